@@ -10,6 +10,8 @@ import (
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/daemon"
 	"src.elv.sh/pkg/daemon/client"
+	"src.elv.sh/pkg/format"
+	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
 )
@@ -17,5 +19,6 @@ import (
 func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
-		buildinfo.Program, daemon.Program, shell.Program{ActivateDaemon: client.Activate}))
+		buildinfo.Program, daemon.Program, lsp.Program, format.Program,
+		shell.Program{ActivateDaemon: client.Activate}))
 }
@@ -9,6 +9,8 @@ import (
 	"os"
 
 	"src.elv.sh/pkg/buildinfo"
+	"src.elv.sh/pkg/format"
+	"src.elv.sh/pkg/lsp"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
 )
@@ -16,7 +18,8 @@ import (
 func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
-		buildinfo.Program, daemonStub{}, shell.Program{}))
+		buildinfo.Program, daemonStub{}, lsp.Program, format.Program,
+		shell.Program{}))
 }
 
 var errNoDaemon = errors.New("daemon is not supported in this build")
@@ -60,6 +60,8 @@ type app struct {
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
 	Highlighter       Highlighter
+	Autosuggester     Autosuggester
+	StatusLine        StatusLine
 	Prompt            Prompt
 	RPrompt           Prompt
 	GlobalBindings    tk.Bindings
@@ -94,6 +96,8 @@ func NewApp(spec AppSpec) App {
 		BeforeReadline:    spec.BeforeReadline,
 		AfterReadline:     spec.AfterReadline,
 		Highlighter:       spec.Highlighter,
+		Autosuggester:     spec.Autosuggester,
+		StatusLine:        spec.StatusLine,
 		Prompt:            spec.Prompt,
 		RPrompt:           spec.RPrompt,
 		GlobalBindings:    spec.GlobalBindings,
@@ -111,6 +115,12 @@ func NewApp(spec AppSpec) App {
 	if a.Highlighter == nil {
 		a.Highlighter = dummyHighlighter{}
 	}
+	if a.Autosuggester == nil {
+		a.Autosuggester = dummyAutosuggester{}
+	}
+	if a.StatusLine == nil {
+		a.StatusLine = dummyStatusLine{}
+	}
 	if a.Prompt == nil {
 		a.Prompt = NewConstPrompt(nil)
 	}
@@ -126,6 +136,8 @@ func NewApp(spec AppSpec) App {
 	a.codeArea = tk.NewCodeArea(tk.CodeAreaSpec{
 		Bindings:      spec.CodeAreaBindings,
 		Highlighter:   a.Highlighter.Get,
+		Autosuggester: a.Autosuggester.Get,
+		StatusLine:    a.StatusLine.Get,
 		Prompt:        a.Prompt.Get,
 		RPrompt:       a.RPrompt.Get,
 		Abbreviations: spec.Abbreviations,
@@ -227,13 +239,17 @@ func (a *app) redraw(flag redrawFlag) {
 	isFinalRedraw := flag&finalRedraw != 0
 	if isFinalRedraw {
 		hideRPrompt := !a.RPromptPersistent()
-		if hideRPrompt {
-			a.codeArea.MutateState(func(s *tk.CodeAreaState) { s.HideRPrompt = true })
-		}
+		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+			s.HideRPrompt = hideRPrompt
+			s.HideAutosuggestion = true
+			s.HideStatusLine = true
+		})
 		bufMain := renderApp(a.codeArea, nil /* addon */, width, height)
-		if hideRPrompt {
-			a.codeArea.MutateState(func(s *tk.CodeAreaState) { s.HideRPrompt = false })
-		}
+		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+			s.HideRPrompt = false
+			s.HideAutosuggestion = false
+			s.HideStatusLine = false
+		})
 		// Insert a newline after the buffer and position the cursor there.
 		bufMain.Extend(term.NewBuffer(width), true)
 
@@ -289,6 +305,7 @@ func (a *app) ReadCode() (string, error) {
 			f(content)
 		}
 		a.resetAllStates()
+		a.codeArea.ClearUndo()
 	}()
 
 	restore, err := a.TTY.Setup()
@@ -334,7 +351,8 @@ func (a *app) ReadCode() (string, error) {
 		wg.Done()
 	}()
 
-	// Relay late updates from prompt, rprompt and highlighter.
+	// Relay late updates from prompt, rprompt, highlighter, autosuggester and
+	// status line.
 	stopRelayLateUpdates := make(chan struct{})
 	defer close(stopRelayLateUpdates)
 	relayLateUpdates := func(ch <-chan struct{}) {
@@ -358,6 +376,8 @@ func (a *app) ReadCode() (string, error) {
 	relayLateUpdates(a.Prompt.LateUpdates())
 	relayLateUpdates(a.RPrompt.LateUpdates())
 	relayLateUpdates(a.Highlighter.LateUpdates())
+	relayLateUpdates(a.Autosuggester.LateUpdates())
+	relayLateUpdates(a.StatusLine.LateUpdates())
 
 	// Trigger an initial prompt update.
 	a.triggerPrompts(true)
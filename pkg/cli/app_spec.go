@@ -13,9 +13,11 @@ type AppSpec struct {
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
 
-	Highlighter Highlighter
-	Prompt      Prompt
-	RPrompt     Prompt
+	Highlighter   Highlighter
+	Autosuggester Autosuggester
+	StatusLine    StatusLine
+	Prompt        Prompt
+	RPrompt       Prompt
 
 	GlobalBindings   tk.Bindings
 	CodeAreaBindings tk.Bindings
@@ -46,6 +48,41 @@ func (dummyHighlighter) Get(code string) (ui.Text, []error) {
 
 func (dummyHighlighter) LateUpdates() <-chan struct{} { return nil }
 
+// Autosuggester represents a source of suggestions for the code whose result
+// can be delivered asynchronously.
+type Autosuggester interface {
+	// Get returns a suggested full content for the given code, or an empty
+	// string if there is no suggestion.
+	Get(code string) string
+	// LateUpdates returns a channel for delivering late updates.
+	LateUpdates() <-chan struct{}
+}
+
+// An Autosuggester implementation that never suggests anything.
+type dummyAutosuggester struct{}
+
+func (dummyAutosuggester) Get(code string) string { return "" }
+
+func (dummyAutosuggester) LateUpdates() <-chan struct{} { return nil }
+
+// StatusLine represents a line of information about the code at the dot, like
+// the signature of the command being typed, whose result can be delivered
+// asynchronously.
+type StatusLine interface {
+	// Get returns the status line for the given code and dot, or an empty text
+	// if there is none.
+	Get(code string, dot int) ui.Text
+	// LateUpdates returns a channel for delivering late updates.
+	LateUpdates() <-chan struct{}
+}
+
+// A StatusLine implementation that never shows anything.
+type dummyStatusLine struct{}
+
+func (dummyStatusLine) Get(string, int) ui.Text { return nil }
+
+func (dummyStatusLine) LateUpdates() <-chan struct{} { return nil }
+
 // Prompt represents a prompt whose result can be delivered asynchronously.
 type Prompt interface {
 	// Trigger requests a re-computation of the prompt. The force flag is set
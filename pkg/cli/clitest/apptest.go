@@ -15,6 +15,7 @@ var Styles = ui.RuneStylesheet{
 	'b': ui.Bold,
 	'*': ui.Stylings(ui.Bold, ui.FgWhite, ui.BgMagenta),
 	'+': ui.Inverse,
+	'U': ui.Stylings(ui.Inverse, ui.Underlined),
 	'/': ui.FgBlue,
 	'#': ui.Stylings(ui.Inverse, ui.FgBlue),
 	'!': ui.FgRed,
@@ -25,6 +26,7 @@ var Styles = ui.RuneStylesheet{
 	'V': ui.Stylings(ui.Underlined, ui.FgGreen),
 	'$': ui.FgMagenta,
 	'c': ui.FgCyan, // mnemonic "Comment"
+	'd': ui.Dim,
 }
 
 // Fixture is a test fixture.
@@ -2,6 +2,7 @@ package mode
 
 import (
 	"errors"
+	"sort"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -55,7 +56,7 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			Horizontal: true,
 			Bindings:   cfg.Bindings,
 			OnSelect: func(it tk.Items, i int) {
-				text := it.(completionItems)[i].ToInsert
+				text := it.(completionItems).items[i].ToInsert
 				app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
 					s.Pending = tk.PendingCode{
 						From: cfg.Replace.From, To: cfg.Replace.To, Content: text}
@@ -67,7 +68,7 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			ExtendStyle: true,
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makePredicate(p)), 0)
+			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makeRanker(p)), 0)
 		},
 	})
 	return completion{w, app.CodeArea()}, nil
@@ -83,20 +84,43 @@ func (w completion) Close(accept bool) {
 	})
 }
 
-type completionItems []CompletionItem
+type completionItems struct {
+	items []CompletionItem
+	// Byte indices of the matched runes in ToShow of each item.
+	matched [][]int
+}
 
-func filterCompletionItems(all []CompletionItem, p func(string) bool) completionItems {
-	var filtered []CompletionItem
+// Filters the items, and sorts them by their scores so that the best match is
+// the first one. Items with the same score are kept in the original order.
+func filterCompletionItems(all []CompletionItem, rank func(string) (int, []int, bool)) completionItems {
+	type rankedItem struct {
+		item    CompletionItem
+		score   int
+		matched []int
+	}
+	var ranked []rankedItem
 	for _, candidate := range all {
-		if p(candidate.ToShow) {
-			filtered = append(filtered, candidate)
+		if score, matched, ok := rank(candidate.ToShow); ok {
+			ranked = append(ranked, rankedItem{candidate, score, matched})
 		}
 	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	filtered := completionItems{
+		items:   make([]CompletionItem, len(ranked)),
+		matched: make([][]int, len(ranked))}
+	for i, r := range ranked {
+		filtered.items[i], filtered.matched[i] = r.item, r.matched
+	}
 	return filtered
 }
 
 func (it completionItems) Show(i int) ui.Text {
-	return ui.Text{&ui.Segment{Style: it[i].ShowStyle, Text: it[i].ToShow}}
+	item := it.items[i]
+	return highlightMatched(
+		ui.Text{&ui.Segment{Style: item.ShowStyle, Text: item.ToShow}},
+		it.matched[i])
 }
 
-func (it completionItems) Len() int { return len(it) }
+func (it completionItems) Len() int { return len(it.items) }
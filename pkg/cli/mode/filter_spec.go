@@ -2,6 +2,7 @@ package mode
 
 import (
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/ui"
 )
@@ -11,6 +12,12 @@ type FilterSpec struct {
 	// Called with the filter text to get the filter predicate. If nil, the
 	// predicate performs substring match.
 	Maker func(string) func(string) bool
+	// Called with the filter text to get a function that ranks items. The
+	// function returns a score of the item (higher is better), the byte
+	// indices of the matched runes, and whether the item matches at all. If
+	// non-nil, it is used instead of Maker, items are sorted by their scores,
+	// and matched runes are highlighted.
+	Ranker func(string) func(string) (int, []int, bool)
 	// Highlighter for the filter. If nil, the filter will not be higlighted.
 	Highlighter func(string) (ui.Text, []error)
 }
@@ -21,3 +28,45 @@ func (f FilterSpec) makePredicate(p string) func(string) bool {
 	}
 	return f.Maker(p)
 }
+
+func (f FilterSpec) makeRanker(p string) func(string) (int, []int, bool) {
+	if f.Ranker == nil {
+		pred := f.makePredicate(p)
+		return func(s string) (int, []int, bool) { return 0, nil, pred(s) }
+	}
+	return f.Ranker(p)
+}
+
+var stylingForMatched = ui.Underlined
+
+// Applies stylingForMatched to the runes in t at the given byte indices, which
+// must be sorted.
+func highlightMatched(t ui.Text, matched []int) ui.Text {
+	if len(matched) == 0 {
+		return t
+	}
+	// Convert the indices to a sequence of [from, to) pairs of byte indices.
+	var sb strings.Builder
+	for _, seg := range t {
+		sb.WriteString(seg.Text)
+	}
+	s := sb.String()
+	var bounds []int
+	for _, i := range matched {
+		if i < 0 || i >= len(s) {
+			continue
+		}
+		_, n := utf8.DecodeRuneInString(s[i:])
+		to := i + n
+		if n := len(bounds); n > 0 && bounds[n-1] == i {
+			bounds[n-1] = to
+		} else {
+			bounds = append(bounds, i, to)
+		}
+	}
+	parts := t.Partition(bounds...)
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = ui.StyleText(parts[i], stylingForMatched)
+	}
+	return ui.Concat(parts...)
+}
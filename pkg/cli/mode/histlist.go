@@ -2,6 +2,7 @@ package mode
 
 import (
 	"fmt"
+	"sort"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -45,7 +46,7 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 	for i, cmd := range cmds {
 		last[cmd.Text] = i
 	}
-	cmdItems := histlistItems{cmds, last}
+	cmdItems := histlistItems{entries: cmds, last: last}
 
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
@@ -74,7 +75,7 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			it := cmdItems.filter(spec.Filter.makePredicate(p), spec.Dedup())
+			it := cmdItems.filter(spec.Filter.makeRanker(p), spec.Dedup())
 			w.ListBox().Reset(it, it.Len()-1)
 		},
 	})
@@ -84,26 +85,47 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 type histlistItems struct {
 	entries []storedefs.Cmd
 	last    map[string]int
+	// Byte indices of the matched runes in the text of each entry.
+	matched [][]int
 }
 
-func (it histlistItems) filter(p func(string) bool, dedup bool) histlistItems {
-	var filtered []storedefs.Cmd
+// Filters the entries, and sorts them by their scores so that the best match
+// is the last one, next to the code area. Entries with the same score are kept
+// in the original order.
+func (it histlistItems) filter(rank func(string) (int, []int, bool), dedup bool) histlistItems {
+	type rankedCmd struct {
+		cmd     storedefs.Cmd
+		score   int
+		matched []int
+	}
+	var ranked []rankedCmd
 	for i, entry := range it.entries {
 		text := entry.Text
 		if dedup && it.last[text] != i {
 			continue
 		}
-		if p(text) {
-			filtered = append(filtered, entry)
+		if score, matched, ok := rank(text); ok {
+			ranked = append(ranked, rankedCmd{entry, score, matched})
 		}
 	}
-	return histlistItems{filtered, nil}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score < ranked[j].score
+	})
+	filtered := histlistItems{
+		entries: make([]storedefs.Cmd, len(ranked)),
+		matched: make([][]int, len(ranked))}
+	for i, r := range ranked {
+		filtered.entries[i], filtered.matched[i] = r.cmd, r.matched
+	}
+	return filtered
 }
 
 func (it histlistItems) Show(i int) ui.Text {
 	entry := it.entries[i]
 	// TODO: The alignment of the index works up to 10000 entries.
-	return ui.T(fmt.Sprintf("%4d %s", entry.Seq, entry.Text))
+	return ui.Concat(
+		ui.T(fmt.Sprintf("%4d ", entry.Seq)),
+		highlightMatched(ui.T(entry.Text), it.matched[i]))
 }
 
 func (it histlistItems) Len() int { return len(it.entries) }
@@ -9,6 +9,7 @@ import (
 	"src.elv.sh/pkg/cli/histutil"
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/strutil"
 	"src.elv.sh/pkg/ui"
 )
 
@@ -142,6 +143,38 @@ func TestHistlist_CustomFilter(t *testing.T) {
 		"++++++++++++++++++++++++++++++++++++++++++++++++++")
 }
 
+func TestHistlist_Ranker(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore(
+		// 0   1         2
+		"vi", "elvish", "nvi")
+
+	startHistlist(f.App, HistlistSpec{
+		AllCmds: st.AllCmds,
+		Filter: FilterSpec{
+			Ranker: func(p string) func(string) (int, []int, bool) {
+				return func(s string) (int, []int, bool) {
+					return strutil.FuzzyMatch(p, s, false)
+				}
+			},
+		},
+	})
+	f.TTY.Inject(term.K('v'), term.K('i'))
+	// The best match is the last one; matched runes are highlighted.
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  vi", Styles,
+		"********************   ", term.DotHere, "\n",
+		"   1 elvish", Styles,
+		"       __  ", "\n",
+		"   2 nvi", Styles,
+		"      __", "\n",
+		"   0 vi                                           ", Styles,
+		"+++++UU+++++++++++++++++++++++++++++++++++++++++++")
+}
+
 func startHistlist(app cli.App, spec HistlistSpec) {
 	w, err := NewHistlist(app, spec)
 	startMode(app, w, err)
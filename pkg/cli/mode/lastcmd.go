@@ -2,6 +2,7 @@ package mode
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -25,6 +26,9 @@ type LastcmdSpec struct {
 	Store LastcmdStore
 	// Wordifier breaks a command into words.
 	Wordifier func(string) []string
+	// Configuration for the filter, which is used when the filter text is not
+	// an index.
+	Filter FilterSpec
 }
 
 // LastcmdStore is a subset of histutil.Store used in lastcmd mode.
@@ -64,7 +68,10 @@ func NewLastcmd(app cli.App, cfg LastcmdSpec) (Lastcmd, error) {
 		app.SetAddon(nil, false)
 	}
 	w := tk.NewComboBox(tk.ComboBoxSpec{
-		CodeArea: tk.CodeAreaSpec{Prompt: modePrompt(" LASTCMD ", true)},
+		CodeArea: tk.CodeAreaSpec{
+			Prompt:      modePrompt(" LASTCMD ", true),
+			Highlighter: cfg.Filter.Highlighter,
+		},
 		ListBox: tk.ListBoxSpec{
 			Bindings: cfg.Bindings,
 			OnAccept: func(it tk.Items, i int) {
@@ -72,6 +79,10 @@ func NewLastcmd(app cli.App, cfg LastcmdSpec) (Lastcmd, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
+			if !isLastcmdIndexFilter(p) {
+				w.ListBox().Reset(rankLastcmdItems(entries, cfg.Filter.makeRanker(p)), 0)
+				return
+			}
 			items := filterLastcmdItems(entries, p)
 			if len(items.entries) == 1 {
 				accept(items.entries[0].content)
@@ -86,6 +97,9 @@ func NewLastcmd(app cli.App, cfg LastcmdSpec) (Lastcmd, error) {
 type lastcmdItems struct {
 	negFilter bool
 	entries   []lastcmdEntry
+	// Byte indices of the matched runes in the content of each entry, when
+	// the entries are filtered by their contents.
+	matched [][]int
 }
 
 type lastcmdEntry struct {
@@ -94,9 +108,41 @@ type lastcmdEntry struct {
 	content  string
 }
 
+// Returns whether the filter text selects entries by their indices, i.e. is
+// empty or consists of an optional "-" followed by digits.
+func isLastcmdIndexFilter(p string) bool {
+	return strings.TrimLeft(strings.TrimPrefix(p, "-"), "0123456789") == ""
+}
+
+// Filters the entries by their contents, and sorts them by their scores so
+// that the best match is the first one.
+func rankLastcmdItems(allEntries []lastcmdEntry, rank func(string) (int, []int, bool)) lastcmdItems {
+	type rankedEntry struct {
+		entry   lastcmdEntry
+		score   int
+		matched []int
+	}
+	var ranked []rankedEntry
+	for _, entry := range allEntries {
+		if score, matched, ok := rank(entry.content); ok {
+			ranked = append(ranked, rankedEntry{entry, score, matched})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	items := lastcmdItems{
+		entries: make([]lastcmdEntry, len(ranked)),
+		matched: make([][]int, len(ranked))}
+	for i, r := range ranked {
+		items.entries[i], items.matched[i] = r.entry, r.matched
+	}
+	return items
+}
+
 func filterLastcmdItems(allEntries []lastcmdEntry, p string) lastcmdItems {
 	if p == "" {
-		return lastcmdItems{false, allEntries}
+		return lastcmdItems{entries: allEntries}
 	}
 	var entries []lastcmdEntry
 	negFilter := strings.HasPrefix(p, "-")
@@ -106,7 +152,7 @@ func filterLastcmdItems(allEntries []lastcmdEntry, p string) lastcmdItems {
 			entries = append(entries, entry)
 		}
 	}
-	return lastcmdItems{negFilter, entries}
+	return lastcmdItems{negFilter: negFilter, entries: entries}
 }
 
 func (it lastcmdItems) Show(i int) ui.Text {
@@ -120,7 +166,13 @@ func (it lastcmdItems) Show(i int) ui.Text {
 	// NOTE: We now use a hardcoded width of 3 for the index, which will work as
 	// long as the command has less than 1000 words (when filter is positive) or
 	// 100 words (when filter is negative).
-	return ui.T(fmt.Sprintf("%3s %s", index, entry.content))
+	var matched []int
+	if it.matched != nil {
+		matched = it.matched[i]
+	}
+	return ui.Concat(
+		ui.T(fmt.Sprintf("%3s ", index)),
+		highlightMatched(ui.T(entry.content), matched))
 }
 
 func (it lastcmdItems) Len() int { return len(it.entries) }
@@ -103,6 +103,26 @@ func TestLastcmd(t *testing.T) {
 	f.TestTTY(t, "foo", term.DotHere)
 }
 
+func TestLastcmd_FilterContent(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore("foo bar baz")
+	startLastcmd(f.App, LastcmdSpec{Store: st})
+
+	// Filters that are not indices match the content of entries.
+	f.TTY.Inject(term.K('b'), term.K('a'))
+	f.TestTTY(t,
+		"\n", // empty code area
+		" LASTCMD  ba", Styles,
+		"*********   ", term.DotHere, "\n",
+		"    foo bar baz                                   \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		"  1 bar\n",
+		"  2 baz",
+	)
+}
+
 func startLastcmd(app cli.App, spec LastcmdSpec) {
 	w, err := NewLastcmd(app, spec)
 	startMode(app, w, err)
@@ -6,6 +6,7 @@ import (
 	"math"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"src.elv.sh/pkg/cli"
@@ -89,7 +90,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 		}
 	}
 
-	l := locationList{dirs}
+	l := locationList{dirs: dirs}
 
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
@@ -111,7 +112,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(l.filter(cfg.Filter.makePredicate(p)), 0)
+			w.ListBox().Reset(l.filter(cfg.Filter.makeRanker(p)), 0)
 		},
 	})
 	return w, nil
@@ -152,21 +153,41 @@ func (ws LocationWSIterator) Parse(path string) (kind, root string) {
 
 type locationList struct {
 	dirs []storedefs.Dir
+	// Byte indices of the matched runes in the shown path of each directory.
+	matched [][]int
 }
 
-func (l locationList) filter(p func(string) bool) locationList {
-	var filteredDirs []storedefs.Dir
+// Filters the directories, and sorts them by their scores so that the best
+// match is the first one. Directories with the same score are kept in the
+// original order.
+func (l locationList) filter(rank func(string) (int, []int, bool)) locationList {
+	type rankedDir struct {
+		dir     storedefs.Dir
+		score   int
+		matched []int
+	}
+	var ranked []rankedDir
 	for _, dir := range l.dirs {
-		if p(fsutil.TildeAbbr(dir.Path)) {
-			filteredDirs = append(filteredDirs, dir)
+		if score, matched, ok := rank(fsutil.TildeAbbr(dir.Path)); ok {
+			ranked = append(ranked, rankedDir{dir, score, matched})
 		}
 	}
-	return locationList{filteredDirs}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	filtered := locationList{
+		dirs:    make([]storedefs.Dir, len(ranked)),
+		matched: make([][]int, len(ranked))}
+	for i, r := range ranked {
+		filtered.dirs[i], filtered.matched[i] = r.dir, r.matched
+	}
+	return filtered
 }
 
 func (l locationList) Show(i int) ui.Text {
-	return ui.T(fmt.Sprintf("%s %s",
-		showScore(l.dirs[i].Score), fsutil.TildeAbbr(l.dirs[i].Path)))
+	return ui.Concat(
+		ui.T(showScore(l.dirs[i].Score)+" "),
+		highlightMatched(ui.T(fsutil.TildeAbbr(l.dirs[i].Path)), l.matched[i]))
 }
 
 func (l locationList) Len() int { return len(l.dirs) }
@@ -130,7 +130,7 @@ func (w *navigation) descend() {
 	if state.Items.Len() == 0 {
 		return
 	}
-	selected := state.Items.(fileItems)[state.Selected]
+	selected := state.Items.(fileItems).files[state.Selected]
 	if !selected.IsDirDeep() {
 		return
 	}
@@ -188,7 +188,7 @@ func (w *navigation) SelectedName() string {
 	}
 	state := col.CopyState()
 	if 0 <= state.Selected && state.Selected < state.Items.Len() {
-		return state.Items.(fileItems)[state.Selected].Name()
+		return state.Items.(fileItems).files[state.Selected].Name()
 	}
 	return ""
 }
@@ -220,10 +220,10 @@ func updateState(w *navigation, selectName string) {
 	if err == nil {
 		currentCol = makeColInner(
 			current,
-			w.Filter.makePredicate(filter),
+			w.Filter.makeRanker(filter),
 			showHidden,
 			func(it tk.Items, i int) {
-				previewCol := makeCol(it.(fileItems)[i], showHidden)
+				previewCol := makeCol(it.(fileItems).files[i], showHidden)
 				colView.MutateState(func(s *tk.ColViewState) {
 					s.Columns[2] = previewCol
 				})
@@ -265,7 +265,7 @@ func tryToSelectName(w tk.Widget, name string) {
 		if !ok {
 			return 0
 		}
-		for i, file := range items {
+		for i, file := range items.files {
 			if file.Name() == name {
 				return i
 			}
@@ -275,31 +275,20 @@ func tryToSelectName(w tk.Widget, name string) {
 }
 
 func makeCol(f NavigationFile, showHidden bool) tk.Widget {
-	return makeColInner(f, func(string) bool { return true }, showHidden, nil)
+	matchAll := func(string) (int, []int, bool) { return 0, nil, true }
+	return makeColInner(f, matchAll, showHidden, nil)
 }
 
-func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, onSelect func(tk.Items, int)) tk.Widget {
+func makeColInner(f NavigationFile, rank func(string) (int, []int, bool), showHidden bool, onSelect func(tk.Items, int)) tk.Widget {
 	files, content, err := f.Read()
 	if err != nil {
 		return makeErrCol(err)
 	}
 
 	if files != nil {
-		var filtered []NavigationFile
-		for _, file := range files {
-			name := file.Name()
-			hidden := len(name) > 0 && name[0] == '.'
-			if filter(name) && (showHidden || !hidden) {
-				filtered = append(filtered, file)
-			}
-		}
-		files = filtered
-		sort.Slice(files, func(i, j int) bool {
-			return files[i].Name() < files[j].Name()
-		})
 		return tk.NewListBox(tk.ListBoxSpec{
 			Padding: 1, ExtendStyle: true, OnSelect: onSelect,
-			State: tk.ListBoxState{Items: fileItems(files)},
+			State: tk.ListBoxState{Items: filterFiles(files, rank, showHidden)},
 		})
 	}
 
@@ -314,13 +303,59 @@ func makeErrCol(err error) tk.Widget {
 	return tk.Label{Content: ui.T(err.Error(), ui.FgRed)}
 }
 
-type fileItems []NavigationFile
+type fileItems struct {
+	files []NavigationFile
+	// Byte indices of the matched runes in the name of each file.
+	matched [][]int
+}
+
+// Filters the files, and sorts them by their scores so that the best match is
+// the first one. Files with the same score are sorted by their names.
+func filterFiles(files []NavigationFile, rank func(string) (int, []int, bool), showHidden bool) fileItems {
+	type rankedFile struct {
+		file    NavigationFile
+		score   int
+		matched []int
+	}
+	var ranked []rankedFile
+	for _, file := range files {
+		name := file.Name()
+		hidden := len(name) > 0 && name[0] == '.'
+		if showHidden || !hidden {
+			if score, matched, ok := rank(name); ok {
+				ranked = append(ranked, rankedFile{file, score, matched})
+			}
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].file.Name() < ranked[j].file.Name()
+	})
+	items := fileItems{
+		files:   make([]NavigationFile, len(ranked)),
+		matched: make([][]int, len(ranked))}
+	for i, r := range ranked {
+		items.files[i], items.matched[i] = r.file, r.matched
+	}
+	return items
+}
 
 func (it fileItems) Show(i int) ui.Text {
-	return it[i].ShowName()
+	name := it.files[i].ShowName()
+	if len(it.matched[i]) == 0 || len(name) == 0 {
+		return name
+	}
+	// The list box extends the styles of the first and last segments to the
+	// padding; surround the name with empty segments in its original styles,
+	// so that the highlighting of matches is not extended.
+	first, last := *name[0], *name[len(name)-1]
+	first.Text, last.Text = "", ""
+	return ui.Concat(ui.Text{&first}, highlightMatched(name, it.matched[i]), ui.Text{&last})
 }
 
-func (it fileItems) Len() int { return len(it) }
+func (it fileItems) Len() int { return len(it.files) }
 
 func sanitize(content string) string {
 	// Remove unprintable characters, and replace tabs with 4 spaces.
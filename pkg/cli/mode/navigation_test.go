@@ -150,6 +150,42 @@ func TestGetSelectedName(t *testing.T) {
 	}
 }
 
+func TestNavigation_Ranker(t *testing.T) {
+	f, cleanup := setup()
+	defer cleanup()
+	defer f.Stop()
+
+	w := startNavigation(f.App, NavigationSpec{
+		Cursor: getTestCursor(),
+		Filter: FilterSpec{
+			Ranker: func(p string) func(string) (int, []int, bool) {
+				return func(s string) (int, []int, bool) {
+					switch s {
+					case "d1":
+						return 2, []int{0}, true
+					case "d3":
+						return 1, nil, true
+					}
+					return 0, nil, false
+				}
+			},
+		},
+	})
+	w.MutateFiltering(func(bool) bool { return true })
+	f.TTY.Inject(term.K('x'))
+	// The best match is the first one; matched runes are highlighted.
+	f.TestTTY(t,
+		"\n",
+		" NAVIGATING  x", Styles,
+		"************  ", term.DotHere, "\n",
+		" a    d1            content    d1\n", Styles,
+		"     +U++++++++++++",
+		" d    d3            line 2\n", Styles,
+		"#### //////////////",
+		" f  ",
+	)
+}
+
 func TestNavigation_FakeFS(t *testing.T) {
 	cursor := getTestCursor()
 	testNavigation(t, cursor)
@@ -0,0 +1,897 @@
+package mode
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+// ViCmd is a mode that emulates the normal and visual states of Vi. The insert
+// state is emulated by closing the mode and going back to the code area; the
+// mode should be started again when leaving the insert state.
+type ViCmd interface {
+	tk.Widget
+	// Visual returns whether the mode is in the visual state.
+	Visual() bool
+}
+
+// ViCmdSpec specifies the configuration for the vi command mode.
+type ViCmdSpec struct {
+	// Key bindings. They take precedence over the builtin commands when no
+	// command is pending.
+	Bindings tk.Bindings
+	// State shared by all the instances of the mode. If nil, a new one is
+	// created.
+	Registers *ViRegisters
+}
+
+// ViRegisters keeps the state of the vi command mode that survives across
+// instances of the mode: the registers, the last change (for the . command),
+// the last character search (for the ; and , commands), and the insertion in
+// progress. The zero value is ready to use.
+type ViRegisters struct {
+	mu         sync.Mutex
+	regs       map[rune]viRegister
+	lastFind   viCommand
+	lastChange *viChange
+	insert     *viInsert
+}
+
+type viRegister struct {
+	text     string
+	linewise bool
+}
+
+// A change that can be repeated with the . command.
+type viChange struct {
+	cmd viCommand
+	// Text inserted after the command, if the command enters the insert state.
+	inserted string
+}
+
+// An insertion that started when a command entered the insert state.
+type viInsert struct {
+	// Buffer when the insertion started.
+	from tk.CodeBuffer
+	// How many times the inserted text should be repeated, and the separator
+	// to use between the repetitions.
+	repeat int
+	sep    string
+	change *viChange
+}
+
+type viCmd struct {
+	app cli.App
+	ViCmdSpec
+
+	mu      sync.Mutex
+	pending []rune
+	visual  bool
+	anchor  int
+}
+
+// NewViCmd creates a new ViCmd mode. Since the mode is meant to be started
+// when leaving the insert state, it finishes the insertion started by the last
+// instance of the mode, if any, and moves the dot left by one character.
+func NewViCmd(app cli.App, cfg ViCmdSpec) ViCmd {
+	if cfg.Bindings == nil {
+		cfg.Bindings = tk.DummyBindings{}
+	}
+	if cfg.Registers == nil {
+		cfg.Registers = &ViRegisters{}
+	}
+	w := &viCmd{app: app, ViCmdSpec: cfg}
+	app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+		cfg.Registers.finishInsert(&s.Buffer)
+		if s.Buffer.Dot > viSOL(s.Buffer.Content, s.Buffer.Dot) {
+			s.Buffer.Dot = viPrevRune(s.Buffer.Content, s.Buffer.Dot)
+		}
+	})
+	return w
+}
+
+func (w *viCmd) Render(width, height int) *term.Buffer {
+	w.mu.Lock()
+	name := " NORMAL "
+	if w.visual {
+		name = " VISUAL "
+	}
+	content := modeLine(name, false)
+	if len(w.pending) > 0 {
+		content = ui.Concat(modeLine(name, true), ui.T(string(w.pending)))
+	}
+	w.mu.Unlock()
+	buf := term.NewBufferBuilder(width).WriteStyled(content).SetDotHere().Buffer()
+	buf.TrimToLines(0, height)
+	return buf
+}
+
+func (w *viCmd) Handle(event term.Event) bool {
+	keyEvent, ok := event.(term.KeyEvent)
+	if !ok {
+		return false
+	}
+	w.mu.Lock()
+	pending := len(w.pending) > 0
+	w.mu.Unlock()
+	if !pending && w.Bindings.Handle(w, event) {
+		return true
+	}
+	return w.handleKey(ui.Key(keyEvent))
+}
+
+func (w *viCmd) Focus() bool { return false }
+
+func (w *viCmd) Visual() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.visual
+}
+
+// Close clears the selection of the visual state.
+func (w *viCmd) Close(bool) {
+	w.app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+		s.Selection = tk.Selection{}
+	})
+}
+
+func (w *viCmd) handleKey(key ui.Key) bool {
+	w.mu.Lock()
+	if key == ui.K('[', ui.Ctrl) {
+		if len(w.pending) > 0 {
+			w.pending = nil
+		} else if w.visual {
+			w.visual = false
+			w.mu.Unlock()
+			w.Close(false)
+			return true
+		}
+		w.mu.Unlock()
+		return true
+	}
+	if key.Mod != 0 || key.Rune < 0x20 || key.Rune == ui.Backspace {
+		// Not a key for vi commands. Pressing one of these keys cancels the
+		// pending command.
+		handled := len(w.pending) > 0
+		w.pending = nil
+		w.mu.Unlock()
+		return handled
+	}
+
+	w.pending = append(w.pending, key.Rune)
+	cmd, status := parseViCommand(w.pending, w.visual)
+	if status == viIncomplete {
+		w.mu.Unlock()
+		return true
+	}
+	w.pending = nil
+	if status == viInvalid {
+		w.mu.Unlock()
+		return true
+	}
+	var insert bool
+	w.app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+		if w.visual {
+			insert = w.execVisual(cmd, &s.Buffer)
+			if !insert {
+				viClampDot(&s.Buffer)
+			}
+		} else {
+			insert = w.execNormal(cmd, &s.Buffer, false)
+		}
+		if w.visual {
+			s.Selection = w.selection(s.Buffer)
+		} else {
+			s.Selection = tk.Selection{}
+		}
+	})
+	w.mu.Unlock()
+	if insert {
+		w.app.SetAddon(nil, false)
+	}
+	return true
+}
+
+// Parsing of commands.
+//
+// A command consists of an optional register, an optional count, and either a
+// simple command, a motion, or an operator followed by another optional count
+// and a motion or text object. Commands are parsed after each key, and the
+// parser reports whether the keys so far form an incomplete, invalid or
+// complete command.
+
+type viCommand struct {
+	// Register, or 0 if not specified.
+	reg rune
+	// Count before the command, or 0 if not specified.
+	count int
+	// Operator, or 0 if the command is not an operator.
+	op rune
+	// Count after the operator, or 0 if not specified.
+	opCount int
+	// Name of the simple command, motion or text object. For an operator
+	// applied to whole lines (like dd), this is the same as op.
+	name string
+	// Character argument of commands like f and r.
+	arg rune
+}
+
+type viParseStatus int
+
+const (
+	viIncomplete viParseStatus = iota
+	viInvalid
+	viComplete
+)
+
+const (
+	viOperators = "dcy"
+	// Simple commands in the normal state.
+	viNormalCommands = "xXsSDCYpPr~JiaIAoOv."
+	// Simple commands in the visual state.
+	viVisualCommands = "dxXDcsCSyYpP~rJov"
+)
+
+func parseViCommand(keys []rune, visual bool) (viCommand, viParseStatus) {
+	var c viCommand
+	i := 0
+	next := func() (rune, bool) {
+		if i == len(keys) {
+			return 0, false
+		}
+		i++
+		return keys[i-1], true
+	}
+	readCount := func() (int, rune, bool) {
+		n := 0
+		for {
+			r, ok := next()
+			if !ok {
+				return 0, 0, false
+			}
+			if !('1' <= r && r <= '9' || r == '0' && n > 0) {
+				return n, r, true
+			}
+			n = n*10 + int(r-'0')
+		}
+	}
+
+	r, ok := next()
+	if !ok {
+		return c, viIncomplete
+	}
+	if r == '"' {
+		if c.reg, ok = next(); !ok {
+			return c, viIncomplete
+		}
+		if !isViRegister(c.reg) {
+			return c, viInvalid
+		}
+	} else {
+		i--
+	}
+	if c.count, r, ok = readCount(); !ok {
+		return c, viIncomplete
+	}
+
+	switch {
+	case !visual && strings.ContainsRune(viOperators, r):
+		c.op = r
+		if c.opCount, r, ok = readCount(); !ok {
+			return c, viIncomplete
+		}
+		if r == c.op {
+			c.name = string(r)
+			return c, viComplete
+		}
+		return parseViMotion(c, r, true, next)
+	case strings.ContainsRune(viNormalCommands, r) && !visual,
+		strings.ContainsRune(viVisualCommands, r) && visual:
+		c.name = string(r)
+		if r == 'r' {
+			if c.arg, ok = next(); !ok {
+				return c, viIncomplete
+			}
+		}
+		return c, viComplete
+	}
+	return parseViMotion(c, r, visual, next)
+}
+
+func parseViMotion(c viCommand, r rune, allowObject bool, next func() (rune, bool)) (viCommand, viParseStatus) {
+	var ok bool
+	switch {
+	case r == 'g':
+		if r, ok = next(); !ok {
+			return c, viIncomplete
+		}
+		if r != 'g' {
+			return c, viInvalid
+		}
+		c.name = "gg"
+	case r == ';' || r == ',':
+		c.name = string(r)
+	case allowObject && (r == 'i' || r == 'a'):
+		name, ok := next()
+		if !ok {
+			return c, viIncomplete
+		}
+		if getViTextObject(r, name) == nil {
+			return c, viInvalid
+		}
+		c.name = string([]rune{r, name})
+	default:
+		m, ok := viMotions[string(r)]
+		if !ok {
+			return c, viInvalid
+		}
+		c.name = string(r)
+		if m.takesArg {
+			if c.arg, ok = next(); !ok {
+				return c, viIncomplete
+			}
+		}
+	}
+	return c, viComplete
+}
+
+func isViRegister(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' ||
+		r == '"' || r == '_'
+}
+
+// Execution of commands.
+
+// Simple commands that are shorthands of operators.
+var viShorthands = map[string]viCommand{
+	"x": {op: 'd', name: "l"},
+	"X": {op: 'd', name: "h"},
+	"D": {op: 'd', name: "$"},
+	"C": {op: 'c', name: "$"},
+	"s": {op: 'c', name: "l"},
+	"S": {op: 'c', name: "c"},
+	"Y": {op: 'y', name: "y"},
+}
+
+// Executes a command in the normal state, and returns whether the insert state
+// should be entered. When replay is true, the command is being repeated by the
+// . command.
+func (w *viCmd) execNormal(c viCommand, buf *tk.CodeBuffer, replay bool) bool {
+	regs := w.Registers
+	if c.name == "." {
+		regs.mu.Lock()
+		last := regs.lastChange
+		if last == nil {
+			regs.mu.Unlock()
+			return false
+		}
+		cmd, inserted := last.cmd, last.inserted
+		if c.count > 0 {
+			cmd.count, cmd.opCount = c.count, 0
+		}
+		// A count given to . replaces the count of the repeated change.
+		last.cmd = cmd
+		regs.mu.Unlock()
+		if w.execNormal(cmd, buf, true) {
+			repeat, sep := viInsertRepeat(cmd)
+			buf.InsertAtDot(inserted)
+			for n := 1; n < repeat; n++ {
+				buf.InsertAtDot(sep + inserted)
+			}
+			if buf.Dot > viSOL(buf.Content, buf.Dot) {
+				buf.Dot = viPrevRune(buf.Content, buf.Dot)
+			}
+		}
+		viClampDot(buf)
+		return false
+	}
+
+	expanded := c
+	if sh, ok := viShorthands[c.name]; ok {
+		expanded = sh
+		expanded.reg, expanded.count = c.reg, c.count
+	}
+	insert, changed := w.execNormalExpanded(expanded, buf)
+	if changed && !replay {
+		change := &viChange{cmd: c}
+		regs.mu.Lock()
+		regs.lastChange = change
+		if insert {
+			repeat, sep := viInsertRepeat(c)
+			regs.insert = &viInsert{*buf, repeat, sep, change}
+		}
+		regs.mu.Unlock()
+	}
+	if !insert {
+		viClampDot(buf)
+	}
+	return insert
+}
+
+// Returns how many times the text inserted after a command should be
+// repeated, and the separator between the repetitions.
+func viInsertRepeat(c viCommand) (int, string) {
+	switch c.name {
+	case "i", "a", "I", "A":
+		return viCount(c.count), ""
+	case "o", "O":
+		return viCount(c.count), "\n"
+	}
+	return 1, ""
+}
+
+// Executes a command that is not a shorthand, and returns whether the insert
+// state should be entered and whether the buffer may have been changed.
+func (w *viCmd) execNormalExpanded(c viCommand, buf *tk.CodeBuffer) (insert, changed bool) {
+	s, dot := buf.Content, buf.Dot
+	count := viCount(c.count)
+	if c.op != 0 {
+		return w.execOperator(c, buf), c.op != 'y'
+	}
+	switch c.name {
+	case "i":
+		return true, true
+	case "a":
+		if dot < viEOL(s, dot) {
+			buf.Dot = viNextRune(s, dot)
+		}
+		return true, true
+	case "I":
+		buf.Dot = viFirstNonBlank(s, dot)
+		return true, true
+	case "A":
+		buf.Dot = viEOL(s, dot)
+		return true, true
+	case "o":
+		buf.Dot = viEOL(s, dot)
+		buf.InsertAtDot("\n")
+		return true, true
+	case "O":
+		buf.Dot = viSOL(s, dot)
+		buf.InsertAtDot("\n")
+		buf.Dot--
+		return true, true
+	case "v":
+		w.visual = true
+		w.anchor = dot
+		return false, false
+	case "p", "P":
+		r, ok := w.Registers.get(c.reg)
+		if ok {
+			w.put(r, count, c.name == "p", buf)
+		}
+		return false, ok
+	case "r":
+		eol := viEOL(s, dot)
+		i := dot
+		for n := 0; n < count; n++ {
+			if i == eol {
+				return false, false
+			}
+			i = viNextRune(s, i)
+		}
+		replaced := strings.Repeat(string(c.arg), count)
+		buf.Content = s[:dot] + replaced + s[i:]
+		buf.Dot = dot + len(replaced) - utf8.RuneLen(c.arg)
+		return false, true
+	case "~":
+		to, _ := viRight(s, dot, count, 0)
+		buf.Content = s[:dot] + toggleCase(s[dot:to]) + s[to:]
+		buf.Dot = to
+		return false, true
+	case "J":
+		viJoinLines(buf, count)
+		return false, true
+	}
+	w.moveDot(c, buf)
+	return false, false
+}
+
+// Moves the dot with a motion, and returns whether the motion succeeded. Must
+// be called with Registers.mu unlocked.
+func (w *viCmd) moveDot(c viCommand, buf *tk.CodeBuffer) bool {
+	m, arg := w.resolveMotion(c)
+	target, ok := m.move(buf.Content, buf.Dot, w.motionCount(m, c), arg)
+	if ok {
+		buf.Dot = target
+	}
+	return ok
+}
+
+// Returns the motion named in the command together with its argument,
+// resolving ; and , to the last character search. Also records the command as
+// the last character search if it is one.
+func (w *viCmd) resolveMotion(c viCommand) (viMotion, rune) {
+	regs := w.Registers
+	regs.mu.Lock()
+	defer regs.mu.Unlock()
+	switch c.name {
+	case "f", "t", "F", "T":
+		regs.lastFind = c
+	case ";", ",":
+		find := regs.lastFind
+		if find.name == "" {
+			return viMotion{move: func(string, int, int, rune) (int, bool) { return 0, false }}, 0
+		}
+		name := rune(find.name[0])
+		if c.name == "," {
+			name = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[name]
+		}
+		m := viMotions[string(name)]
+		m.move = viFindMotion(name, true)
+		return m, find.arg
+	}
+	return viMotions[c.name], c.arg
+}
+
+func (w *viCmd) motionCount(m viMotion, c viCommand) int {
+	count := c.count
+	if c.opCount > 0 {
+		count = viCount(count) * c.opCount
+	}
+	if m.rawCount {
+		return count
+	}
+	return viCount(count)
+}
+
+// Executes an operator in the normal state, and returns whether the insert
+// state should be entered.
+func (w *viCmd) execOperator(c viCommand, buf *tk.CodeBuffer) bool {
+	s, dot := buf.Content, buf.Dot
+	count := viCount(c.count) * viCount(c.opCount)
+	switch {
+	case c.name == string(c.op):
+		// Operator on whole lines, like dd.
+		last := dot
+		for n := 1; n < count; n++ {
+			eol := viEOL(s, last)
+			if eol == len(s) {
+				break
+			}
+			last = eol + 1
+		}
+		return w.applyOperator(c.op, c.reg, dot, last, true, buf)
+	case c.name[0] == 'i' || c.name[0] == 'a':
+		from, to, ok := getViTextObject(rune(c.name[0]), rune(c.name[1]))(s, dot, count)
+		if !ok {
+			return false
+		}
+		return w.applyOperator(c.op, c.reg, from, to, false, buf)
+	}
+
+	if c.op == 'c' && (c.name == "w" || c.name == "W") &&
+		dot < len(s) && categorizeRune(s, dot, categorizeBigWord) != 0 {
+		// Special case: cw changes to the end of the word, like ce.
+		c.name = map[string]string{"w": "e", "W": "E"}[c.name]
+		if viWordEndsAt(s, dot, c.name) {
+			count--
+		}
+		if count == 0 {
+			return w.applyOperator(c.op, c.reg, dot, viNextRune(s, dot), false, buf)
+		}
+		c.count, c.opCount = count, 0
+	}
+	m, arg := w.resolveMotion(c)
+	target, ok := m.move(s, dot, w.motionCount(m, c), arg)
+	if !ok {
+		return false
+	}
+	from, to := dot, target
+	if from > to {
+		from, to = to, from
+	}
+	if m.linewise {
+		return w.applyOperator(c.op, c.reg, from, to, true, buf)
+	}
+	if m.inclusive && to < len(s) {
+		to = viNextRune(s, to)
+	}
+	if (c.name == "w" || c.name == "W") && to > from {
+		// Special case: the motion never goes past the end of the line the
+		// last word moved over is on.
+		if i := strings.LastIndexByte(s[from:to], '\n'); i != -1 {
+			to = from + i
+		}
+	}
+	return w.applyOperator(c.op, c.reg, from, to, false, buf)
+}
+
+// Reports whether the word (as defined by the e or E motion) under i ends at
+// i.
+func viWordEndsAt(s string, i int, name string) bool {
+	categorize := categorizeBigWord
+	if name == "e" {
+		categorize = tk.CategorizeSmallWord
+	}
+	j := viNextRune(s, i)
+	return j == len(s) || categorizeRune(s, j, categorize) != categorizeRune(s, i, categorize)
+}
+
+// Applies an operator to the region [from, to). If linewise is true, from and
+// to are positions on the first and last lines of the region instead. Returns
+// whether the insert state should be entered.
+func (w *viCmd) applyOperator(op, reg rune, from, to int, linewise bool, buf *tk.CodeBuffer) bool {
+	s := buf.Content
+	if linewise {
+		from, to = viSOL(s, from), viEOL(s, to)
+	}
+	w.Registers.store(reg, s[from:to], linewise, op == 'y')
+	switch op {
+	case 'y':
+		if !linewise || viSOL(s, buf.Dot) != from {
+			buf.Dot = from
+		}
+	case 'd':
+		if linewise {
+			// Also delete one newline.
+			if to < len(s) {
+				to++
+			} else if from > 0 {
+				from--
+			}
+		}
+		buf.Content = s[:from] + s[to:]
+		buf.Dot = from
+		if linewise {
+			buf.Dot = viFirstNonBlank(buf.Content, from)
+		}
+	case 'c':
+		buf.Content = s[:from] + s[to:]
+		buf.Dot = from
+		return true
+	}
+	return false
+}
+
+// Puts the content of a register count times, after the dot if after is true
+// and before the dot otherwise.
+func (w *viCmd) put(r viRegister, count int, after bool, buf *tk.CodeBuffer) {
+	s, dot := buf.Content, buf.Dot
+	if r.linewise {
+		text := strings.Repeat(r.text+"\n", count)
+		if after {
+			buf.Dot = viEOL(s, dot)
+			buf.InsertAtDot("\n" + text[:len(text)-1])
+			buf.Dot = viEOL(s, dot) + 1
+		} else {
+			buf.Dot = viSOL(s, dot)
+			buf.InsertAtDot(text)
+			buf.Dot = viSOL(s, dot)
+		}
+		buf.Dot = viFirstNonBlank(buf.Content, buf.Dot)
+		return
+	}
+	text := strings.Repeat(r.text, count)
+	if after && dot < viEOL(s, dot) {
+		buf.Dot = viNextRune(s, dot)
+	}
+	buf.InsertAtDot(text)
+	if text != "" {
+		buf.Dot = viPrevRune(buf.Content, buf.Dot)
+	}
+}
+
+// Executes a command in the visual state, and returns whether the insert
+// state should be entered.
+func (w *viCmd) execVisual(c viCommand, buf *tk.CodeBuffer) bool {
+	s := buf.Content
+	sel := w.selection(*buf)
+	from, to := sel.From, sel.To
+	switch c.name {
+	case "o":
+		w.anchor, buf.Dot = buf.Dot, w.anchor
+		return false
+	case "v":
+		w.visual = false
+		return false
+	case "d", "x", "c", "s", "y", "D", "X", "C", "S", "Y":
+		w.visual = false
+		linewise := strings.ToUpper(c.name) == c.name
+		if linewise {
+			to = viPrevRune(s, to)
+		}
+		op := rune(c.name[0])
+		switch op {
+		case 'x', 'X':
+			op = 'd'
+		case 's', 'S', 'C':
+			op = 'c'
+		case 'D':
+			op = 'd'
+		case 'Y':
+			op = 'y'
+		}
+		return w.applyOperator(op, c.reg, from, to, linewise, buf)
+	case "~":
+		w.visual = false
+		buf.Content = s[:from] + toggleCase(s[from:to]) + s[to:]
+		buf.Dot = from
+		return false
+	case "r":
+		w.visual = false
+		buf.Content = s[:from] + strings.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return c.arg
+		}, s[from:to]) + s[to:]
+		buf.Dot = from
+		return false
+	case "J":
+		w.visual = false
+		buf.Dot = from
+		viJoinLines(buf, strings.Count(s[from:to], "\n")+1)
+		return false
+	case "p", "P":
+		w.visual = false
+		r, ok := w.Registers.get(c.reg)
+		if !ok {
+			return false
+		}
+		buf.Content = s[:from] + s[to:]
+		buf.Dot = from
+		w.put(r, viCount(c.count), false, buf)
+		// Like in Vi, the replaced text goes into the unnamed register.
+		w.Registers.store(0, s[from:to], false, false)
+		return false
+	}
+	if len(c.name) == 2 && (c.name[0] == 'i' || c.name[0] == 'a') {
+		from, to, ok := getViTextObject(rune(c.name[0]), rune(c.name[1]))(s, buf.Dot, viCount(c.count))
+		if ok && from < to {
+			w.anchor, buf.Dot = from, viPrevRune(s, to)
+		}
+		return false
+	}
+	w.moveDot(c, buf)
+	return false
+}
+
+// Returns the region selected in the visual state, which includes the
+// characters under both the anchor and the dot.
+func (w *viCmd) selection(buf tk.CodeBuffer) tk.Selection {
+	from, to := w.anchor, buf.Dot
+	if from > to {
+		from, to = to, from
+	}
+	if to > len(buf.Content) {
+		to = len(buf.Content)
+	}
+	if from > to {
+		from = to
+	}
+	if to < len(buf.Content) {
+		to = viNextRune(buf.Content, to)
+	}
+	return tk.Selection{From: from, To: to}
+}
+
+// Joins count lines starting from the line of the dot, with at least two lines
+// joined.
+func viJoinLines(buf *tk.CodeBuffer, count int) {
+	if count < 2 {
+		count = 2
+	}
+	s := buf.Content
+	for n := 1; n < count; n++ {
+		eol := viEOL(s, buf.Dot)
+		if eol == len(s) {
+			break
+		}
+		next := eol + 1
+		for next < len(s) && isSpaceByte(s[next]) {
+			next++
+		}
+		sep := " "
+		if next == len(s) || s[next] == '\n' || s[next] == ')' {
+			sep = ""
+		}
+		s = s[:eol] + sep + s[next:]
+		buf.Dot = eol
+	}
+	buf.Content = s
+}
+
+// Moves the dot off the end of a non-empty line, since the dot is always on a
+// character in the normal state.
+func viClampDot(buf *tk.CodeBuffer) {
+	s, dot := buf.Content, buf.Dot
+	if dot == viEOL(s, dot) && dot > viSOL(s, dot) {
+		buf.Dot = viPrevRune(s, dot)
+	}
+}
+
+func viCount(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// Stores text into a register. Besides the named register, the text is always
+// stored in the unnamed register ", and yanked text is also stored in the
+// register 0. Writing to an uppercase register appends to the corresponding
+// lowercase register, and the register _ discards the text.
+func (r *ViRegisters) store(reg rune, text string, linewise, yank bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reg == '_' {
+		return
+	}
+	if r.regs == nil {
+		r.regs = make(map[rune]viRegister)
+	}
+	value := viRegister{text, linewise}
+	if 'A' <= reg && reg <= 'Z' {
+		reg = unicode.ToLower(reg)
+		if old, ok := r.regs[reg]; ok {
+			sep := ""
+			if old.linewise || linewise {
+				sep = "\n"
+			}
+			value = viRegister{old.text + sep + text, old.linewise || linewise}
+		}
+	}
+	if reg != 0 {
+		r.regs[reg] = value
+	}
+	r.regs['"'] = value
+	if yank && reg == 0 {
+		r.regs['0'] = value
+	}
+}
+
+func (r *ViRegisters) get(reg rune) (viRegister, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reg == 0 {
+		reg = '"'
+	}
+	value, ok := r.regs[unicode.ToLower(reg)]
+	return value, ok
+}
+
+// Finishes the insertion in progress, recording the inserted text for the .
+// command, and repeating it if the command that entered the insert state had
+// a count.
+func (r *ViRegisters) finishInsert(buf *tk.CodeBuffer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ins := r.insert
+	r.insert = nil
+	if ins == nil {
+		return
+	}
+	before, from := ins.from.Content, ins.from.Dot
+	s := buf.Content
+	to := len(s) - (len(before) - from)
+	if to < from || buf.Dot != to ||
+		s[:from] != before[:from] || s[to:] != before[from:] {
+		// The buffer was changed in a way other than inserting text at the
+		// dot, so the change cannot be repeated.
+		if r.lastChange == ins.change {
+			r.lastChange = nil
+		}
+		return
+	}
+	text := s[from:to]
+	ins.change.inserted = text
+	for n := 1; n < ins.repeat; n++ {
+		buf.InsertAtDot(ins.sep + text)
+	}
+}
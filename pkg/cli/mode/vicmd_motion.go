@@ -0,0 +1,470 @@
+package mode
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli/tk"
+)
+
+// Motions and text objects of the vi command mode. All positions are byte
+// indices into the buffer.
+
+type viMotion struct {
+	// Moves the dot count times. The arg argument is only meaningful when
+	// takesArg is true. Returns the new dot and whether the motion succeeded.
+	move func(s string, dot, count int, arg rune) (int, bool)
+	// Whether an operator applied with this motion includes the character at
+	// the target.
+	inclusive bool
+	// Whether an operator applied with this motion works on whole lines.
+	linewise bool
+	// Whether the motion takes a character argument, like f.
+	takesArg bool
+	// Whether the motion should be called with a count of 0 when no count is
+	// given, instead of 1.
+	rawCount bool
+}
+
+var viMotions = map[string]viMotion{
+	"h": {move: viLeft},
+	"l": {move: viRight},
+	"0": {move: func(s string, dot, _ int, _ rune) (int, bool) { return viSOL(s, dot), true }},
+	"^": {move: func(s string, dot, _ int, _ rune) (int, bool) { return viFirstNonBlank(s, dot), true }},
+	"$": {move: viEOLMotion},
+
+	"w": {move: viWordMotion(viNextWord, tk.CategorizeSmallWord)},
+	"W": {move: viWordMotion(viNextWord, categorizeBigWord)},
+	"b": {move: viWordMotion(viPrevWord, tk.CategorizeSmallWord)},
+	"B": {move: viWordMotion(viPrevWord, categorizeBigWord)},
+	"e": {move: viWordMotion(viWordEnd, tk.CategorizeSmallWord), inclusive: true},
+	"E": {move: viWordMotion(viWordEnd, categorizeBigWord), inclusive: true},
+
+	"f": {move: viFindMotion('f', false), inclusive: true, takesArg: true},
+	"t": {move: viFindMotion('t', false), inclusive: true, takesArg: true},
+	"F": {move: viFindMotion('F', false), takesArg: true},
+	"T": {move: viFindMotion('T', false), takesArg: true},
+
+	"j":  {move: viLineMotion(1), linewise: true},
+	"k":  {move: viLineMotion(-1), linewise: true},
+	"G":  {move: viGotoLine(-1), linewise: true, rawCount: true},
+	"gg": {move: viGotoLine(1), linewise: true, rawCount: true},
+}
+
+// Returns the motion for the find command with the given name (one of f, t, F
+// and T). When repeat is true, a t or T motion does not get stuck before the
+// character it has just found.
+func viFindMotion(name rune, repeat bool) func(string, int, int, rune) (int, bool) {
+	return func(s string, dot, count int, arg rune) (int, bool) {
+		sol, eol := viSOL(s, dot), viEOL(s, dot)
+		forward := name == 'f' || name == 't'
+		till := name == 't' || name == 'T'
+		i := dot
+		if till && repeat {
+			// Skip over the character that the previous search found.
+			if forward && i < eol {
+				i = viNextRune(s, i)
+			} else if !forward && i > sol {
+				i = viPrevRune(s, i)
+			}
+		}
+		for n := 0; n < count; n++ {
+			var j int
+			if forward {
+				if i >= eol {
+					return dot, false
+				}
+				j = strings.IndexRune(s[viNextRune(s, i):eol], arg)
+				if j == -1 {
+					return dot, false
+				}
+				i = viNextRune(s, i) + j
+			} else {
+				j = strings.LastIndex(s[sol:i], string(arg))
+				if j == -1 {
+					return dot, false
+				}
+				i = sol + j
+			}
+		}
+		if till {
+			if forward {
+				i = viPrevRune(s, i)
+			} else {
+				i = viNextRune(s, i)
+			}
+		}
+		return i, true
+	}
+}
+
+func viLeft(s string, dot, count int, _ rune) (int, bool) {
+	sol := viSOL(s, dot)
+	i := dot
+	for n := 0; n < count && i > sol; n++ {
+		i = viPrevRune(s, i)
+	}
+	return i, i != dot
+}
+
+func viRight(s string, dot, count int, _ rune) (int, bool) {
+	eol := viEOL(s, dot)
+	i := dot
+	for n := 0; n < count && i < eol; n++ {
+		i = viNextRune(s, i)
+	}
+	return i, i != dot
+}
+
+func viEOLMotion(s string, dot, count int, _ rune) (int, bool) {
+	i := viEOL(s, dot)
+	for n := 1; n < count; n++ {
+		if i == len(s) {
+			return dot, false
+		}
+		i = viEOL(s, i+1)
+	}
+	return i, true
+}
+
+func viWordMotion(f func(string, int, func(rune) int) int, categorize func(rune) int) func(string, int, int, rune) (int, bool) {
+	return func(s string, dot, count int, _ rune) (int, bool) {
+		i := dot
+		for n := 0; n < count; n++ {
+			i = f(s, i, categorize)
+		}
+		return i, i != dot
+	}
+}
+
+func viNextWord(s string, i int, categorize func(rune) int) int {
+	if i == len(s) {
+		return i
+	}
+	if cat := categorizeRune(s, i, categorize); cat != 0 {
+		for i < len(s) && categorizeRune(s, i, categorize) == cat {
+			i = viNextRune(s, i)
+		}
+	}
+	for i < len(s) && categorizeRune(s, i, categorize) == 0 {
+		i = viNextRune(s, i)
+	}
+	return i
+}
+
+func viPrevWord(s string, i int, categorize func(rune) int) int {
+	for i > 0 && categorizeRune(s, viPrevRune(s, i), categorize) == 0 {
+		i = viPrevRune(s, i)
+	}
+	if i == 0 {
+		return i
+	}
+	cat := categorizeRune(s, viPrevRune(s, i), categorize)
+	for i > 0 && categorizeRune(s, viPrevRune(s, i), categorize) == cat {
+		i = viPrevRune(s, i)
+	}
+	return i
+}
+
+func viWordEnd(s string, i int, categorize func(rune) int) int {
+	if i == len(s) {
+		return i
+	}
+	j := viNextRune(s, i)
+	for j < len(s) && categorizeRune(s, j, categorize) == 0 {
+		j = viNextRune(s, j)
+	}
+	if j == len(s) {
+		return i
+	}
+	cat := categorizeRune(s, j, categorize)
+	for {
+		k := viNextRune(s, j)
+		if k == len(s) || categorizeRune(s, k, categorize) != cat {
+			return j
+		}
+		j = k
+	}
+}
+
+func viLineMotion(delta int) func(string, int, int, rune) (int, bool) {
+	return func(s string, dot, count int, _ rune) (int, bool) {
+		col := utf8.RuneCountInString(s[viSOL(s, dot):dot])
+		sol := viSOL(s, dot)
+		for n := 0; n < count; n++ {
+			if delta > 0 {
+				eol := viEOL(s, sol)
+				if eol == len(s) {
+					break
+				}
+				sol = eol + 1
+			} else {
+				if sol == 0 {
+					break
+				}
+				sol = viSOL(s, sol-1)
+			}
+		}
+		if sol == viSOL(s, dot) {
+			return dot, false
+		}
+		return viColumn(s, sol, col), true
+	}
+}
+
+// Returns a motion that goes to the line given by the count, or the line given
+// by defaultLine if the count is 0. A negative defaultLine counts from the last
+// line.
+func viGotoLine(defaultLine int) func(string, int, int, rune) (int, bool) {
+	return func(s string, dot, count int, _ rune) (int, bool) {
+		if count == 0 {
+			count = defaultLine
+		}
+		if count < 0 {
+			count = strings.Count(s, "\n") + 1 + count + 1
+		}
+		sol := 0
+		for n := 1; n < count; n++ {
+			eol := viEOL(s, sol)
+			if eol == len(s) {
+				break
+			}
+			sol = eol + 1
+		}
+		return viFirstNonBlank(s, sol), true
+	}
+}
+
+// A text object returns the region [from, to) it covers. The count argument
+// is always positive.
+type viTextObject func(s string, dot, count int) (from, to int, ok bool)
+
+// Returns the text object named by the given kind ('i' or 'a') and the given
+// name, or nil if there is no such text object.
+func getViTextObject(kind, name rune) viTextObject {
+	inner := kind == 'i'
+	switch name {
+	case 'w':
+		return viWordObject(inner, tk.CategorizeSmallWord)
+	case 'W':
+		return viWordObject(inner, categorizeBigWord)
+	case '"', '\'', '`':
+		return viQuoteObject(inner, byte(name))
+	case '(', ')', 'b':
+		return viBracketObject(inner, '(', ')')
+	case '[', ']':
+		return viBracketObject(inner, '[', ']')
+	case '{', '}', 'B':
+		return viBracketObject(inner, '{', '}')
+	case '<', '>':
+		return viBracketObject(inner, '<', '>')
+	}
+	return nil
+}
+
+func viWordObject(inner bool, categorize func(rune) int) viTextObject {
+	return func(s string, dot, count int) (int, int, bool) {
+		sol, eol := viSOL(s, dot), viEOL(s, dot)
+		if dot == eol {
+			return 0, 0, false
+		}
+		// Returns the end of the run of characters with the same category
+		// starting at i.
+		runEnd := func(i int) int {
+			cat := categorizeRune(s, i, categorize)
+			for i < eol && categorizeRune(s, i, categorize) == cat {
+				i = viNextRune(s, i)
+			}
+			return i
+		}
+		cat := categorizeRune(s, dot, categorize)
+		from := dot
+		for from > sol && categorizeRune(s, viPrevRune(s, from), categorize) == cat {
+			from = viPrevRune(s, from)
+		}
+		to := runEnd(dot)
+		if inner {
+			for n := 1; n < count && to < eol; n++ {
+				to = runEnd(to)
+			}
+			return from, to, true
+		}
+		isSpaceAt := func(i int) bool { return i < eol && categorizeRune(s, i, categorize) == 0 }
+		switch {
+		case cat == 0:
+			// On whitespace, "aw" covers the whitespace and the word after it.
+			if to < eol {
+				to = runEnd(to)
+			}
+		case isSpaceAt(to):
+			to = runEnd(to)
+		default:
+			// No trailing whitespace; include the leading whitespace instead.
+			for from > sol && categorizeRune(s, viPrevRune(s, from), categorize) == 0 {
+				from = viPrevRune(s, from)
+			}
+		}
+		for n := 1; n < count && to < eol; n++ {
+			to = runEnd(to)
+			if isSpaceAt(to) {
+				to = runEnd(to)
+			}
+		}
+		return from, to, true
+	}
+}
+
+func viQuoteObject(inner bool, quote byte) viTextObject {
+	return func(s string, dot, _ int) (int, int, bool) {
+		sol, eol := viSOL(s, dot), viEOL(s, dot)
+		var quotes []int
+		for i := sol; i < eol; i++ {
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quotes = append(quotes, i)
+			}
+		}
+		for i := 0; i+1 < len(quotes); i += 2 {
+			open, close := quotes[i], quotes[i+1]
+			if dot > close {
+				continue
+			}
+			if inner {
+				return open + 1, close, true
+			}
+			from, to := open, close+1
+			if to < eol && isSpaceByte(s[to]) {
+				for to < eol && isSpaceByte(s[to]) {
+					to++
+				}
+			} else {
+				for from > sol && isSpaceByte(s[from-1]) {
+					from--
+				}
+			}
+			return from, to, true
+		}
+		return 0, 0, false
+	}
+}
+
+func viBracketObject(inner bool, open, close byte) viTextObject {
+	return func(s string, dot, count int) (int, int, bool) {
+		var from int
+		switch {
+		case dot < len(s) && s[dot] == open:
+			from = dot
+		case dot < len(s) && s[dot] == close:
+			from = viFindOpen(s, dot-1, open, close)
+		default:
+			from = viFindOpen(s, dot, open, close)
+		}
+		for n := 1; n < count && from != -1; n++ {
+			from = viFindOpen(s, from-1, open, close)
+		}
+		if from == -1 {
+			return 0, 0, false
+		}
+		to := viFindClose(s, from+1, open, close)
+		if to == -1 {
+			return 0, 0, false
+		}
+		if inner {
+			return from + 1, to, true
+		}
+		return from, to + 1, true
+	}
+}
+
+// Finds the unmatched open bracket at or before i.
+func viFindOpen(s string, i int, open, close byte) int {
+	depth := 0
+	for ; i >= 0; i-- {
+		switch s[i] {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// Finds the unmatched close bracket at or after i.
+func viFindClose(s string, i int, open, close byte) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func categorizeBigWord(r rune) int {
+	if unicode.IsSpace(r) {
+		return 0
+	}
+	return 1
+}
+
+func categorizeRune(s string, i int, categorize func(rune) int) int {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return categorize(r)
+}
+
+func isSpaceByte(b byte) bool { return b == ' ' || b == '\t' }
+
+func viPrevRune(s string, i int) int {
+	_, n := utf8.DecodeLastRuneInString(s[:i])
+	return i - n
+}
+
+func viNextRune(s string, i int) int {
+	_, n := utf8.DecodeRuneInString(s[i:])
+	return i + n
+}
+
+// Returns the start of the line i is on.
+func viSOL(s string, i int) int {
+	return strings.LastIndexByte(s[:i], '\n') + 1
+}
+
+// Returns the end of the line i is on, which is the position of the newline
+// character or the end of the buffer.
+func viEOL(s string, i int) int {
+	if j := strings.IndexByte(s[i:], '\n'); j != -1 {
+		return i + j
+	}
+	return len(s)
+}
+
+func viFirstNonBlank(s string, i int) int {
+	i, eol := viSOL(s, i), viEOL(s, i)
+	for i < eol && isSpaceByte(s[i]) {
+		i++
+	}
+	return i
+}
+
+// Returns the position of the col-th rune on the line starting at sol, or the
+// end of the line if it is not long enough.
+func viColumn(s string, sol, col int) int {
+	eol := viEOL(s, sol)
+	i := sol
+	for n := 0; n < col && i < eol; n++ {
+		i = viNextRune(s, i)
+	}
+	return i
+}
@@ -0,0 +1,290 @@
+package mode
+
+import (
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/ui"
+)
+
+// In the following tests, buffers are written with a "|" marking the dot. In
+// the keys, "\x1b" stands for Esc, and keys pressed in the insert state are
+// inserted into the buffer.
+var viCmdTests = []struct {
+	name   string
+	before string
+	keys   string
+	after  string
+}{
+	// Motions.
+	{"h", "fo|o", "h", "f|oo"},
+	{"h at start of line", "a\n|b", "h", "a\n|b"},
+	{"l with count", "|foo bar", "3l", "foo| bar"},
+	{"l at end of line", "fo|o", "l", "fo|o"},
+	{"$", "|foo\nbar", "$", "fo|o\nbar"},
+	{"0", "foo b|ar", "0", "|foo bar"},
+	{"^", "  fo|o", "^", "  |foo"},
+	{"w", "|foo.bar baz", "w", "foo|.bar baz"},
+	{"w with count", "|foo.bar baz", "2w", "foo.|bar baz"},
+	{"W", "|foo.bar baz", "W", "foo.bar |baz"},
+	{"b", "foo ba|r", "b", "foo |bar"},
+	{"B", "foo a.b|c", "B", "foo |a.bc"},
+	{"e", "|foo bar", "e", "fo|o bar"},
+	{"e at end of word", "fo|o bar", "e", "foo ba|r"},
+	{"f", "|foo bar", "fa", "foo b|ar"},
+	{"f not found", "|foo bar", "fx", "|foo bar"},
+	{"t", "|foo bar", "ta", "foo |bar"},
+	{"F", "foo ba|r", "Fo", "fo|o bar"},
+	{"T", "foo ba|r", "To", "foo| bar"},
+	{"; after f", "|foo boo", "fo;", "fo|o boo"},
+	{"; after t", "|xoxo", "to;", "xo|xo"},
+	{", after f", "|foo boo", "fo;;;,", "foo b|oo"},
+	{"j", "f|oo\nbar", "j", "foo\nb|ar"},
+	{"j to shorter line", "fo|o\nb", "j", "foo\n|b"},
+	{"k", "foo\nb|ar", "k", "f|oo\nbar"},
+	{"G", "|a\nb\nc", "G", "a\nb\n|c"},
+	{"G with count", "|a\nb\nc", "2G", "a\n|b\nc"},
+	{"gg", "a\nb\n|c", "gg", "|a\nb\nc"},
+
+	// Operators with motions.
+	{"dw", "|foo bar", "dw", "|bar"},
+	{"dw at last word of line", "foo |bar\nbaz", "dw", "foo| \nbaz"},
+	{"dw with count", "|a b c", "2dw", "|c"},
+	{"dw with count after operator", "|a b c", "d2w", "|c"},
+	{"de", "|foo bar", "de", "| bar"},
+	{"db", "foo |bar", "db", "|bar"},
+	{"d$", "f|oo bar", "d$", "|f"},
+	{"d0", "foo b|ar", "d0", "|ar"},
+	{"df", "|foo bar", "dfa", "|r"},
+	{"dt", "|foo bar", "dta", "|ar"},
+	{"dd", "foo\n|bar\nbaz", "dd", "foo\n|baz"},
+	{"dd on last line", "foo\n|bar", "dd", "|foo"},
+	{"dd with count", "|a\nb\nc", "2dd", "|c"},
+	{"dj", "|a\nb\nc", "dj", "|c"},
+	{"d with failed motion", "|foo", "dfx", "|foo"},
+	{"Esc cancels pending command", "|ab", "d\x1bx", "|b"},
+	{"invalid command", "|ab", "dzx", "|b"},
+
+	// Operators with text objects.
+	{"diw", "foo b|ar baz", "diw", "foo | baz"},
+	{"daw", "foo b|ar baz", "daw", "foo |baz"},
+	{"daw at last word", "foo b|ar", "daw", "fo|o"},
+	{"daw on whitespace", "foo| bar baz", "daw", "foo| baz"},
+	{"diW", "a b.|c d", "diW", "a | d"},
+	{`di"`, `echo "fo|o bar"`, `di"`, `echo "|"`},
+	{`da"`, `echo "fo|o" x`, `da"`, `echo |x`},
+	{`di" before quotes`, `|echo "foo"`, `di"`, `echo "|"`},
+	{"di(", "(a (b|) c)", "di(", "(a (|) c)"},
+	{"da(", "(a (b|) c)", "da(", "(a | c)"},
+	{"di( with count", "(a (b|) c)", "2di(", "(|)"},
+	{"di[ on bracket", "x |[a b]", "di[", "x [|]"},
+	{"ci{", "{|foo}", "ci{bar\x1b", "{ba|r}"},
+
+	// Simple commands.
+	{"x", "|foo", "x", "|oo"},
+	{"x with count", "|foo bar", "3x", "| bar"},
+	{"x at end of line", "fo|o", "x", "f|o"},
+	{"X", "fo|o", "X", "f|o"},
+	{"D", "f|oo", "D", "|f"},
+	{"r", "|foo", "rx", "|xoo"},
+	{"r with count", "|foo", "3rx", "xx|x"},
+	{"r past end of line", "|foo", "4rx", "|foo"},
+	{"~", "|foo", "~", "F|oo"},
+	{"J", "|foo\n  bar", "J", "foo| bar"},
+
+	// Yanking and putting.
+	{"yy and p", "|foo", "yyp", "foo\n|foo"},
+	{"yy and P", "|foo\nbar", "jyyP", "foo\n|bar\nbar"},
+	{"yw and P", "|foo bar", "ywP", "foo| foo bar"},
+	{"x and p", "|ab", "xp", "b|a"},
+	{"p with count", "|ab", "x3p", "baa|a"},
+	{"named register", "|foo bar", `"aywdw"aP`, "foo| bar"},
+	{"appending to named register", "|a b", `"ayww"Ayw$"ap`, "a ba |b"},
+	{"black hole register", "|a b", `yw"_dwP`, "a| b"},
+	{"yank register", "|ab cd", `ywwdw"0p`, "ab ab| "},
+
+	// Changes and the insert state.
+	{"cw", "|foo bar", "cwxyz\x1b", "xy|z bar"},
+	{"cw at end of word", "fo|o bar", "cwx\x1b", "fo|x bar"},
+	{"cw on whitespace", "foo| bar", "cwx\x1b", "foo|xbar"},
+	{"cc", "foo\n|bar", "ccx\x1b", "foo\n|x"},
+	{"C", "f|oo", "Cx\x1b", "f|x"},
+	{"s", "|foo", "sx\x1b", "|xoo"},
+	{"S", "|foo", "Sx\x1b", "|x"},
+	{"i", "f|oo", "ix\x1b", "f|xoo"},
+	{"i with count", "|", "3ia\x1b", "aa|a"},
+	{"a", "f|oo", "ax\x1b", "fo|xo"},
+	{"I", "  fo|o", "Ix\x1b", "  |xfoo"},
+	{"A", "f|oo", "Ax\x1b", "foo|x"},
+	{"o", "|foo", "ox\x1b", "foo\n|x"},
+	{"O", "|foo", "Ox\x1b", "|x\nfoo"},
+	{"o with count", "|foo", "2ox\x1b", "foo\nx\n|x"},
+
+	// Repeating with ".".
+	{". after dw", "|a b c", "dw.", "|c"},
+	{". after x with new count", "|abcde", "x3.", "|e"},
+	{". after cw", "|foo bar", "cwxyz\x1bw.", "xyz xy|z"},
+	{". after i with count", "|", "2ia\x1b.", "aa|aa"},
+	{". after p", "|ab", "xp.", "ba|a"},
+	{". without change", "|ab", ".", "|ab"},
+
+	// The visual state.
+	{"v and d", "|abcd", "vlld", "|d"},
+	{"v with backward motion", "fo|o", "vhd", "|f"},
+	{"v and c", "|abc", "vlcx\x1b", "|xc"},
+	{"v and y", "|ab cd", "vly$p", "ab cda|b"},
+	{"v and ~", "|abc", "vl~", "|ABc"},
+	{"v and o", "a|bc", "vlohd", "|"},
+	{"v and iw", "foo b|ar baz", "viwd", "foo | baz"},
+	{"v and p", "|ab cd", "yiwwviwp", "ab a|b"},
+	{"v and r", "|abc", "vlrx", "|xxc"},
+	{"v and J", "|a\nb\nc", "vjJ", "a| b\nc"},
+	{"v and D", "a\n|b\nc", "vD", "a\n|c"},
+	{"Esc leaves visual", "|abc", "vl\x1bx", "a|c"},
+	{"v leaves visual", "|abc", "vlvx", "a|c"},
+}
+
+func TestViCmd_Commands(t *testing.T) {
+	for _, test := range viCmdTests {
+		t.Run(test.name, func(t *testing.T) {
+			f := Setup()
+			defer f.Stop()
+			spec := ViCmdSpec{Registers: &ViRegisters{}}
+			startViCmd(f.App, spec)
+			f.App.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+				s.Buffer = parseViBuffer(test.before)
+			})
+			feedViKeys(f.App, spec, test.keys)
+			got := f.App.CodeArea().CopyState().Buffer
+			if want := parseViBuffer(test.after); got != want {
+				t.Errorf("got buffer %q, want %q", showViBuffer(got), test.after)
+			}
+		})
+	}
+}
+
+func TestViCmd_Rendering(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "abc", Dot: 3}
+	}))
+	defer f.Stop()
+
+	startViCmd(f.App, ViCmdSpec{})
+	f.TestTTY(t,
+		"ab", term.DotHere, "c\n",
+		" NORMAL ", Styles,
+		"********",
+	)
+
+	f.TTY.Inject(term.K('2'), term.K('d'))
+	f.TestTTY(t,
+		"ab", term.DotHere, "c\n",
+		" NORMAL  2d", Styles,
+		"******** ",
+	)
+
+	f.TTY.Inject(term.K('[', ui.Ctrl), term.K('0'), term.K('v'), term.K('l'))
+	f.TestTTY(t,
+		"a", Styles,
+		"+", term.DotHere, "b", Styles,
+		"+", "c\n",
+		" VISUAL ", Styles,
+		"********",
+	)
+	f.TTY.Inject(term.K('[', ui.Ctrl))
+	f.TestTTY(t,
+		"a", term.DotHere, "bc\n",
+		" NORMAL ", Styles,
+		"********",
+	)
+}
+
+func TestViCmd_Visual(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w := NewViCmd(f.App, ViCmdSpec{})
+	if w.Visual() {
+		t.Errorf("Visual() -> true initially")
+	}
+	w.Handle(term.K('v'))
+	if !w.Visual() {
+		t.Errorf("Visual() -> false after v")
+	}
+}
+
+func TestViCmd_BindingsTakePrecedence(t *testing.T) {
+	f := Setup(WithSpec(func(spec *cli.AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "abc", Dot: 0}
+	}))
+	defer f.Stop()
+
+	called := 0
+	w := NewViCmd(f.App, ViCmdSpec{Bindings: tk.MapBindings{
+		term.K('x'): func(tk.Widget) { called++ },
+	}})
+	w.Handle(term.K('x'))
+	// Bindings are not consulted when there is a pending command.
+	w.Handle(term.K('d'))
+	w.Handle(term.K('x'))
+	w.Handle(term.K('x'))
+
+	if called != 2 {
+		t.Errorf("binding called %d times, want 2", called)
+	}
+	if content := f.App.CodeArea().CopyState().Buffer.Content; content != "abc" {
+		t.Errorf("content changed to %q", content)
+	}
+}
+
+func TestViCmd_UnhandledKeys(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w := NewViCmd(f.App, ViCmdSpec{})
+	if w.Handle(term.K(ui.Enter)) {
+		t.Errorf("Enter handled")
+	}
+	if !w.Handle(term.K('[', ui.Ctrl)) {
+		t.Errorf("Esc not handled")
+	}
+}
+
+func startViCmd(app cli.App, spec ViCmdSpec) {
+	app.SetAddon(NewViCmd(app, spec), false)
+	app.Redraw()
+}
+
+// Feeds keys to the current ViCmd addon. When there is no addon, which means
+// that the insert state has been entered, keys are inserted into the buffer,
+// and "\x1b" starts a new ViCmd addon.
+func feedViKeys(app cli.App, spec ViCmdSpec, keys string) {
+	for _, r := range keys {
+		addon, ok := app.CopyState().Addon.(ViCmd)
+		switch {
+		case ok && r == '\x1b':
+			addon.Handle(term.K('[', ui.Ctrl))
+		case ok:
+			addon.Handle(term.K(r))
+		case r == '\x1b':
+			startViCmd(app, spec)
+		default:
+			app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+				s.Buffer.InsertAtDot(string(r))
+			})
+		}
+	}
+}
+
+func parseViBuffer(s string) tk.CodeBuffer {
+	dot := strings.IndexByte(s, '|')
+	return tk.CodeBuffer{Content: s[:dot] + s[dot+1:], Dot: dot}
+}
+
+func showViBuffer(b tk.CodeBuffer) string {
+	return b.Content[:b.Dot] + "|" + b.Content[b.Dot:]
+}
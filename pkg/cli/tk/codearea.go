@@ -21,6 +21,23 @@ type CodeArea interface {
 	MutateState(f func(*CodeAreaState))
 	// Submit triggers the OnSubmit callback.
 	Submit()
+	// Undo reverts the last change to the buffer, and returns whether there
+	// was any change to revert.
+	Undo() bool
+	// Redo reapplies the last change reverted by Undo, and returns whether
+	// there was any change to reapply.
+	Redo() bool
+	// ClearUndo forgets all the changes that can be undone or redone.
+	ClearUndo()
+}
+
+// Diagnostic may be implemented by errors returned by the Highlighter of
+// CodeAreaSpec, for problems in a part of the code, like a reference to a
+// variable that does not exist. Unlike other errors, which are always shown, a
+// Diagnostic is only shown when the dot is within its range.
+type Diagnostic interface {
+	error
+	DiagnosticRange() (from, to int)
 }
 
 // CodeAreaSpec specifies the configuration and initial state for CodeArea.
@@ -28,8 +45,9 @@ type CodeAreaSpec struct {
 	// Key bindings.
 	Bindings Bindings
 	// A function that highlights the given code and returns any errors it has
-	// found when highlighting. If this function is not given, the Widget does
-	// not highlight the code nor show any errors.
+	// found when highlighting. Errors that implement Diagnostic are only shown
+	// when the dot is within their ranges. If this function is not given, the
+	// Widget does not highlight the code nor show any errors.
 	Highlighter func(code string) (ui.Text, []error)
 	// Prompt callback.
 	Prompt func() ui.Text
@@ -40,6 +58,15 @@ type CodeAreaSpec struct {
 	// expand any abbreviations.
 	Abbreviations          func(f func(abbr, full string))
 	SmallWordAbbreviations func(f func(abbr, full string))
+	// A function that returns a suggested full content for the given code,
+	// such as a command from history that starts with it. If this function is
+	// not given, the Widget does not show any suggestions.
+	Autosuggester func(code string) string
+	// A function that returns a line of information about the code at the
+	// dot, such as the signature of the command being typed, shown under the
+	// code and errors. If this function is not given, the Widget does not show
+	// any status line.
+	StatusLine func(code string, dot int) ui.Text
 	// A function that returns whether pasted texts (from bracketed pastes)
 	// should be quoted. If this function is not given, the Widget defaults to
 	// not quoting pasted texts.
@@ -53,9 +80,12 @@ type CodeAreaSpec struct {
 
 // CodeAreaState keeps the mutable state of the CodeArea widget.
 type CodeAreaState struct {
-	Buffer      CodeBuffer
-	Pending     PendingCode
-	HideRPrompt bool
+	Buffer             CodeBuffer
+	Pending            PendingCode
+	HideRPrompt        bool
+	HideAutosuggestion bool
+	HideStatusLine     bool
+	Selection          Selection
 }
 
 // CodeBuffer represents the buffer of the CodeArea widget.
@@ -79,6 +109,17 @@ type PendingCode struct {
 	Content string
 }
 
+// Selection represents a selected region of the buffer, such as in the visual
+// state of the vi command mode.
+type Selection struct {
+	// Beginning index of the selected region, as a byte index into
+	// CodeBuffer.Content.
+	From int
+	// End index of the selected region, as a byte index into
+	// CodeBuffer.Content.
+	To int
+}
+
 // ApplyPending applies pending code to the code buffer, and resets pending code.
 func (s *CodeAreaState) ApplyPending() {
 	s.Buffer, _, _ = patchPending(s.Buffer, s.Pending)
@@ -107,6 +148,16 @@ type codeArea struct {
 	pasting bool
 	// Buffer for keeping Pasted text during bracketed pasting.
 	pasteBuffer bytes.Buffer
+
+	// Buffers before the changes that can be undone, and after the changes
+	// that can be redone. Both are protected by StateMutex.
+	undoStack []CodeBuffer
+	redoStack []CodeBuffer
+	// Whether the last recorded change was an insertion of typed text, and
+	// the buffer after it. Used for grouping consecutive insertions into one
+	// change.
+	lastChangeIsInsert bool
+	lastChangeBuffer   CodeBuffer
 }
 
 // NewCodeArea creates a new CodeArea from the given spec.
@@ -129,6 +180,12 @@ func NewCodeArea(spec CodeAreaSpec) CodeArea {
 	if spec.SmallWordAbbreviations == nil {
 		spec.SmallWordAbbreviations = func(func(a, f string)) {}
 	}
+	if spec.Autosuggester == nil {
+		spec.Autosuggester = func(string) string { return "" }
+	}
+	if spec.StatusLine == nil {
+		spec.StatusLine = func(string, int) ui.Text { return nil }
+	}
 	if spec.QuotePaste == nil {
 		spec.QuotePaste = func() bool { return false }
 	}
@@ -169,7 +226,9 @@ func (w *codeArea) Handle(event term.Event) bool {
 func (w *codeArea) MutateState(f func(*CodeAreaState)) {
 	w.StateMutex.Lock()
 	defer w.StateMutex.Unlock()
+	before := w.State.Buffer
 	f(&w.State)
+	w.recordChange(before, false)
 }
 
 func (w *codeArea) CopyState() CodeAreaState {
@@ -178,6 +237,65 @@ func (w *codeArea) CopyState() CodeAreaState {
 	return w.State
 }
 
+func (w *codeArea) Undo() bool {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	if len(w.undoStack) == 0 {
+		return false
+	}
+	w.redoStack = append(w.redoStack, w.State.Buffer)
+	w.restoreBuffer(w.undoStack[len(w.undoStack)-1])
+	w.undoStack = w.undoStack[:len(w.undoStack)-1]
+	w.lastChangeIsInsert = false
+	return true
+}
+
+func (w *codeArea) Redo() bool {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	if len(w.redoStack) == 0 {
+		return false
+	}
+	w.undoStack = append(w.undoStack, w.State.Buffer)
+	w.restoreBuffer(w.redoStack[len(w.redoStack)-1])
+	w.redoStack = w.redoStack[:len(w.redoStack)-1]
+	w.lastChangeIsInsert = false
+	return true
+}
+
+// Replaces the buffer with one from the undo or redo stack. The pending code
+// and the selection refer to positions in the old buffer, so they are cleared.
+// This function assumes that the state mutex is already being held.
+func (w *codeArea) restoreBuffer(b CodeBuffer) {
+	w.State.Buffer = b
+	w.State.Pending = PendingCode{}
+	w.State.Selection = Selection{}
+}
+
+func (w *codeArea) ClearUndo() {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	w.undoStack, w.redoStack = nil, nil
+	w.lastChangeIsInsert = false
+}
+
+// Records a change to the buffer from before to the current buffer, if the
+// content has changed. An insertion of typed text is merged into the last
+// change if that was also one and nothing has happened since. This function
+// assumes that the state mutex is already being held.
+func (w *codeArea) recordChange(before CodeBuffer, insert bool) {
+	after := w.State.Buffer
+	if after.Content == before.Content {
+		return
+	}
+	if !(insert && w.lastChangeIsInsert && before == w.lastChangeBuffer) {
+		w.undoStack = append(w.undoStack, before)
+	}
+	w.redoStack = nil
+	w.lastChangeIsInsert = insert
+	w.lastChangeBuffer = after
+}
+
 func (w *codeArea) resetInserts() {
 	w.inserts = ""
 	w.lastCodeBuffer = CodeBuffer{}
@@ -321,12 +439,14 @@ func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 			// reset the state.
 			w.resetInserts()
 		}
+		before := w.State.Buffer
 		s := string(key.Rune)
 		w.State.Buffer.InsertAtDot(s)
 		w.inserts += s
 		w.lastCodeBuffer = w.State.Buffer
 		w.expandSimpleAbbr()
 		w.expandWordAbbr(key.Rune, CategorizeSmallWord)
+		w.recordChange(before, true)
 		return true
 	}
 }
@@ -1,6 +1,8 @@
 package tk
 
 import (
+	"strings"
+
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/ui"
 	"src.elv.sh/pkg/wcwidth"
@@ -13,19 +15,39 @@ type view struct {
 	code    ui.Text
 	dot     int
 	errors  []error
+	status  ui.Text
 }
 
-var stylingForPending = ui.Underlined
+var (
+	stylingForPending    = ui.Underlined
+	stylingForSelection  = ui.Inverse
+	stylingForSuggestion = ui.Dim
+)
 
 func getView(w *codeArea) *view {
 	s := w.CopyState()
 	code, pFrom, pTo := patchPending(s.Buffer, s.Pending)
 	styledCode, errors := w.Highlighter(code.Content)
+	errors = errorsAtDot(errors, code.Dot)
 	if pFrom < pTo {
 		// Apply stylingForPending to [pFrom, pTo)
 		parts := styledCode.Partition(pFrom, pTo)
 		pending := ui.StyleText(parts[1], stylingForPending)
 		styledCode = ui.Concat(parts[0], pending, parts[2])
+	} else if sel := s.Selection; 0 <= sel.From && sel.From < sel.To && sel.To <= len(code.Content) {
+		// Apply stylingForSelection to [sel.From, sel.To). The selection is
+		// not shown when there is pending code, since the indices would be
+		// off.
+		parts := styledCode.Partition(sel.From, sel.To)
+		selected := ui.StyleText(parts[1], stylingForSelection)
+		styledCode = ui.Concat(parts[0], selected, parts[2])
+	} else if !s.HideAutosuggestion && code.Dot == len(code.Content) && code.Content != "" {
+		// Show the part of the suggestion after the code. Suggestions are
+		// only shown when the dot is at the end of the code.
+		if sugg := w.Autosuggester(code.Content); len(sugg) > len(code.Content) && strings.HasPrefix(sugg, code.Content) {
+			rest := ui.T(sugg[len(code.Content):], stylingForSuggestion)
+			styledCode = ui.Concat(styledCode, rest)
+		}
 	}
 
 	var rprompt ui.Text
@@ -33,7 +55,27 @@ func getView(w *codeArea) *view {
 		rprompt = w.RPrompt()
 	}
 
-	return &view{w.Prompt(), rprompt, styledCode, code.Dot, errors}
+	var status ui.Text
+	if !s.HideStatusLine {
+		status = w.StatusLine(code.Content, code.Dot)
+	}
+
+	return &view{w.Prompt(), rprompt, styledCode, code.Dot, errors, status}
+}
+
+// Filters out the errors that are diagnostics for parts of the code not
+// containing the dot.
+func errorsAtDot(errors []error, dot int) []error {
+	var filtered []error
+	for _, err := range errors {
+		if d, ok := err.(Diagnostic); ok {
+			if from, to := d.DiagnosticRange(); dot < from || dot > to {
+				continue
+			}
+		}
+		filtered = append(filtered, err)
+	}
+	return filtered
 }
 
 func patchPending(c CodeBuffer, p PendingCode) (CodeBuffer, int, int) {
@@ -93,6 +135,11 @@ func renderView(v *view, buf *term.BufferBuilder) {
 			buf.Write(err.Error())
 		}
 	}
+
+	if len(v.status) > 0 {
+		buf.Newline()
+		buf.WriteStyled(v.status)
+	}
 }
 
 func truncateToHeight(b *term.Buffer, maxHeight int) {
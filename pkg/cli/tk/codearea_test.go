@@ -2,6 +2,7 @@ package tk
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -101,6 +102,38 @@ var codeAreaRenderTests = []renderTest{
 		Want: bb(10).Write("> code").SetDotHere().
 			Newline().Write("static error"),
 	},
+	{
+		Name: "diagnostic at the dot",
+		Given: NewCodeArea(CodeAreaSpec{
+			Highlighter: func(code string) (ui.Text, []error) {
+				return ui.T(code), []error{testDiagnostic{0, 2}, testDiagnostic{3, 4}}
+			},
+			State: CodeAreaState{Buffer: CodeBuffer{Content: "code", Dot: 2}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere().Write("de").
+			Newline().Write("diag 0-2"),
+	},
+	{
+		Name: "status line",
+		Given: NewCodeArea(CodeAreaSpec{
+			StatusLine: func(code string, dot int) ui.Text {
+				return ui.T(fmt.Sprintf("status %d", dot), ui.Bold)
+			},
+			State: CodeAreaState{Buffer: CodeBuffer{Content: "code", Dot: 2}}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere().Write("de").
+			Newline().WriteStringSGR("status 2", "1"),
+	},
+	{
+		Name: "status line hidden",
+		Given: NewCodeArea(CodeAreaSpec{
+			StatusLine: func(code string, dot int) ui.Text { return ui.T("status") },
+			State: CodeAreaState{
+				Buffer:         CodeBuffer{Content: "code", Dot: 4},
+				HideStatusLine: true}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("code").SetDotHere(),
+	},
 	{
 		Name: "pending code inserting at the dot",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -156,6 +189,67 @@ var codeAreaRenderTests = []renderTest{
 		Width: 10, Height: 24,
 		Want: bb(10).Write("code").SetDotHere(),
 	},
+	{
+		Name: "selection",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer:    CodeBuffer{Content: "code", Dot: 2},
+			Selection: Selection{From: 1, To: 3},
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("c").WriteStringSGR("o", "7").SetDotHere().
+			WriteStringSGR("d", "7").Write("e"),
+	},
+	{
+		Name: "ignore selection with pending code",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer:    CodeBuffer{Content: "code", Dot: 4},
+			Pending:   PendingCode{From: 4, To: 4, Content: "x"},
+			Selection: Selection{From: 1, To: 3},
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("code").WriteStringSGR("x", "4").SetDotHere(),
+	},
+	{
+		Name: "autosuggestion",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return code + "de" },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "co", Dot: 2},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere().WriteStringSGR("de", "2"),
+	},
+	{
+		Name: "ignore autosuggestion when dot is not at the end",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return code + "de" },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "co", Dot: 1},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("c").SetDotHere().Write("o"),
+	},
+	{
+		Name: "ignore autosuggestion not starting with the code",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return "xyz" },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "co", Dot: 2},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere(),
+	},
+	{
+		Name: "hide autosuggestion",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return code + "de" },
+			State: CodeAreaState{
+				Buffer:             CodeBuffer{Content: "co", Dot: 2},
+				HideAutosuggestion: true,
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere(),
+	},
 	{
 		Name: "prioritize lines before the cursor with small height",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -448,6 +542,81 @@ func TestCodeArea_State(t *testing.T) {
 	}
 }
 
+func TestCodeArea_Undo(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{})
+	testBuffer := func(want CodeBuffer) {
+		t.Helper()
+		if got := w.CopyState().Buffer; got != want {
+			t.Errorf("got buffer %v, want %v", got, want)
+		}
+	}
+
+	if w.Undo() {
+		t.Errorf("Undo() -> true when there is nothing to undo")
+	}
+	// Consecutive inserts are grouped into one change.
+	w.Handle(term.K('a'))
+	w.Handle(term.K('b'))
+	// Each call to MutateState that changes the content is one change.
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot(" cd") })
+	// Changes to the dot alone are not recorded, but they end the grouping of
+	// inserts.
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.Dot = 0 })
+	w.Handle(term.K('x'))
+	w.Handle(term.K('y'))
+	testBuffer(CodeBuffer{Content: "xyab cd", Dot: 2})
+
+	w.Undo()
+	testBuffer(CodeBuffer{Content: "ab cd", Dot: 0})
+	w.Undo()
+	testBuffer(CodeBuffer{Content: "ab", Dot: 2})
+	w.Redo()
+	testBuffer(CodeBuffer{Content: "ab cd", Dot: 0})
+	w.Undo()
+	w.Undo()
+	testBuffer(CodeBuffer{Content: "", Dot: 0})
+	if w.Undo() {
+		t.Errorf("Undo() -> true when there is nothing to undo")
+	}
+
+	// A new change discards the changes that can be redone.
+	w.Handle(term.K('z'))
+	if w.Redo() {
+		t.Errorf("Redo() -> true after a new change")
+	}
+	testBuffer(CodeBuffer{Content: "z", Dot: 1})
+
+	w.ClearUndo()
+	if w.Undo() {
+		t.Errorf("Undo() -> true after ClearUndo")
+	}
+}
+
+func TestCodeArea_UndoAndRedoClearPendingAndSelection(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{})
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot("echo foo") })
+	setPendingAndSelection := func() {
+		w.MutateState(func(s *CodeAreaState) {
+			s.Pending = PendingCode{From: 5, To: 8, Content: "foobar"}
+			s.Selection = Selection{From: 0, To: 4}
+		})
+	}
+	testState := func(want CodeAreaState) {
+		t.Helper()
+		if got := w.CopyState(); got != want {
+			t.Errorf("got state %v, want %v", got, want)
+		}
+	}
+
+	setPendingAndSelection()
+	w.Undo()
+	testState(CodeAreaState{})
+
+	setPendingAndSelection()
+	w.Redo()
+	testState(CodeAreaState{Buffer: CodeBuffer{Content: "echo foo", Dot: 8}})
+}
+
 func TestCodeAreaState_ApplyPending(t *testing.T) {
 	applyPending := func(s CodeAreaState) CodeAreaState {
 		s.ApplyPending()
@@ -466,3 +635,9 @@ func TestCodeAreaState_ApplyPending(t *testing.T) {
 			Rets(CodeAreaState{Buffer: CodeBuffer{Content: "x", Dot: 1}, HideRPrompt: true}),
 	})
 }
+
+type testDiagnostic struct{ from, to int }
+
+func (d testDiagnostic) Error() string { return fmt.Sprintf("diag %d-%d", d.from, d.to) }
+
+func (d testDiagnostic) DiagnosticRange() (int, int) { return d.from, d.to }
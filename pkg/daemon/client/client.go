@@ -112,6 +112,13 @@ func (c *client) AddCmd(text string) (int, error) {
 	return res.Seq, err
 }
 
+func (c *client) AddCmds(texts []string, infos map[int]storedefs.CmdInfo) (int, error) {
+	req := &api.AddCmdsRequest{Texts: texts, Infos: infos}
+	res := &api.AddCmdsResponse{}
+	err := c.call("AddCmds", req, res)
+	return res.Seq, err
+}
+
 func (c *client) DelCmd(seq int) error {
 	req := &api.DelCmdRequest{Seq: seq}
 	res := &api.DelCmdResponse{}
@@ -147,6 +154,26 @@ func (c *client) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 	return storedefs.Cmd{Text: res.Text, Seq: res.Seq}, err
 }
 
+func (c *client) SetCmdInfo(seq int, info storedefs.CmdInfo) error {
+	req := &api.SetCmdInfoRequest{Seq: seq, Info: info}
+	res := &api.SetCmdInfoResponse{}
+	return c.call("SetCmdInfo", req, res)
+}
+
+func (c *client) CmdInfo(seq int) (storedefs.CmdInfo, error) {
+	req := &api.CmdInfoRequest{Seq: seq}
+	res := &api.CmdInfoResponse{}
+	err := c.call("CmdInfo", req, res)
+	return res.Info, err
+}
+
+func (c *client) CmdInfos(from, upto int) (map[int]storedefs.CmdInfo, error) {
+	req := &api.CmdInfosRequest{From: from, Upto: upto}
+	res := &api.CmdInfosResponse{}
+	err := c.call("CmdInfos", req, res)
+	return res.Infos, err
+}
+
 func (c *client) AddDir(dir string, incFactor float64) error {
 	req := &api.AddDirRequest{Dir: dir, IncFactor: incFactor}
 	res := &api.AddDirResponse{}
@@ -54,6 +54,7 @@ func TestDaemon(t *testing.T) {
 
 	// Store requests.
 	storetest.TestCmd(t, client)
+	storetest.TestCmdInfo(t, client)
 	storetest.TestDir(t, client)
 	storetest.TestSharedVar(t, client)
 }
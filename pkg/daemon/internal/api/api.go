@@ -7,7 +7,7 @@ import (
 )
 
 // Version is the API version. It should be bumped any time the API changes.
-const Version = -93
+const Version = -91
 
 // ServiceName is the name of the RPC service exposed by the daemon.
 const ServiceName = "Daemon"
@@ -42,6 +42,15 @@ type AddCmdResponse struct {
 	Seq int
 }
 
+type AddCmdsRequest struct {
+	Texts []string
+	Infos map[int]storedefs.CmdInfo
+}
+
+type AddCmdsResponse struct {
+	Seq int
+}
+
 type DelCmdRequest struct {
 	Seq int
 }
@@ -95,6 +104,30 @@ type PrevCmdResponse struct {
 	Text string
 }
 
+type SetCmdInfoRequest struct {
+	Seq  int
+	Info storedefs.CmdInfo
+}
+
+type SetCmdInfoResponse struct{}
+
+type CmdInfoRequest struct {
+	Seq int
+}
+
+type CmdInfoResponse struct {
+	Info storedefs.CmdInfo
+}
+
+type CmdInfosRequest struct {
+	From int
+	Upto int
+}
+
+type CmdInfosResponse struct {
+	Infos map[int]storedefs.CmdInfo
+}
+
 // Dir requests.
 
 type AddDirRequest struct {
@@ -48,6 +48,15 @@ func (s *service) AddCmd(req *api.AddCmdRequest, res *api.AddCmdResponse) error
 	return err
 }
 
+func (s *service) AddCmds(req *api.AddCmdsRequest, res *api.AddCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	seq, err := s.store.AddCmds(req.Texts, req.Infos)
+	res.Seq = seq
+	return err
+}
+
 func (s *service) DelCmd(req *api.DelCmdRequest, res *api.DelCmdResponse) error {
 	if s.err != nil {
 		return s.err
@@ -92,6 +101,31 @@ func (s *service) PrevCmd(req *api.PrevCmdRequest, res *api.PrevCmdResponse) err
 	return err
 }
 
+func (s *service) SetCmdInfo(req *api.SetCmdInfoRequest, res *api.SetCmdInfoResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetCmdInfo(req.Seq, req.Info)
+}
+
+func (s *service) CmdInfo(req *api.CmdInfoRequest, res *api.CmdInfoResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	info, err := s.store.CmdInfo(req.Seq)
+	res.Info = info
+	return err
+}
+
+func (s *service) CmdInfos(req *api.CmdInfosRequest, res *api.CmdInfosResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	infos, err := s.store.CmdInfos(req.From, req.Upto)
+	res.Infos = infos
+	return err
+}
+
 func (s *service) AddDir(req *api.AddDirRequest, res *api.AddDirResponse) error {
 	if s.err != nil {
 		return s.err
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/wcwidth"
 )
@@ -53,6 +54,13 @@ func (c *Context) RelevantString() string {
 	return c.Source[c.From:c.To]
 }
 
+// Position returns the 1-based line and column numbers of the start of the
+// range. The column number counts codepoints.
+func (c *Context) Position() (line, col int) {
+	info := c.showInfo()
+	return info.BeginLine, utf8.RuneCountInString(info.Head) + 1
+}
+
 func (c *Context) showInfo() *rangeShowInfo {
 	if c.savedShowInfo != nil {
 		return c.savedShowInfo
@@ -70,6 +70,23 @@ func TestContext(t *testing.T) {
 	}
 }
 
+func TestContext_Position(t *testing.T) {
+	for _, test := range []struct {
+		context   *Context
+		line, col int
+	}{
+		{parseContext("echo (bad)", "(", ")", true), 1, 6},
+		{parseContext("echo\n  (bad)", "(", ")", true), 2, 3},
+		{parseContext("中文 (bad)", "(", ")", true), 1, 4},
+	} {
+		line, col := test.context.Position()
+		if line != test.line || col != test.col {
+			t.Errorf("Position() -> (%v, %v), want (%v, %v)",
+				line, col, test.line, test.col)
+		}
+	}
+}
+
 // Parse a string into a source range, using the first appearance of certain
 // texts as start and end positions.
 func parseContext(s, starter, ender string, endAfter bool) *Context {
@@ -0,0 +1,231 @@
+package edit
+
+// Implementation of autosuggestions from command history.
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+//elvdoc:var autosuggest
+//
+// Whether to show autosuggestions, defaults to `$true`.
+//
+// When this is on and the dot is at the end of the code buffer, the editor
+// finds the most recent command in history that starts with the content of the
+// code buffer, and shows the rest of it dimmed after the dot. Commands that
+// were run in the current directory are preferred over more recent ones run
+// elsewhere. The history is searched in the background, so a suggestion may
+// show up slightly after the keystroke that triggers it.
+//
+// @cf edit:accept-autosuggestion edit:accept-autosuggestion-word
+
+//elvdoc:fn accept-autosuggestion
+//
+// Replaces the content of the code buffer with the autosuggestion, and moves
+// the dot to the end. Does nothing if no autosuggestion is shown.
+//
+// By default, this function is called before `edit:move-dot-right` and
+// `edit:move-dot-eol` in the <kbd>Right</kbd> and <kbd>End</kbd> bindings of
+// insert mode.
+//
+// @cf edit:autosuggest edit:accept-autosuggestion-word
+
+//elvdoc:fn accept-autosuggestion-word
+//
+// Like `edit:accept-autosuggestion`, but only accepts the autosuggestion up to
+// the beginning of the next word.
+//
+// By default, this function is called before `edit:move-dot-right-word` in
+// the <kbd>Alt-f</kbd> binding of insert mode.
+//
+// @cf edit:autosuggest edit:accept-autosuggestion
+
+const autosuggesterLatesBufferSize = 1
+
+// How many of the most recent matching commands are searched for one run in
+// the current directory.
+const autosuggestDirLookback = 20
+
+func initAutosuggest(appSpec *cli.AppSpec, ed *Editor, hs histutil.Store, st storedefs.Store, nb eval.NsBuilder) {
+	enabled := newBoolVar(true)
+	as := newAutosuggester(
+		func() bool { return enabled.Get().(bool) },
+		func(code string, stale func() bool) string {
+			return suggestFromHistory(hs, st, code, stale)
+		})
+	appSpec.Autosuggester = as
+	nb.Add("autosuggest", enabled)
+
+	accept := func(f func(buf tk.CodeBuffer, sugg string) string) func() {
+		return func() {
+			ed.app.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+				buf := s.Buffer
+				if buf.Dot != len(buf.Content) || s.Pending != (tk.PendingCode{}) {
+					return
+				}
+				sugg := as.Get(buf.Content)
+				if len(sugg) <= len(buf.Content) {
+					return
+				}
+				content := f(buf, sugg)
+				s.Buffer = tk.CodeBuffer{Content: content, Dot: len(content)}
+			})
+		}
+	}
+	nb.AddGoFns("<edit>", map[string]interface{}{
+		"accept-autosuggestion": accept(func(_ tk.CodeBuffer, sugg string) string {
+			return sugg
+		}),
+		"accept-autosuggestion-word": accept(func(buf tk.CodeBuffer, sugg string) string {
+			return sugg[:moveDotRightWord(sugg, buf.Dot)]
+		}),
+	})
+}
+
+// Returns the most recent command in history that starts with, but is not
+// equal to the given code. If the store is available, the most recent such
+// command run in the current directory is preferred, as long as it is among the
+// first autosuggestDirLookback candidates. The search is abandoned when stale
+// returns true.
+func suggestFromHistory(hs histutil.Store, st storedefs.Store, code string, stale func() bool) string {
+	dir, _ := os.Getwd()
+	c := hs.Cursor(code)
+	var candidates []storedefs.Cmd
+	for len(candidates) < autosuggestDirLookback {
+		if stale() {
+			return ""
+		}
+		c.Prev()
+		cmd, err := c.Get()
+		if err != nil {
+			break
+		}
+		if cmd.Text == code {
+			continue
+		}
+		candidates = append(candidates, cmd)
+		if st == nil || dir == "" {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	if len(candidates) > 1 && !stale() {
+		// Look up the information of all the candidates at once. Candidates
+		// are found from the most recent, but their sequence numbers are not
+		// necessarily in order, so find the range that covers all of them.
+		from, upto := candidates[0].Seq, candidates[0].Seq+1
+		for _, cmd := range candidates[1:] {
+			if cmd.Seq < from {
+				from = cmd.Seq
+			} else if cmd.Seq >= upto {
+				upto = cmd.Seq + 1
+			}
+		}
+		infos, err := st.CmdInfos(from, upto)
+		if err == nil {
+			for _, cmd := range candidates {
+				if info, ok := infos[cmd.Seq]; ok && info.Dir == dir {
+					return cmd.Text
+				}
+			}
+		}
+	}
+	return candidates[0].Text
+}
+
+// An implementation of cli.Autosuggester that computes suggestions in the
+// background.
+//
+// At most one computation runs at a time. While it runs, only the code of the
+// last call to Get is remembered, and it is computed next; the computation in
+// progress is abandoned as soon as possible.
+type autosuggester struct {
+	enabled func() bool
+	suggest func(code string, stale func() bool) string
+	lates   chan struct{}
+
+	mu sync.Mutex
+	// The code of the last call to Get.
+	code string
+	// Whether there is a goroutine computing suggestions.
+	working bool
+	// The last computed suggestion, and the code it was computed for.
+	result    string
+	resultFor string
+}
+
+func newAutosuggester(enabled func() bool, suggest func(string, func() bool) string) *autosuggester {
+	return &autosuggester{
+		enabled: enabled, suggest: suggest,
+		lates: make(chan struct{}, autosuggesterLatesBufferSize)}
+}
+
+// Get returns the suggestion for the given code, starting a computation in the
+// background if there isn't one for the code already.
+//
+// While the computation is running, a suggestion computed for a prefix of the
+// code is returned if it starts with the code, since it is very likely to be
+// the suggestion for the code too. This keeps the suggestion shown while the
+// user is typing it.
+func (as *autosuggester) Get(code string) string {
+	if code == "" || !as.enabled() {
+		return ""
+	}
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	if code != as.code {
+		as.code = code
+		if !as.working {
+			as.working = true
+			go as.work(code)
+		}
+	}
+	if strings.HasPrefix(code, as.resultFor) && strings.HasPrefix(as.result, code) {
+		return as.result
+	}
+	return ""
+}
+
+// Computes suggestions until the result for the code of the last call to Get
+// is known.
+func (as *autosuggester) work(code string) {
+	for {
+		stale := func() bool {
+			as.mu.Lock()
+			defer as.mu.Unlock()
+			return as.code != code
+		}
+		result := as.suggest(code, stale)
+		as.mu.Lock()
+		if as.code != code {
+			// The code has changed since the computation was started.
+			code = as.code
+			as.mu.Unlock()
+			continue
+		}
+		as.result, as.resultFor = result, code
+		as.working = false
+		as.mu.Unlock()
+		break
+	}
+	select {
+	case as.lates <- struct{}{}:
+	default:
+		// An update is already pending.
+	}
+}
+
+// LateUpdates returns a channel for notifying late updates.
+func (as *autosuggester) LateUpdates() <-chan struct{} {
+	return as.lates
+}
@@ -0,0 +1,172 @@
+package edit
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestAutosuggest(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo hello world")
+		s.AddCmd("echo foo")
+		s.AddCmd("put")
+	}))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "echo h")
+	f.TestTTY(t,
+		"~> echo h", Styles,
+		"   vvvv  ", term.DotHere, "ello world", Styles,
+		"dddddddddd",
+	)
+
+	// Accept one word.
+	f.TTYCtrl.Inject(term.K('f', ui.Alt))
+	f.TestTTY(t,
+		"~> echo hello ", Styles,
+		"   vvvv       ", term.DotHere, "world", Styles,
+		"ddddd",
+	)
+
+	// Accept the rest.
+	f.TTYCtrl.Inject(term.K(ui.Right))
+	f.TestTTY(t,
+		"~> echo hello world", Styles,
+		"   vvvv            ", term.DotHere,
+	)
+}
+
+func TestAutosuggest_PrefersRecentCommands(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo hello")
+		s.AddCmd("echo foo")
+	}))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere, " foo", Styles,
+		"dddd",
+	)
+}
+
+func TestAutosuggest_PrefersCommandsInCurrentDirectory(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		wd, _ := os.Getwd()
+		seq, _ := s.AddCmd("echo hello")
+		s.SetCmdInfo(seq, storedefs.CmdInfo{Dir: wd})
+		seq, _ = s.AddCmd("echo foo")
+		s.SetCmdInfo(seq, storedefs.CmdInfo{Dir: "/elsewhere"})
+	}))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere, " hello", Styles,
+		"dddddd",
+	)
+}
+
+func TestAutosuggest_Disabled(t *testing.T) {
+	f := setup(
+		rc("edit:autosuggest = $false"),
+		storeOp(func(s storedefs.Store) { s.AddCmd("echo hello") }))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere,
+	)
+	// Right still moves the dot when there is no suggestion.
+	f.TTYCtrl.Inject(term.K(ui.Left), term.K(ui.Right), term.K(ui.Left))
+	f.TestTTY(t,
+		"~> ech", Styles,
+		"   vvv", term.DotHere, "o", Styles,
+		"v",
+	)
+}
+
+func TestAutosuggester_ComputesLatestCode(t *testing.T) {
+	var computed []string
+	gate := make(chan struct{})
+	as := newAutosuggester(func() bool { return true },
+		func(code string, stale func() bool) string {
+			computed = append(computed, code)
+			if code == "a" {
+				<-gate
+				if !stale() {
+					t.Errorf("stale() -> false after code has changed")
+				}
+			}
+			return code + "-suggestion"
+		})
+
+	as.Get("a")
+	as.Get("ab")
+	as.Get("abc")
+	close(gate)
+	select {
+	case <-as.LateUpdates():
+	case <-time.After(testutil.ScaledMs(1000)):
+		t.Fatal("timed out waiting for late update")
+	}
+
+	// The computation for "ab" is skipped, since "abc" was requested before the
+	// computation for "a" finished.
+	if wantComputed := []string{"a", "abc"}; !reflect.DeepEqual(computed, wantComputed) {
+		t.Errorf("computed %q, want %q", computed, wantComputed)
+	}
+	if got := as.Get("abc"); got != "abc-suggestion" {
+		t.Errorf("Get -> %q, want %q", got, "abc-suggestion")
+	}
+}
+
+func TestAutosuggest_AddingCommandsWhileTyping(t *testing.T) {
+	st, cleanup := store.MustGetTempStore()
+	defer cleanup()
+	hs, err := newHistStore(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := newAutosuggester(func() bool { return true },
+		func(code string, stale func() bool) string {
+			return suggestFromHistory(hs, st, code, stale)
+		})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			hs.AddCmd(storedefs.Cmd{Text: fmt.Sprintf("echo %d", i), Seq: -1})
+		}
+	}()
+	code := "echo "
+	for i := 0; i < 100; i++ {
+		as.Get(code + fmt.Sprint(i%10))
+	}
+	wg.Wait()
+
+	// Wait for the suggestion for new code, which takes all the commands into
+	// account.
+	for as.Get("echo 5") != "echo 59" {
+		select {
+		case <-as.LateUpdates():
+		case <-time.After(testutil.ScaledMs(1000)):
+			t.Fatal("timed out waiting for suggestion")
+		}
+	}
+}
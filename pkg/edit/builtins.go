@@ -195,6 +195,25 @@ func isSyntaxComplete(code string) bool {
 	return true
 }
 
+//elvdoc:fn undo
+//
+// Reverts the last change to the code buffer. Consecutively typed characters
+// are reverted together; every other change, including each call to
+// `edit:insert-at-dot` and `edit:replace-input` and each accepted completion,
+// is reverted individually. Does nothing if there is no change to revert.
+//
+// The changes are forgotten when the editor finishes reading a command.
+//
+// @cf edit:redo
+
+//elvdoc:fn redo
+//
+// Reapplies the last change reverted by `edit:undo`. Does nothing if there is
+// no change to reapply, or if the code buffer has been changed since the last
+// `edit:undo`.
+//
+// @cf edit:undo
+
 //elvdoc:fn wordify
 //
 //
@@ -233,6 +252,8 @@ func initMiscBuiltins(app cli.App, nb eval.NsBuilder) {
 		"return-line":    app.CommitCode,
 		"return-eof":     app.CommitEOF,
 		"smart-enter":    func() { smartEnter(app) },
+		"undo":           func() { app.CodeArea().Undo() },
+		"redo":           func() { app.CodeArea().Redo() },
 		"wordify":        wordify,
 	})
 }
@@ -252,16 +273,20 @@ var bufferBuiltinsData = map[string]func(*tk.CodeBuffer){
 	"move-dot-up":   makeMove(moveDotUp),
 	"move-dot-down": makeMove(moveDotDown),
 
-	"kill-rune-left":        makeKill(moveDotLeft),
-	"kill-rune-right":       makeKill(moveDotRight),
-	"kill-word-left":        makeKill(moveDotLeftWord),
-	"kill-word-right":       makeKill(moveDotRightWord),
-	"kill-small-word-left":  makeKill(moveDotLeftSmallWord),
-	"kill-small-word-right": makeKill(moveDotRightSmallWord),
-	"kill-left-alnum-word":  makeKill(moveDotLeftAlnumWord),
-	"kill-right-alnum-word": makeKill(moveDotRightAlnumWord),
-	"kill-line-left":        makeKill(moveDotSOL),
-	"kill-line-right":       makeKill(moveDotEOL),
+	"kill-rune-left":  makeKill(moveDotLeft),
+	"kill-rune-right": makeKill(moveDotRight),
+}
+
+// Kill builtins that add the killed text to the kill ring.
+var killRingBuiltinsData = map[string]pureMover{
+	"kill-word-left":        moveDotLeftWord,
+	"kill-word-right":       moveDotRightWord,
+	"kill-small-word-left":  moveDotLeftSmallWord,
+	"kill-small-word-right": moveDotRightSmallWord,
+	"kill-left-alnum-word":  moveDotLeftAlnumWord,
+	"kill-right-alnum-word": moveDotRightAlnumWord,
+	"kill-line-left":        moveDotSOL,
+	"kill-line-right":       moveDotEOL,
 }
 
 func initBufferBuiltins(app cli.App, nb eval.NsBuilder) {
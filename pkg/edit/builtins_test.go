@@ -239,6 +239,35 @@ func TestBufferBuiltins(t *testing.T) {
 	}
 }
 
+func TestUndoRedo(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+	testContent := func(want string) {
+		t.Helper()
+		if got := f.Editor.app.CodeArea().CopyState().Buffer.Content; got != want {
+			t.Errorf("got content %q, want %q", got, want)
+		}
+	}
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", Styles,
+		"   vvvv", term.DotHere)
+	evals(f.Evaler, "edit:insert-at-dot ' foo'", "edit:replace-input 'put bar'")
+	testContent("put bar")
+
+	evals(f.Evaler, "edit:undo")
+	testContent("echo foo")
+	evals(f.Evaler, "edit:undo")
+	testContent("echo")
+	// The typed characters are undone together.
+	evals(f.Evaler, "edit:undo")
+	testContent("")
+
+	evals(f.Evaler, "edit:redo", "edit:redo")
+	testContent("echo foo")
+}
+
 // Tests for pure movers.
 
 func TestMoveDotLeftRight(t *testing.T) {
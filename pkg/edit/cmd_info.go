@@ -0,0 +1,85 @@
+package edit
+
+// Recording of information about the execution of interactive commands.
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+//elvdoc:var session-id
+//
+// An opaque string identifying the current interactive session. It is stored
+// along with each command added to the history, and can be compared with the
+// `session` key of the output of `edit:command-history` to find commands run in
+// this session.
+//
+// This variable is read-only.
+//
+// @cf edit:command-history
+
+// Records information about the execution of commands added to the command
+// history.
+type cmdInfoRecorder struct {
+	st      storedefs.Store
+	session string
+
+	mu sync.Mutex
+	// The command added to the history in the last readline cycle, its sequence
+	// number, and the information known about it before it is executed. The
+	// code is cleared once the information is saved.
+	code string
+	seq  int
+	info storedefs.CmdInfo
+}
+
+func initCmdInfo(ed *Editor, st storedefs.Store, nb eval.NsBuilder) *cmdInfoRecorder {
+	r := &cmdInfoRecorder{st: st, session: newSessionID()}
+	nb.Add("session-id", vars.NewReadOnly(r.session))
+	ed.AfterCommand = append(ed.AfterCommand,
+		func(src parse.Source, duration float64, errCmd error) {
+			err := r.finish(src, duration, errCmd)
+			if err != nil {
+				ed.notifyError("command info", err)
+			}
+		})
+	return r
+}
+
+func newSessionID() string {
+	return strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Called when a command has been added to the history and is about to be
+// executed.
+func (r *cmdInfoRecorder) start(seq int, code string) {
+	dir, _ := os.Getwd()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.code, r.seq = code, seq
+	r.info = storedefs.CmdInfo{Dir: dir, Start: time.Now(), Session: r.session}
+}
+
+// Called when a command has finished executing. Saves the information about the
+// command if it was the one added to the history.
+func (r *cmdInfoRecorder) finish(src parse.Source, duration float64, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.st == nil || r.code == "" || src.Code != r.code {
+		return nil
+	}
+	info := r.info
+	info.Duration = time.Duration(duration * float64(time.Second))
+	if err != nil {
+		info.Exception = err.Error()
+	}
+	r.code = ""
+	return r.st.SetCmdInfo(r.seq, info)
+}
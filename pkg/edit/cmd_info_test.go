@@ -0,0 +1,60 @@
+package edit
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestCmdInfo_Recorded(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "false")
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	if code, err := f.Wait(); code != "false" || err != nil {
+		t.Fatalf("got (%q, %v), want (%q, nil)", code, err, "false")
+	}
+	f.Editor.RunAfterCommandHooks(
+		parse.Source{Name: "[tty]", Code: "false"}, 1.5, errors.New("failed"))
+
+	info, err := f.Store.CmdInfo(1)
+	if err != nil {
+		t.Fatalf("CmdInfo(1) => error %v", err)
+	}
+	wd, _ := os.Getwd()
+	if info.Dir != wd {
+		t.Errorf("got Dir %q, want %q", info.Dir, wd)
+	}
+	if info.Duration != 1500*time.Millisecond {
+		t.Errorf("got Duration %v, want 1.5s", info.Duration)
+	}
+	if info.Exception != "failed" {
+		t.Errorf("got Exception %q, want %q", info.Exception, "failed")
+	}
+	if time.Since(info.Start) > time.Minute {
+		t.Errorf("got Start %v, want a recent time", info.Start)
+	}
+	evals(f.Evaler, "session = $edit:session-id")
+	testGlobal(t, f.Evaler, "session", info.Session)
+}
+
+func TestCmdInfo_NotRecordedForFilteredCommands(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, " echo secret")
+	f.TTYCtrl.Inject(term.K(ui.Enter))
+	f.Wait()
+	f.Editor.RunAfterCommandHooks(
+		parse.Source{Name: "[tty]", Code: " echo secret"}, 1, nil)
+
+	if infos, err := f.Store.CmdInfos(0, 100); len(infos) != 0 || err != nil {
+		t.Errorf("CmdInfos => (%v, %v), want (empty, nil)", infos, err)
+	}
+}
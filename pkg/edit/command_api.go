@@ -3,37 +3,107 @@ package edit
 // Implementation of the editor "command" mode.
 
 import (
+	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/mode"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
 )
 
+//elvdoc:var vi-state
+//
+// The current state of the Vi emulation: `normal` or `visual` when command mode
+// is active, and `insert` otherwise. This is useful for showing the state in
+// the prompt; since the prompt is normally not updated on every keystroke, this
+// also requires setting `$edit:-prompt-eagerness` to 10:
+//
+// ```elvish
+// edit:prompt = { put '['$edit:vi-state'] ~> ' }
+// edit:-prompt-eagerness = 10
+// ```
+//
+// @cf edit:command:start
+
 //elvdoc:var command:binding
 //
-// Key bindings for command mode. This is currently a very small subset of Vi
-// command mode bindings.
+// Key bindings for command mode. When no command is pending, they take
+// precedence over the builtin Vi commands, so they can be used to override the
+// builtin commands or bind additional keys.
 //
 // @cf edit:command:start
 
 //elvdoc:fn command:start
 //
-// Enter command mode. This mode is intended to emulate Vi's command mode, but
-// it is very incomplete right now.
+// Enter command mode, which emulates the normal and visual states of Vi. To use
+// Vi-style editing, bind the Esc key in insert mode to this function:
+//
+// ```elvish
+// edit:insert:binding[Ctrl-'['] = $edit:command:start~
+// ```
+//
+// The following Vi commands are supported, all of which can be prefixed with a
+// count:
+//
+// -   Motions: `h`, `l`, `j`, `k`, `w`, `W`, `b`, `B`, `e`, `E`, `0`, `^`,
+//     `$`, `f`, `F`, `t`, `T`, `;`, `,`, `gg` and `G`.
 //
-// @cf edit:command:binding
+// -   Operators `d`, `c` and `y`, which can be followed by a motion, a text
+//     object, or the operator itself to work on whole lines. The text objects
+//     are `iw`, `aw`, `iW`, `aW`, and `i` or `a` followed by a quote (`"`,
+//     `'` or `` ` ``) or a bracket (`(`, `)`, `b`, `[`, `]`, `{`, `}`, `B`,
+//     `<` or `>`).
+//
+// -   Other commands: `x`, `X`, `s`, `S`, `D`, `C`, `Y`, `r`, `~`, `J`, `p`,
+//     `P`, and `i`, `a`, `I`, `A`, `o` and `O` for entering insert mode.
+//
+// -   `.` repeats the last change, including the text inserted if the change
+//     entered insert mode.
+//
+// -   `v` starts the visual state, in which motions extend the selection, and
+//     `d`, `x`, `c`, `s`, `y`, `p`, `P`, `r`, `~` and `J` work on the
+//     selection. `D`, `X`, `C`, `S` and `Y` work on the selected lines. `o`
+//     moves to the other end of the selection, and `v` or Esc goes back to the
+//     normal state.
+//
+// Undoing and redoing are available through the default bindings of `u` and
+// `Ctrl-R` to `edit:undo` and `edit:redo` in `$edit:command:binding`.
+//
+// Commands can be prefixed with `"` and a register name. The registers are
+// `a` to `z` (using the uppercase letter appends to the register), `0` (the
+// last yanked text), `_` (discards the text) and `"` (the unnamed register,
+// which is used when no register is specified). The registers are kept for the
+// entire session.
+//
+// @cf edit:command:binding edit:vi-state
 
 func initCommandAPI(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
+	registers := &mode.ViRegisters{}
+	nb.Add("vi-state", vars.FromGet(func() interface{} {
+		return viState(ed.app)
+	}))
 	nb.AddNs("command",
 		eval.NsBuilder{
 			"binding": bindingVar,
 		}.AddGoFns("<edit:command>:", map[string]interface{}{
 			"start": func() {
-				w := mode.NewStub(mode.StubSpec{
-					Bindings: bindings,
-					Name:     " COMMAND ",
+				w := mode.NewViCmd(ed.app, mode.ViCmdSpec{
+					Bindings:  bindings,
+					Registers: registers,
 				})
 				ed.app.SetAddon(w, false)
 			},
 		}).Ns())
 }
+
+func viState(app cli.App) string {
+	w, ok := app.CopyState().Addon.(mode.ViCmd)
+	switch {
+	case !ok:
+		return "insert"
+	case w.Visual():
+		return "visual"
+	default:
+		return "normal"
+	}
+}
@@ -15,10 +15,11 @@ func TestCommandMode(t *testing.T) {
 	feedInput(f.TTYCtrl, "echo")
 	f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
 	f.TestTTY(t,
-		"~> echo", Styles,
-		"   vvvv", term.DotHere, "\n",
-		" COMMAND ", Styles,
-		"*********",
+		"~> ech", Styles,
+		"   vvv", term.DotHere, "o", Styles,
+		"v", "\n",
+		" NORMAL ", Styles,
+		"********",
 	)
 
 	f.TTYCtrl.Inject(term.K('b'))
@@ -26,7 +27,53 @@ func TestCommandMode(t *testing.T) {
 		"~> ", term.DotHere,
 		"echo\n", Styles,
 		"vvvv",
-		" COMMAND ", Styles,
-		"*********",
+		" NORMAL ", Styles,
+		"********",
 	)
+
+	f.TTYCtrl.Inject(term.K('c'), term.K('w'))
+	f.TestTTY(t, "~> ", term.DotHere)
+	feedInput(f.TTYCtrl, "put")
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
+	f.TestTTY(t,
+		"~> pu", Styles,
+		"   vv", term.DotHere, "t", Styles,
+		"v", "\n",
+		" NORMAL ", Styles,
+		"********",
+	)
+}
+
+func TestCommandMode_Binding(t *testing.T) {
+	f := setup(rc(`edit:command:binding[x] = { edit:insert-at-dot X }`))
+	defer f.Cleanup()
+
+	evals(f.Evaler, `edit:insert:binding[Ctrl-'['] = $edit:command:start~`)
+	feedInput(f.TTYCtrl, "ab")
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl), term.K('x'))
+	f.TestTTY(t,
+		"~> aX", Styles,
+		"   !!", term.DotHere, "b", Styles,
+		"!", "\n",
+		" NORMAL ", Styles,
+		"********",
+	)
+}
+
+func TestViState(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	evals(f.Evaler, "s = $edit:vi-state")
+	testGlobal(t, f.Evaler, "s", "insert")
+	evals(f.Evaler, "edit:command:start", "s = $edit:vi-state")
+	testGlobal(t, f.Evaler, "s", "normal")
+	f.TTYCtrl.Inject(term.K('v'))
+	f.TestTTY(t,
+		"~> ", term.DotHere, "\n",
+		" VISUAL ", Styles,
+		"********",
+	)
+	evals(f.Evaler, "s = $edit:vi-state")
+	testGlobal(t, f.Evaler, "s", "visual")
 }
@@ -30,9 +30,16 @@ type Config struct {
 	// Used to generate candidates for a command argument. Defaults to
 	// Filenames.
 	ArgGenerator ArgGenerator
+	// Used to generate candidates for the name of an option. If nil, option
+	// names are not completed.
+	OptionGenerator OptionGenerator
+	// Used to generate candidates for the value of an option. Defaults to
+	// generating filenames.
+	OptionValueGenerator OptionValueGenerator
 }
 
-// Filterer is the type of functions that filter raw candidates.
+// Filterer is the type of functions that filter raw candidates. A Filterer
+// may wrap the raw candidates it keeps in RankedItem's to rank them.
 type Filterer func(ctxName, seed string, rawItems []RawItem) []RawItem
 
 // ArgGenerator is the type of functions that generate raw candidates for a
@@ -40,6 +47,16 @@ type Filterer func(ctxName, seed string, rawItems []RawItem) []RawItem
 // argument to complete, and returns raw candidates or an error.
 type ArgGenerator func(args []string) ([]RawItem, error)
 
+// OptionGenerator is the type of functions that generate raw candidates for
+// the name of an option. It takes the name of the command, and returns raw
+// candidates or an error. The candidates should not include the leading "&".
+type OptionGenerator func(cmd string) ([]RawItem, error)
+
+// OptionValueGenerator is the type of functions that generate raw candidates
+// for the value of an option. It takes the name of the command, the name of the
+// option and the value being completed, and returns raw candidates or an error.
+type OptionValueGenerator func(cmd, opt, seed string) ([]RawItem, error)
+
 // Result keeps the result of the completion algorithm.
 type Result struct {
 	Name    string
@@ -84,6 +101,9 @@ func Complete(code CodeBuffer, cfg Config) (*Result, error) {
 	if cfg.ArgGenerator == nil {
 		cfg.ArgGenerator = GenerateFileNames
 	}
+	if cfg.OptionValueGenerator == nil {
+		cfg.OptionValueGenerator = generateFileNamesForOption
+	}
 
 	// Ignore the error; the function always returns a valid *ChunkNode.
 	tree, _ := parse.Parse(parse.Source{Name: "[interactive]", Code: code.Content}, parse.Config{})
@@ -95,18 +115,40 @@ func Complete(code CodeBuffer, cfg Config) (*Result, error) {
 		}
 		rawItems = cfg.Filterer(ctx.name, ctx.seed, rawItems)
 		items := make([]mode.CompletionItem, len(rawItems))
+		scores := make([]float64, len(rawItems))
 		for i, rawCand := range rawItems {
 			items[i] = rawCand.Cook(ctx.quote)
+			if ranked, ok := rawCand.(RankedItem); ok {
+				scores[i] = ranked.Score
+			}
 		}
-		sort.Slice(items, func(i, j int) bool {
-			return items[i].ToShow < items[j].ToShow
-		})
+		// Sort by score first, then alphabetically.
+		sort.Sort(rankedItems{items, scores})
 		items = dedup(items)
 		return &Result{Name: ctx.name, Items: items, Replace: ctx.interval}, nil
 	}
 	return nil, errNoCompletion
 }
 
+type rankedItems struct {
+	items  []mode.CompletionItem
+	scores []float64
+}
+
+func (r rankedItems) Len() int { return len(r.items) }
+
+func (r rankedItems) Less(i, j int) bool {
+	if r.scores[i] != r.scores[j] {
+		return r.scores[i] > r.scores[j]
+	}
+	return r.items[i].ToShow < r.items[j].ToShow
+}
+
+func (r rankedItems) Swap(i, j int) {
+	r.items[i], r.items[j] = r.items[j], r.items[i]
+	r.scores[i], r.scores[j] = r.scores[j], r.scores[i]
+}
+
 func dedup(items []mode.CompletionItem) []mode.CompletionItem {
 	var result []mode.CompletionItem
 	for i, item := range items {
@@ -103,6 +103,16 @@ func TestComplete(t *testing.T) {
 		},
 	}
 
+	rankedCfg := Config{
+		PureEvaler: cfg.PureEvaler,
+		Filterer: func(ctxName, seed string, items []RawItem) []RawItem {
+			return []RawItem{items[0], RankedItem{items[1], 1}, RankedItem{items[2], 2}}
+		},
+		ArgGenerator: func([]string) ([]RawItem, error) {
+			return []RawItem{PlainItem("a"), PlainItem("b"), PlainItem("c")}, nil
+		},
+	}
+
 	dupCfg := Config{
 		PureEvaler: cfg.PureEvaler,
 		ArgGenerator: func([]string) ([]RawItem, error) {
@@ -110,6 +120,26 @@ func TestComplete(t *testing.T) {
 		},
 	}
 
+	dirCfg := Config{
+		PureEvaler:   cfg.PureEvaler,
+		ArgGenerator: GenerateDirNames,
+	}
+
+	optionCfg := Config{
+		PureEvaler: cfg.PureEvaler,
+		OptionGenerator: func(cmd string) ([]RawItem, error) {
+			if cmd != "f" {
+				return nil, nil
+			}
+			return []RawItem{PlainItem("foo"), PlainItem("bar")}, nil
+		},
+		OptionValueGenerator: func(cmd, opt, seed string) ([]RawItem, error) {
+			// Prepend the seed so that the item survives prefix filtering.
+			item := noQuoteItem(seed + fmt.Sprintf("%q", []string{cmd, opt}))
+			return []RawItem{item}, nil
+		},
+	}
+
 	allFileNameItems := []mode.CompletionItem{
 		fc("a.exe", " "), fc("d"+string(os.PathSeparator), ""), fc("non-exe", " "),
 	}
@@ -133,6 +163,15 @@ func TestComplete(t *testing.T) {
 				},
 			},
 			nil),
+		// Candidates are sorted by their scores.
+		Args(cb("ls "), rankedCfg).Rets(
+			&Result{
+				Name: "argument", Replace: r(3, 3),
+				Items: []mode.CompletionItem{
+					c("c"), c("b"), c("a"),
+				},
+			},
+			nil),
 		// Complete arguments using GenerateFileNames.
 		Args(cb("ls "), cfg).Rets(
 			&Result{
@@ -144,6 +183,12 @@ func TestComplete(t *testing.T) {
 				Name: "argument", Replace: r(3, 4),
 				Items: []mode.CompletionItem{fc("a.exe", " ")}},
 			nil),
+		// Complete arguments using GenerateDirNames.
+		Args(cb("ls "), dirCfg).Rets(
+			&Result{
+				Name: "argument", Replace: r(3, 3),
+				Items: []mode.CompletionItem{fc("d"+string(os.PathSeparator), "")}},
+			nil),
 		// GenerateForSudo completing external commands.
 		Args(cb("sudo "), cfg).Rets(
 			&Result{
@@ -167,6 +212,49 @@ func TestComplete(t *testing.T) {
 				Name: "argument", Replace: r(5, 6),
 				Items: []mode.CompletionItem{c(`[]string{"ls", "a", "b"}`)}},
 			nil),
+		// Complete option names.
+		Args(cb("f &"), optionCfg).Rets(
+			&Result{
+				Name: "option", Replace: r(3, 3),
+				Items: []mode.CompletionItem{c("bar"), c("foo")}},
+			nil),
+		Args(cb("f x &f"), optionCfg).Rets(
+			&Result{
+				Name: "option", Replace: r(5, 6),
+				Items: []mode.CompletionItem{c("foo")}},
+			nil),
+		// Complete option values.
+		Args(cb("f &foo="), optionCfg).Rets(
+			&Result{
+				Name: "option-value", Replace: r(7, 7),
+				Items: []mode.CompletionItem{c(`["f" "foo"]`)}},
+			nil),
+		Args(cb("f &foo=x"), optionCfg).Rets(
+			&Result{
+				Name: "option-value", Replace: r(7, 8),
+				Items: []mode.CompletionItem{c(`x["f" "foo"]`)}},
+			nil),
+		// Complete option values using filenames by default.
+		Args(cb("f &foo=a"), cfg).Rets(
+			&Result{
+				Name: "option-value", Replace: r(7, 8),
+				Items: []mode.CompletionItem{fc("a.exe", " ")}},
+			nil),
+		// Complete kinds after "kind" in a match form.
+		Args(cb("match $x $a kind "), cfg).Rets(
+			&Result{
+				Name: "kind", Replace: r(17, 17),
+				Items: []mode.CompletionItem{
+					c("bool"), c("exception"), c("file"), c("fn"), c("list"),
+					c("map"), c("nil"), c("ns"), c("number"), c("pipe"),
+					c("string"), c("structmap"),
+				}},
+			nil),
+		Args(cb("match $x $a kind s"), cfg).Rets(
+			&Result{
+				Name: "kind", Replace: r(17, 18),
+				Items: []mode.CompletionItem{c("string"), c("structmap")}},
+			nil),
 
 		// Complete commands at an empty buffer, generating special forms,
 		// externals, functions, namespaces and variable assignments.
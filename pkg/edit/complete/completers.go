@@ -11,10 +11,12 @@ import (
 var parent = parse.Parent
 
 var completers = []completer{
+	completeOption,
 	completeCommand,
 	completeIndex,
 	completeRedir,
 	completeVariable,
+	completeMatchKind,
 	completeArg,
 }
 
@@ -54,6 +56,120 @@ func completeArg(n parse.Node, cfg Config) (*context, []RawItem, error) {
 	return nil, nil, errNoCompletion
 }
 
+func completeOption(n parse.Node, cfg Config) (*context, []RawItem, error) {
+	ev := cfg.PureEvaler
+	generateNames := func(form *parse.Form, ctx *context) (*context, []RawItem, error) {
+		cmd, ok := ev.PurelyEvalCompound(form.Head)
+		if !ok || cfg.OptionGenerator == nil {
+			return nil, nil, errNoCompletion
+		}
+		items, err := cfg.OptionGenerator(cmd)
+		return ctx, items, err
+	}
+	generateValues := func(pair *parse.MapPair, ctx *context) (*context, []RawItem, error) {
+		form, ok := parent(pair).(*parse.Form)
+		if !ok || form.Head == nil {
+			return nil, nil, errNoCompletion
+		}
+		cmd, ok := ev.PurelyEvalCompound(form.Head)
+		if !ok {
+			return nil, nil, errNoCompletion
+		}
+		opt, ok := ev.PurelyEvalCompound(pair.Key)
+		if !ok {
+			return nil, nil, errNoCompletion
+		}
+		items, err := cfg.OptionValueGenerator(cmd, opt, ctx.seed)
+		return ctx, items, err
+	}
+
+	if sep, ok := n.(*parse.Sep); ok {
+		switch parent := parent(sep).(type) {
+		case *parse.Pipeline:
+			// A "&" at the end of a pipeline is parsed as the marker for
+			// running it in the background, but it may also be the beginning
+			// of an option.
+			if parent.Background && parse.SourceText(sep) == "&" {
+				if form := parent.Forms[len(parent.Forms)-1]; form.Head != nil {
+					// Case 1: just after "&".
+					return generateNames(form,
+						&context{"option", "", parse.Bareword, range0(sep.Range().To)})
+				}
+			}
+		case *parse.MapPair:
+			if parse.SourceText(sep) == "=" {
+				// Case 2: just after "=".
+				return generateValues(parent,
+					&context{"option-value", "", parse.Bareword, range0(sep.Range().To)})
+			}
+		}
+	}
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, seed := primaryInSimpleCompound(primary, ev); compound != nil {
+			if pair, ok := parent(compound).(*parse.MapPair); ok {
+				if form, ok := parent(pair).(*parse.Form); ok && form.Head != nil {
+					if pair.Key == compound {
+						// Case 3: in an incomplete option name.
+						return generateNames(form,
+							&context{"option", seed, primary.Type, compound.Range()})
+					}
+					// Case 4: in an incomplete option value.
+					return generateValues(pair,
+						&context{"option-value", seed, primary.Type, compound.Range()})
+				}
+			}
+		}
+	}
+	return nil, nil, errNoCompletion
+}
+
+// Kinds that can be used after "kind" in the arms of the "match" special form.
+var matchKinds = []string{
+	"bool", "exception", "file", "fn", "list", "map", "nil", "ns", "number",
+	"pipe", "string", "structmap",
+}
+
+func completeMatchKind(n parse.Node, cfg Config) (*context, []RawItem, error) {
+	ev := cfg.PureEvaler
+	generate := func(ctx *context) (*context, []RawItem, error) {
+		items := make([]RawItem, len(matchKinds))
+		for i, kind := range matchKinds {
+			items[i] = PlainItem(kind)
+		}
+		return ctx, items, nil
+	}
+	// Returns whether the argument starting at pos follows "kind" in a match
+	// form.
+	afterKind := func(form *parse.Form, pos int) bool {
+		if form.Head == nil || parse.SourceText(form.Head) != "match" {
+			return false
+		}
+		for i := len(form.Args) - 1; i >= 0; i-- {
+			if form.Args[i].Range().To <= pos {
+				return i > 0 && parse.SourceText(form.Args[i]) == "kind"
+			}
+		}
+		return false
+	}
+
+	if sep, ok := n.(*parse.Sep); ok {
+		if form, ok := parent(sep).(*parse.Form); ok && afterKind(form, sep.Range().To) {
+			// Starting a new argument after "kind".
+			return generate(&context{"kind", "", parse.Bareword, range0(sep.Range().To)})
+		}
+	}
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, seed := primaryInSimpleCompound(primary, ev); compound != nil {
+			if form, ok := parent(compound).(*parse.Form); ok && form.Head != compound &&
+				afterKind(form, compound.Range().From) {
+				// In an incomplete argument after "kind".
+				return generate(&context{"kind", seed, primary.Type, compound.Range()})
+			}
+		}
+	}
+	return nil, nil, errNoCompletion
+}
+
 func completeCommand(n parse.Node, cfg Config) (*context, []RawItem, error) {
 	ev := cfg.PureEvaler
 	generateForEmpty := func(pos int) (*context, []RawItem, error) {
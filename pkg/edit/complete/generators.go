@@ -22,6 +22,19 @@ func GenerateFileNames(args []string) ([]RawItem, error) {
 	return generateFileNames(args[len(args)-1], false)
 }
 
+// GenerateDirNames is like GenerateFileNames, but only generates directories
+// (and symlinks to directories).
+func GenerateDirNames(args []string) ([]RawItem, error) {
+	items, err := generateFileNames(args[len(args)-1], false)
+	var dirs []RawItem
+	for _, item := range items {
+		if strings.HasSuffix(item.String(), pathSeparator) {
+			dirs = append(dirs, item)
+		}
+	}
+	return dirs, err
+}
+
 // GenerateForSudo generates candidates for sudo.
 func GenerateForSudo(cfg Config, args []string) ([]RawItem, error) {
 	switch {
@@ -37,6 +50,11 @@ func GenerateForSudo(cfg Config, args []string) ([]RawItem, error) {
 
 // Internal generators, used from completers.
 
+// The default OptionValueGenerator.
+func generateFileNamesForOption(cmd, opt, seed string) ([]RawItem, error) {
+	return generateFileNames(seed, false)
+}
+
 func generateExternalCommands(seed string, ev PureEvaler) ([]RawItem, error) {
 	if fsutil.DontSearch(seed) {
 		// Completing a local external command name.
@@ -50,3 +50,10 @@ func (c ComplexItem) Cook(q parse.PrimaryType) mode.CompletionItem {
 		ShowStyle: c.DisplayStyle,
 	}
 }
+
+// RankedItem wraps a RawItem with a score. Items with higher scores are shown
+// first; items that are not RankedItem's have a score of 0.
+type RankedItem struct {
+	RawItem
+	Score float64
+}
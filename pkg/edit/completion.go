@@ -3,6 +3,7 @@ package edit
 import (
 	"bufio"
 	"fmt"
+	"math/big"
 	"os"
 	"strings"
 	"sync"
@@ -24,6 +25,11 @@ import (
 //
 // A map containing argument completers.
 
+//elvdoc:var completion:option-completer
+//
+// A map from command names to maps from option names to option completers.
+// See [Option Completer](#option-completer).
+
 //elvdoc:var completion:binding
 //
 // Keybinding for the completion mode.
@@ -150,6 +156,25 @@ func complexCandidate(fm *eval.Frame, opts complexCandidateOpts, stem string) co
 // }
 // ```
 
+//elvdoc:fn match-fuzzy
+//
+// ```elvish
+// edit:match-fuzzy $seed $inputs?
+// ```
+//
+// For each input, outputs a number if $seed matches the input fuzzily, and
+// `$false` otherwise. Uses the result of `to-string` for non-string inputs.
+//
+// The seed matches the input if it is a
+// [subsequence](https://en.wikipedia.org/wiki/Subsequence) of it. The number
+// is a score of how well the seed matches: characters that are next to each
+// other, at the beginning of words, or of the same case as in the seed make
+// better matches. When used as a matcher, candidates are sorted by their
+// scores, the best match first.
+//
+// Like the other builtin matchers, this function supports the `&ignore-case`
+// and `&smart-case` options.
+
 //elvdoc:fn completion:start
 //
 // Start the completion mode.
@@ -159,7 +184,7 @@ func complexCandidate(fm *eval.Frame, opts complexCandidateOpts, stem string) co
 // Starts the completion mode. However, if all the candidates share a non-empty
 // prefix and that prefix starts with the seed, inserts the prefix instead.
 
-func completionStart(app cli.App, bindings tk.Bindings, cfg complete.Config, smart bool) {
+func completionStart(app cli.App, bindings tk.Bindings, fs mode.FilterSpec, cfg complete.Config, smart bool) {
 	buf := app.CodeArea().CopyState().Buffer
 	result, err := complete.Complete(
 		complete.CodeBuffer{Content: buf.Content, Dot: buf.Dot}, cfg)
@@ -198,7 +223,7 @@ func completionStart(app cli.App, bindings tk.Bindings, cfg complete.Config, sma
 	}
 	w, err := mode.NewCompletion(app, mode.CompletionSpec{
 		Name: result.Name, Replace: result.Replace, Items: result.Items,
-		Filter: filterSpec, Bindings: bindings,
+		Filter: fs, Bindings: bindings,
 	})
 	if w != nil {
 		app.SetAddon(w, false)
@@ -212,18 +237,24 @@ func completionStart(app cli.App, bindings tk.Bindings, cfg complete.Config, sma
 //
 // Closes the completion mode UI.
 
-func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+func initCompletion(ed *Editor, ev *eval.Evaler, fs mode.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	matcherMapVar := newMapVar(vals.EmptyMap)
 	argGeneratorMapVar := newMapVar(vals.EmptyMap)
+	optionGeneratorMapVar := newMapVar(vals.EmptyMap)
 	cfg := func() complete.Config {
 		return complete.Config{
 			PureEvaler: pureEvaler{ev},
 			Filterer: adaptMatcherMap(
 				ed, ev, matcherMapVar.Get().(vals.Map)),
-			ArgGenerator: adaptArgGeneratorMap(
-				ev, argGeneratorMapVar.Get().(vals.Map)),
+			ArgGenerator: adaptArgGeneratorMapWithSpecs(
+				ed, ev, argGeneratorMapVar),
+			OptionGenerator: func(cmd string) ([]complete.RawItem, error) {
+				return generateOptions(ev, cmd), nil
+			},
+			OptionValueGenerator: adaptOptionGeneratorMap(
+				ev, optionGeneratorMapVar.Get().(vals.Map)),
 		}
 	}
 	generateForSudo := func(args []string) ([]complete.RawItem, error) {
@@ -237,17 +268,19 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		"match-prefix":      wrapMatcher(strings.HasPrefix),
 		"match-subseq":      wrapMatcher(strutil.HasSubseq),
 		"match-substr":      wrapMatcher(strings.Contains),
+		"match-fuzzy":       matchFuzzy,
 	})
 	app := ed.app
 	nb.AddNs("completion",
 		eval.NsBuilder{
-			"arg-completer": argGeneratorMapVar,
-			"binding":       bindingVar,
-			"matcher":       matcherMapVar,
+			"arg-completer":    argGeneratorMapVar,
+			"binding":          bindingVar,
+			"matcher":          matcherMapVar,
+			"option-completer": optionGeneratorMapVar,
 		}.AddGoFns("<edit:completion>:", map[string]interface{}{
 			"accept":      func() { listingAccept(app) },
-			"smart-start": func() { completionStart(app, bindings, cfg(), true) },
-			"start":       func() { completionStart(app, bindings, cfg(), false) },
+			"smart-start": func() { completionStart(app, bindings, fs, cfg(), true) },
+			"start":       func() { completionStart(app, bindings, fs, cfg(), false) },
 			"up":          func() { listingUp(app) },
 			"down":        func() { listingDown(app) },
 			"up-cycle":    func() { listingUpCycle(app) },
@@ -440,14 +473,41 @@ func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer
 		}
 		filtered := []complete.RawItem{}
 		for i := 0; i < len(rawItems) && i < len(outputs); i++ {
-			if vals.Bool(outputs[i]) {
-				filtered = append(filtered, rawItems[i])
+			switch output := outputs[i].(type) {
+			case int, *big.Int, *big.Rat, float64:
+				// A number is the score of a kept candidate.
+				filtered = append(filtered, complete.RankedItem{
+					RawItem: rawItems[i], Score: vals.ConvertToFloat64(output)})
+			default:
+				if vals.Bool(output) {
+					filtered = append(filtered, rawItems[i])
+				}
 			}
 		}
 		return filtered
 	}
 }
 
+// Implements edit:match-fuzzy. The output for each input is its score as a
+// fuzzy match of the seed, or $false if it does not match.
+func matchFuzzy(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.Inputs) error {
+	out := fm.ValueOutput()
+	ignoreCase := opts.IgnoreCase || (opts.SmartCase && seed == strings.ToLower(seed))
+	var errOut error
+	inputs(func(v interface{}) {
+		if errOut != nil {
+			return
+		}
+		score, _, ok := strutil.FuzzyMatch(seed, vals.ToString(v), ignoreCase)
+		if ok {
+			errOut = out.Put(score)
+		} else {
+			errOut = out.Put(false)
+		}
+	})
+	return errOut
+}
+
 func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
 	return func(args []string) ([]complete.RawItem, error) {
 		gen, ok := lookupFn(m, args[0])
@@ -461,50 +521,101 @@ func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
 		for i, arg := range args {
 			argValues[i] = arg
 		}
-		var output []complete.RawItem
-		var outputMutex sync.Mutex
-		collect := func(item complete.RawItem) {
-			outputMutex.Lock()
-			defer outputMutex.Unlock()
-			output = append(output, item)
+		return callGenerator(ev, gen, argValues, "[editor arg generator]")
+	}
+}
+
+// Generates the names of the options of the function a command refers to,
+// displayed along with their default values.
+func generateOptions(ev *eval.Evaler, cmd string) []complete.RawItem {
+	fn, ok := functionOf(ev, cmd)
+	if !ok {
+		return nil
+	}
+	sig, ok := eval.SignatureOf(fn)
+	if !ok {
+		return nil
+	}
+	items := make([]complete.RawItem, len(sig.Opts))
+	for i, opt := range sig.Opts {
+		items[i] = complete.ComplexItem{
+			Stem: opt.Name, CodeSuffix: "=",
+			Display: opt.Name + " (default " + opt.Default + ")"}
+	}
+	return items
+}
+
+// Adapts $edit:completion:option-completer into an OptionValueGenerator.
+func adaptOptionGeneratorMap(ev *eval.Evaler, m vals.Map) complete.OptionValueGenerator {
+	return func(cmd, opt, seed string) ([]complete.RawItem, error) {
+		optMap, ok := m.Index(cmd)
+		if !ok {
+			return complete.GenerateFileNames([]string{seed})
 		}
-		valueCb := func(ch <-chan interface{}) {
-			for v := range ch {
-				switch v := v.(type) {
-				case string:
-					collect(complete.PlainItem(v))
-				case complexItem:
-					collect(complete.ComplexItem(v))
-				default:
-					collect(complete.PlainItem(vals.ToString(v)))
-				}
-			}
+		gen, err := vals.Index(optMap, opt)
+		if err != nil {
+			return complete.GenerateFileNames([]string{seed})
 		}
-		bytesCb := func(r *os.File) {
-			buffered := bufio.NewReader(r)
-			for {
-				line, err := buffered.ReadString('\n')
-				if line != "" {
-					collect(complete.PlainItem(strutil.ChopLineEnding(line)))
-				}
-				if err != nil {
-					break
-				}
+		fn, ok := gen.(eval.Callable)
+		if !ok {
+			return nil, fmt.Errorf("option completer for %s &%s not a function", cmd, opt)
+		}
+		return callGenerator(ev, fn, []interface{}{seed}, "[editor option generator]")
+	}
+}
+
+// Calls a completer implemented in Elvish, and collects the candidates it
+// outputs.
+func callGenerator(ev *eval.Evaler, gen eval.Callable, args []interface{}, from string) ([]complete.RawItem, error) {
+	return collectCandidates(func(ports []*eval.Port) error {
+		return ev.Call(gen,
+			eval.CallCfg{Args: args, From: from}, eval.EvalCfg{Ports: ports})
+	})
+}
+
+// Runs Elvish code with the given function, and collects the candidates it
+// outputs as values or lines.
+func collectCandidates(run func(ports []*eval.Port) error) ([]complete.RawItem, error) {
+	var output []complete.RawItem
+	var outputMutex sync.Mutex
+	collect := func(item complete.RawItem) {
+		outputMutex.Lock()
+		defer outputMutex.Unlock()
+		output = append(output, item)
+	}
+	valueCb := func(ch <-chan interface{}) {
+		for v := range ch {
+			switch v := v.(type) {
+			case string:
+				collect(complete.PlainItem(v))
+			case complexItem:
+				collect(complete.ComplexItem(v))
+			default:
+				collect(complete.PlainItem(vals.ToString(v)))
 			}
 		}
-		port1, done, err := eval.PipePort(valueCb, bytesCb)
-		if err != nil {
-			panic(err)
+	}
+	bytesCb := func(r *os.File) {
+		buffered := bufio.NewReader(r)
+		for {
+			line, err := buffered.ReadString('\n')
+			if line != "" {
+				collect(complete.PlainItem(strutil.ChopLineEnding(line)))
+			}
+			if err != nil {
+				break
+			}
 		}
-		err = ev.Call(gen,
-			eval.CallCfg{Args: argValues, From: "[editor arg generator]"},
-			eval.EvalCfg{Ports: []*eval.Port{
-				// TODO: Supply the Chan component of port 2.
-				nil, port1, {File: os.Stderr}}})
-		done()
-
-		return output, err
 	}
+	port1, done, err := eval.PipePort(valueCb, bytesCb)
+	if err != nil {
+		panic(err)
+	}
+	// TODO: Supply the Chan component of port 2.
+	err = run([]*eval.Port{nil, port1, {File: os.Stderr}})
+	done()
+
+	return output, err
 }
 
 func lookupFn(m vals.Map, ctxName string) (eval.Callable, bool) {
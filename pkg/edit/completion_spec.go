@@ -0,0 +1,367 @@
+package edit
+
+// Implementation of completion specs, declarative descriptions of the options
+// and arguments of commands that are compiled into argument completers.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/getopt"
+	"src.elv.sh/pkg/parse"
+)
+
+// The directory under the library directory containing completion specs.
+const completionSpecsDir = "completions"
+
+// The format of completion specs. Specs written as Elvish maps are converted
+// to JSON first, so both formats share this definition.
+type completionSpec struct {
+	Desc        string                     `json:"desc"`
+	Options     []optionSpec               `json:"options"`
+	Args        []argSpec                  `json:"args"`
+	Rest        *argSpec                   `json:"rest"`
+	Subcommands map[string]*completionSpec `json:"subcommands"`
+}
+
+type optionSpec struct {
+	Short string `json:"short"`
+	Long  string `json:"long"`
+	Desc  string `json:"desc"`
+	// If not nil, the option takes a required argument.
+	Arg     *argSpec `json:"arg"`
+	ArgDesc string   `json:"arg-desc"`
+}
+
+// The spec of an argument. Arguments that only need a type can also be written
+// as just the type.
+type argSpec struct {
+	Type string `json:"type"`
+	// The candidates of a "one-of" argument.
+	Values []string `json:"values"`
+	// The Elvish code that outputs the candidates of a "command" argument.
+	Command string `json:"command"`
+}
+
+func (a *argSpec) UnmarshalJSON(data []byte) error {
+	if json.Unmarshal(data, &a.Type) == nil {
+		return nil
+	}
+	// Use a type without the UnmarshalJSON method to avoid infinite recursion.
+	type plainArgSpec argSpec
+	return json.Unmarshal(data, (*plainArgSpec)(a))
+}
+
+// A completion spec compiled for use with getopt.
+type compiledSpec struct {
+	opts        []*getopt.Option
+	optSpecs    map[*getopt.Option]*optionSpec
+	args        []argSpec
+	rest        *argSpec
+	subcommands map[string]*compiledSpec
+	desc        string
+}
+
+// Loads the completion spec for a command from the completions directory
+// under the library directory, either from a JSON file or from an Elvish file
+// that outputs a map. Returns nil and no error if there is no spec for the
+// command.
+func loadCompletionSpec(ev *eval.Evaler, cmd string) (*compiledSpec, error) {
+	libDir := ev.LibDir()
+	name := filepath.Base(cmd)
+	if libDir == "" || name == "." || name == string(filepath.Separator) {
+		return nil, nil
+	}
+	base := filepath.Join(libDir, completionSpecsDir, name)
+
+	var spec completionSpec
+	if data, err := ioutil.ReadFile(base + ".json"); err == nil {
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("%s.json: %v", base, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else if data, err := ioutil.ReadFile(base + ".elv"); err == nil {
+		if err := evalCompletionSpec(ev, base+".elv", string(data), &spec); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else {
+		return nil, nil
+	}
+	return compileSpec(&spec)
+}
+
+func evalCompletionSpec(ev *eval.Evaler, path, code string, spec *completionSpec) error {
+	port, collect, err := eval.CapturePort()
+	if err != nil {
+		return err
+	}
+	err = ev.Eval(parse.Source{Name: path, Code: code},
+		eval.EvalCfg{Ports: []*eval.Port{nil, port}, Global: eval.NsBuilder{}.Ns()})
+	outputs := collect()
+	if err != nil {
+		return err
+	}
+	if len(outputs) != 1 {
+		return fmt.Errorf("%s should output exactly one map, got %d values", path, len(outputs))
+	}
+	if _, ok := outputs[0].(vals.Map); !ok {
+		return fmt.Errorf("%s should output a map, got %s", path, vals.Kind(outputs[0]))
+	}
+	data, err := json.Marshal(outputs[0])
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func compileSpec(spec *completionSpec) (*compiledSpec, error) {
+	s := &compiledSpec{
+		optSpecs: map[*getopt.Option]*optionSpec{},
+		args:     spec.Args, rest: spec.Rest, desc: spec.Desc}
+	for i := range spec.Options {
+		optSpec := &spec.Options[i]
+		opt := &getopt.Option{Long: optSpec.Long}
+		if optSpec.Short != "" {
+			r, size := utf8.DecodeRuneInString(optSpec.Short)
+			if r == utf8.RuneError || size != len(optSpec.Short) {
+				return nil, fmt.Errorf(
+					"short option should be exactly one rune, got %v",
+					parse.Quote(optSpec.Short))
+			}
+			opt.Short = r
+		}
+		if opt.Short == 0 && opt.Long == "" {
+			return nil, errors.New(
+				"option should have at least one of short and long forms")
+		}
+		if optSpec.Arg != nil {
+			if err := checkArgSpec(optSpec.Arg); err != nil {
+				return nil, err
+			}
+			opt.HasArg = getopt.RequiredArgument
+		}
+		s.opts = append(s.opts, opt)
+		s.optSpecs[opt] = optSpec
+	}
+	for i := range spec.Args {
+		if err := checkArgSpec(&spec.Args[i]); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Rest != nil {
+		if err := checkArgSpec(spec.Rest); err != nil {
+			return nil, err
+		}
+	}
+	if len(spec.Subcommands) > 0 {
+		s.subcommands = make(map[string]*compiledSpec, len(spec.Subcommands))
+		for name, subSpec := range spec.Subcommands {
+			sub, err := compileSpec(subSpec)
+			if err != nil {
+				return nil, fmt.Errorf("subcommand %s: %v", name, err)
+			}
+			s.subcommands[name] = sub
+		}
+	}
+	return s, nil
+}
+
+func checkArgSpec(a *argSpec) error {
+	switch a.Type {
+	case "file", "dir", "one-of":
+		return nil
+	case "command":
+		_, err := parse.Parse(parse.Source{Name: "[completion spec]", Code: a.Command}, parse.Config{})
+		return err
+	default:
+		return fmt.Errorf("unknown argument type %s", parse.Quote(a.Type))
+	}
+}
+
+// Returns an argument completer that completes according to the spec.
+func (s *compiledSpec) argCompleter(ev *eval.Evaler, cmd string) eval.Callable {
+	return eval.NewGoFn("<completion spec for "+cmd+">",
+		wrapArgGenerator(func(args []string) ([]complete.RawItem, error) {
+			return s.generate(ev, args[1:])
+		}))
+}
+
+// Generates candidates for the last of the arguments, which don't include the
+// command itself.
+func (s *compiledSpec) generate(ev *eval.Evaler, args []string) ([]complete.RawItem, error) {
+	g := getopt.Getopt{Options: s.opts, Config: getopt.GNUGetoptLong}
+	if len(s.subcommands) > 0 {
+		// The subcommand is the first argument that is not an option or the
+		// argument of an option; find it by parsing more and more arguments.
+		for i := 1; i < len(args); i++ {
+			if _, parsedArgs, _ := g.Parse(args[:i+1]); len(parsedArgs) > 0 {
+				if sub, ok := s.subcommands[args[i-1]]; ok {
+					return sub.generate(ev, args[i:])
+				}
+				return nil, nil
+			}
+		}
+	}
+
+	_, parsedArgs, ctx := g.Parse(args)
+	current := args[len(args)-1]
+	var items []complete.RawItem
+	switch ctx.Type {
+	case getopt.NewOptionOrArgument, getopt.Argument:
+		if len(s.subcommands) > 0 {
+			return s.subcommandItems(), nil
+		}
+		if arg := s.argAt(len(parsedArgs)); arg != nil {
+			return arg.generate(ev, current)
+		}
+	case getopt.NewOption:
+		for _, opt := range s.opts {
+			if opt.Short != 0 {
+				items = append(items, s.optionItem(opt, "-"+string(opt.Short)))
+			}
+			if opt.Long != "" {
+				items = append(items, s.optionItem(opt, "--"+opt.Long))
+			}
+		}
+	case getopt.NewLongOption, getopt.LongOption:
+		for _, opt := range s.opts {
+			if opt.Long != "" {
+				items = append(items, s.optionItem(opt, "--"+opt.Long))
+			}
+		}
+	case getopt.ChainShortOption:
+		for _, opt := range s.opts {
+			if opt.Short != 0 {
+				items = append(items, s.optionItem(opt, current+string(opt.Short)))
+			}
+		}
+	case getopt.OptionArgument:
+		optSpec, ok := s.optSpecs[ctx.Option.Option]
+		if !ok || optSpec.Arg == nil {
+			return nil, nil
+		}
+		argItems, err := optSpec.Arg.generate(ev, ctx.Option.Argument)
+		// The argument may be in the same word as the option, like
+		// "--opt=arg" or "-oarg".
+		prefix := current[:len(current)-len(ctx.Option.Argument)]
+		return prefixItems(prefix, argItems), err
+	}
+	return items, nil
+}
+
+// Returns the spec of the i-th positional argument, or nil if there is none.
+func (s *compiledSpec) argAt(i int) *argSpec {
+	if i < len(s.args) {
+		return &s.args[i]
+	}
+	return s.rest
+}
+
+func (s *compiledSpec) subcommandItems() []complete.RawItem {
+	names := make([]string, 0, len(s.subcommands))
+	for name := range s.subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]complete.RawItem, len(names))
+	for i, name := range names {
+		item := complete.ComplexItem{Stem: name, CodeSuffix: " "}
+		if desc := s.subcommands[name].desc; desc != "" {
+			item.Display = name + " (" + desc + ")"
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// Returns a candidate for an option, displayed with the description of the
+// option and its argument, like edit:complete-getopt.
+func (s *compiledSpec) optionItem(opt *getopt.Option, stem string) complete.RawItem {
+	item := complete.ComplexItem{Stem: stem}
+	optSpec := s.optSpecs[opt]
+	if optSpec.Desc != "" {
+		if optSpec.ArgDesc != "" {
+			item.Display = stem + " " + optSpec.ArgDesc + " (" + optSpec.Desc + ")"
+		} else {
+			item.Display = stem + " (" + optSpec.Desc + ")"
+		}
+	}
+	return item
+}
+
+func (a *argSpec) generate(ev *eval.Evaler, seed string) ([]complete.RawItem, error) {
+	switch a.Type {
+	case "file":
+		return complete.GenerateFileNames([]string{seed})
+	case "dir":
+		return complete.GenerateDirNames([]string{seed})
+	case "one-of":
+		items := make([]complete.RawItem, len(a.Values))
+		for i, value := range a.Values {
+			items[i] = complete.PlainItem(value)
+		}
+		return items, nil
+	case "command":
+		return collectCandidates(func(ports []*eval.Port) error {
+			return ev.Eval(parse.Source{Name: "[completion spec]", Code: a.Command},
+				eval.EvalCfg{Ports: ports, Global: eval.NsBuilder{}.Ns()})
+		})
+	}
+	return nil, nil
+}
+
+func prefixItems(prefix string, items []complete.RawItem) []complete.RawItem {
+	if prefix == "" {
+		return items
+	}
+	prefixed := make([]complete.RawItem, len(items))
+	for i, item := range items {
+		switch item := item.(type) {
+		case complete.ComplexItem:
+			if item.Display != "" {
+				item.Display = prefix + item.Display
+			}
+			item.Stem = prefix + item.Stem
+			prefixed[i] = item
+		default:
+			prefixed[i] = complete.PlainItem(prefix + item.String())
+		}
+	}
+	return prefixed
+}
+
+// Wraps the ArgGenerator for $edit:completion:arg-completer, so that commands
+// without argument completers use their completion specs if they have any.
+// A spec is loaded and compiled into an argument completer when it is first
+// used, and the completer is added to $edit:completion:arg-completer.
+func adaptArgGeneratorMapWithSpecs(nt notifier, ev *eval.Evaler, mapVar vars.PtrVar) complete.ArgGenerator {
+	return func(args []string) ([]complete.RawItem, error) {
+		m := mapVar.Get().(vals.Map)
+		if _, ok := m.Index(args[0]); !ok {
+			spec, err := loadCompletionSpec(ev, args[0])
+			if err != nil {
+				nt.notifyf("cannot load completion spec for %s: %v", args[0], err)
+			} else if spec != nil {
+				m = m.Assoc(args[0], spec.argCompleter(ev, args[0]))
+				mapVar.Set(m)
+			}
+		}
+		return adaptArgGeneratorMap(ev, m)(args)
+	}
+}
@@ -0,0 +1,197 @@
+package edit
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/testutil"
+)
+
+const testSpec = `{
+  "options": [
+    {"short": "v", "long": "verbose", "desc": "Be verbose"},
+    {"short": "c", "long": "color", "arg": {"type": "one-of", "values": ["always", "never"]}}
+  ],
+  "subcommands": {
+    "add": {"desc": "Add files", "args": ["file"], "rest": "file"},
+    "checkout": {
+      "args": [{"type": "command", "command": "put main dev"}]
+    },
+    "cd": {"args": ["dir"]}
+  }
+}`
+
+var completionSpecGenerateTests = []struct {
+	name string
+	args []string
+	want []string
+}{
+	{"subcommands", []string{""}, []string{"add", "cd", "checkout"}},
+	{"subcommands after option", []string{"-v", ""}, []string{"add", "cd", "checkout"}},
+	{"options", []string{"-"}, []string{"-v", "--verbose", "-c", "--color"}},
+	{"long options", []string{"--"}, []string{"--verbose", "--color"}},
+	{"chained short options", []string{"-v"}, []string{"-vv", "-vc"}},
+	{"option argument", []string{"-c", ""}, []string{"always", "never"}},
+	{"inline long option argument", []string{"--color=n"}, []string{"--color=always", "--color=never"}},
+	{"inline short option argument", []string{"-ca"}, []string{"-calways", "-cnever"}},
+	{"files", []string{"add", ""}, []string{"d/", "f"}},
+	{"files in rest argument", []string{"-c", "never", "add", "f", ""}, []string{"d/", "f"}},
+	{"dirs", []string{"cd", ""}, []string{"d/"}},
+	{"command output", []string{"checkout", ""}, []string{"main", "dev"}},
+	{"no more arguments", []string{"cd", "d", ""}, nil},
+	{"unknown subcommand", []string{"foo", ""}, nil},
+}
+
+func TestCompletionSpec_Generate(t *testing.T) {
+	_, cleanup := testutil.InTestDir()
+	defer cleanup()
+	testutil.ApplyDir(testutil.Dir{"d": testutil.Dir{}, "f": ""})
+
+	var spec completionSpec
+	if err := json.Unmarshal([]byte(testSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := compileSpec(&spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := eval.NewEvaler()
+	for _, test := range completionSpecGenerateTests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := compiled.generate(ev, test.args)
+			if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if got := stems(items); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompletionSpec_Display(t *testing.T) {
+	spec := completionSpec{
+		Options: []optionSpec{
+			{Short: "n", Desc: "Set name", Arg: &argSpec{Type: "file"}, ArgDesc: "name"}},
+		Subcommands: map[string]*completionSpec{"add": {Desc: "Add files"}},
+	}
+	compiled, err := compileSpec(&spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := eval.NewEvaler()
+	wantItems := map[string]complete.RawItem{
+		"": complete.ComplexItem{
+			Stem: "add", CodeSuffix: " ", Display: "add (Add files)"},
+		"-": complete.ComplexItem{Stem: "-n", Display: "-n name (Set name)"},
+	}
+	for arg, want := range wantItems {
+		items, _ := compiled.generate(ev, []string{arg})
+		if len(items) != 1 || items[0] != want {
+			t.Errorf("generate(%q) -> %v, want %v", arg, items, want)
+		}
+	}
+}
+
+var compileSpecErrorTests = []struct {
+	spec    string
+	wantErr string
+}{
+	{`{"options": [{"desc": "x"}]}`, "at least one of short and long"},
+	{`{"options": [{"short": "xy"}]}`, "exactly one rune"},
+	{`{"args": ["bad"]}`, "unknown argument type bad"},
+	{`{"rest": {"type": "command", "command": "put ("}}`, "should be ')'"},
+	{`{"subcommands": {"a": {"args": ["bad"]}}}`, "subcommand a: unknown argument type"},
+}
+
+func TestCompileSpec_Errors(t *testing.T) {
+	for _, test := range compileSpecErrorTests {
+		var spec completionSpec
+		if err := json.Unmarshal([]byte(test.spec), &spec); err != nil {
+			t.Fatal(err)
+		}
+		_, err := compileSpec(&spec)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("compileSpec(%s) -> error %v, want containing %q",
+				test.spec, err, test.wantErr)
+		}
+	}
+}
+
+func TestLoadCompletionSpec(t *testing.T) {
+	libDir, cleanup := testutil.InTestDir()
+	defer cleanup()
+	testutil.ApplyDir(testutil.Dir{
+		completionSpecsDir: testutil.Dir{
+			"json.json":   `{"args": [{"type": "one-of", "values": ["a", "b"]}]}`,
+			"elv.elv":     `put [&args=[[&type=one-of &values=[a b]]]]`,
+			"bad.json":    `{"args": [}`,
+			"not-map.elv": `put foo`,
+			"two.elv":     `put [&] [&]`,
+		},
+	})
+	ev := eval.NewEvaler()
+	ev.SetLibDir(libDir)
+
+	for _, cmd := range []string{"json", "elv", "/usr/bin/json"} {
+		spec, err := loadCompletionSpec(ev, cmd)
+		if err != nil || spec == nil {
+			t.Errorf("loadCompletionSpec(%q) -> %v, %v, want spec and no error", cmd, spec, err)
+			continue
+		}
+		items, _ := spec.generate(ev, []string{""})
+		if got := stems(items); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("spec for %q generates %q, want [a b]", cmd, got)
+		}
+	}
+	if spec, err := loadCompletionSpec(ev, "none"); spec != nil || err != nil {
+		t.Errorf("loadCompletionSpec(none) -> %v, %v, want nil, nil", spec, err)
+	}
+	for _, cmd := range []string{"bad", "not-map", "two"} {
+		if _, err := loadCompletionSpec(ev, cmd); err == nil {
+			t.Errorf("loadCompletionSpec(%q) -> no error, want error", cmd)
+		}
+	}
+}
+
+func TestCompletionSpec_InEditor(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+	testutil.ApplyDir(testutil.Dir{
+		"lib": testutil.Dir{
+			completionSpecsDir: testutil.Dir{
+				"foo.json": `{"args": [{"type": "one-of", "values": ["lorem", "ipsum"]}]}`,
+			},
+		},
+	})
+	f.Evaler.SetLibDir(filepath.Join(f.Home, "lib"))
+	evals(f.Evaler, `fn foo { }`)
+
+	feedInput(f.TTYCtrl, "foo \t")
+	f.TestTTY(t,
+		"~> foo ipsum\n", Styles,
+		"   vvv _____",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"ipsum  lorem", Styles,
+		"+++++       ",
+	)
+	// The spec has been compiled into an argument completer.
+	evals(f.Evaler, `@cands = ($edit:completion:arg-completer[foo] foo '')`)
+	testGlobal(t, f.Evaler, "cands", vals.MakeList("lorem", "ipsum"))
+}
+
+func stems(items []complete.RawItem) []string {
+	var stems []string
+	for _, item := range items {
+		stems = append(stems, item.String())
+	}
+	return stems
+}
@@ -138,6 +138,53 @@ func TestCompletionArgCompleter_BytesOutput(t *testing.T) {
 	)
 }
 
+func TestCompletionOption(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	evals(f.Evaler, `fn foo [&lorem=x &ipsum=(num 2)]{ }`)
+
+	feedInput(f.TTYCtrl, "foo &\t")
+	f.TestTTY(t,
+		"~> foo &ipsum=\n", Styles,
+		"   vvv b______",
+		" COMPLETING option  ", Styles,
+		"******************* ", term.DotHere, "\n",
+		"ipsum (default (num 2))  lorem (default x)", Styles,
+		"+++++++++++++++++++++++                   ",
+	)
+}
+
+func TestCompletionOptionCompleter(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	evals(f.Evaler,
+		`seed = ''`,
+		`fn foo [&lorem=x]{ }`,
+		`edit:completion:option-completer[foo] = [&lorem=[s]{
+		   seed = $s
+		   put lorem1 lorem2
+		 }]`)
+
+	feedInput(f.TTYCtrl, "foo &lorem=l\t")
+	f.TestTTY(t,
+		"~> foo &lorem=lorem", Styles,
+		"   vvv b", term.DotHere,
+	)
+
+	feedInput(f.TTYCtrl, "\t")
+	f.TestTTY(t,
+		"~> foo &lorem=lorem1\n", Styles,
+		"   vvv b      ______",
+		" COMPLETING option-value  ", Styles,
+		"************************* ", term.DotHere, "\n",
+		"lorem1  lorem2", Styles,
+		"++++++        ",
+	)
+	testGlobal(t, f.Evaler, "seed", "lorem")
+}
+
 func TestCompleteSudo(t *testing.T) {
 	f := setup()
 	defer f.Cleanup()
@@ -169,6 +216,24 @@ func TestCompletionMatcher(t *testing.T) {
 	)
 }
 
+func TestCompletionMatcher_Scores(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+	testutil.ApplyDir(testutil.Dir{"axfxb": "", "fb": "", "bf": ""})
+
+	// Candidates are sorted by the scores output by the matcher.
+	evals(f.Evaler, `edit:completion:matcher[''] = $edit:match-fuzzy~`)
+	feedInput(f.TTYCtrl, "echo fb\t")
+	f.TestTTY(t,
+		"~> echo fb \n", Styles,
+		"   vvvv ___",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"fb  axfxb", Styles,
+		"++       ",
+	)
+}
+
 func TestBuiltinMatchers(t *testing.T) {
 	f := setup()
 	defer f.Cleanup()
@@ -187,6 +252,23 @@ func TestBuiltinMatchers(t *testing.T) {
 	testThatOutputErrorIsBubbled(t, f, "edit:match-prefix ab [ab]")
 }
 
+func TestMatchFuzzy(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	evals(f.Evaler,
+		`@fuzzy = (edit:match-fuzzy ab [ab abc cab acb ba [ab] [a b] [b a]])`,
+		`@matched = (each [x]{ not-eq $x $false } $fuzzy)`,
+		`better = (> $fuzzy[0] $fuzzy[3])`,
+	)
+	testGlobals(t, f.Evaler, map[string]interface{}{
+		"matched": vals.MakeList(true, true, true, true, false, true, true, false),
+		"better":  true,
+	})
+
+	testThatOutputErrorIsBubbled(t, f, "edit:match-fuzzy ab [ab]")
+}
+
 func TestBuiltinMatchers_Options(t *testing.T) {
 	f := setup()
 	defer f.Cleanup()
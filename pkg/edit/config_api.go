@@ -70,7 +70,7 @@ func initAfterReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder)
 // not run. The default value of this list contains a filter which
 // ignores command starts with space.
 
-func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store) {
+func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store, r *cmdInfoRecorder) {
 	ignoreLeadingSpace := eval.NewGoFn("<ignore-cmd-with-leading-space>",
 		func(s string) bool { return !strings.HasPrefix(s, " ") })
 	filters := newListVar(vals.MakeList(ignoreLeadingSpace))
@@ -80,7 +80,10 @@ func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder,
 		if code != "" &&
 			callFilters(ev, "$<edit>:add-cmd-filters",
 				filters.Get().(vals.List), code) {
-			s.AddCmd(storedefs.Cmd{Text: code, Seq: -1})
+			seq, err := s.AddCmd(storedefs.Cmd{Text: code, Seq: -1})
+			if err == nil {
+				r.start(seq, code)
+			}
 		}
 		// TODO(xiaq): Handle the error.
 	})
@@ -53,10 +53,13 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 		_ = err // TODO(xiaq): Report the error.
 	}
 
-	initHighlighter(&appSpec, ev)
+	initHighlighter(&appSpec, ev, nb)
+	initAutosuggest(&appSpec, ed, hs, st, nb)
+	initSignatureHelp(&appSpec, ev, nb)
 	initMaxHeight(&appSpec, nb)
 	initReadlineHooks(&appSpec, ev, nb)
-	initAddCmdFilters(&appSpec, ev, nb, hs)
+	cmdInfo := initCmdInfo(ed, st, nb)
+	initAddCmdFilters(&appSpec, ev, nb, hs, cmdInfo)
 	initGlobalBindings(&appSpec, ed, ev, nb)
 	initInsertAPI(&appSpec, ed, ev, nb)
 	initPrompts(&appSpec, ed, ev, nb)
@@ -65,19 +68,20 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	initExceptionsAPI(ed, nb)
 	initVarsAPI(ed, nb)
 	initCommandAPI(ed, ev, nb)
-	initListings(ed, ev, st, hs, nb)
-	initNavigation(ed, ev, nb)
-	initCompletion(ed, ev, nb)
+	listingFilter := initListings(ed, ev, st, hs, nb)
+	initNavigation(ed, ev, listingFilter, nb)
+	initCompletion(ed, ev, listingFilter, nb)
 	initHistWalk(ed, ev, hs, nb)
 	initInstant(ed, ev, nb)
 	initMinibuf(ed, ev, nb)
 
 	initRepl(ed, ev, nb)
 	initBufferBuiltins(ed.app, nb)
+	initKillRing(ed.app, nb)
 	initTTYBuiltins(ed.app, tty, nb)
 	initMiscBuiltins(ed.app, nb)
 	initStateAPI(ed.app, nb)
-	initStoreAPI(ed.app, nb, hs)
+	initStoreAPI(ed.app, nb, hs, st)
 
 	ed.ns = nb.Ns()
 	initElvishState(ev, ed.ns)
@@ -19,17 +19,17 @@ global-binding = (binding-table [
 
 insert:binding = (binding-table [
   &Left=  $move-dot-left~
-  &Right= $move-dot-right~
+  &Right= { accept-autosuggestion; move-dot-right }
 
   &Ctrl-Left=  $move-dot-left-word~
   &Ctrl-Right= $move-dot-right-word~
   &Alt-Left=   $move-dot-left-word~
   &Alt-Right=  $move-dot-right-word~
   &Alt-b=      $move-dot-left-word~
-  &Alt-f=      $move-dot-right-word~
+  &Alt-f=      { accept-autosuggestion-word; move-dot-right-word }
 
   &Home= $move-dot-sol~
-  &End=  $move-dot-eol~
+  &End=  { accept-autosuggestion; move-dot-eol }
 
   &Backspace= $kill-rune-left~
   &Ctrl-H=    $kill-rune-left~
@@ -37,9 +37,14 @@ insert:binding = (binding-table [
   &Ctrl-W=    $kill-word-left~
   &Ctrl-U=    $kill-line-left~
   &Ctrl-K=    $kill-line-right~
+  &Ctrl-Y=    $yank~
+  &Alt-y=     $yank-pop~
 
   &Ctrl-V= $insert-raw~
 
+  &Ctrl-/= $undo~
+  &Alt-/=  $redo~
+
   &Alt-,=  $lastcmd:start~
   &Alt-.=  $insert-last-word~
   &Ctrl-R= $histlist:start~
@@ -54,17 +59,16 @@ insert:binding = (binding-table [
 ])
 
 command:binding = (binding-table [
- &'$'= $move-dot-eol~
- &0=   $move-dot-sol~
- &D=   $kill-line-right~
- &b=   $move-dot-left-word~
- &h=   $move-dot-left~
- &i=   $close-mode~
- &j=   $move-dot-down~
- &k=   $move-dot-up~
- &l=   $move-dot-right~
- &w=   $move-dot-right-word~
- &x=   $kill-rune-right~
+  &Left=  $move-dot-left~
+  &Right= $move-dot-right~
+  &Home=  $move-dot-sol~
+  &End=   $move-dot-eol~
+
+  &u=      $undo~
+  &Ctrl-R= $redo~
+
+  &Enter=  $smart-enter~
+  &Ctrl-D= $return-eof~
 ])
 
 listing:binding = (binding-table [
@@ -17,8 +17,19 @@ import (
 
 // Compile parses and compiles a filter.
 func Compile(q string) (Filter, error) {
+	return compile(q, false)
+}
+
+// CompileFuzzy is like Compile, but the compiled filter matches string
+// literals in the filter fuzzily, and ranks the strings it matches by how well
+// they match. See strutil.FuzzyMatch for how fuzzy matching works.
+func CompileFuzzy(q string) (Filter, error) {
+	return compile(q, true)
+}
+
+func compile(q string, fuzzy bool) (Filter, error) {
 	qn, errParse := parseFilter(q)
-	filter, errCompile := compileFilter(qn)
+	filter, errCompile := compiler{fuzzy}.compileFilter(qn)
 	return filter, diag.Errors(errParse, errCompile)
 }
 
@@ -28,21 +39,25 @@ func parseFilter(q string) (*parse.Filter, error) {
 	return qn, err
 }
 
-func compileFilter(qn *parse.Filter) (Filter, error) {
+type compiler struct {
+	fuzzy bool
+}
+
+func (c compiler) compileFilter(qn *parse.Filter) (Filter, error) {
 	if len(qn.Opts) > 0 {
 		return nil, notSupportedError{"option"}
 	}
-	qs, err := compileCompounds(qn.Args)
+	qs, err := c.compileCompounds(qn.Args)
 	if err != nil {
 		return nil, err
 	}
 	return andFilter{qs}, nil
 }
 
-func compileCompounds(ns []*parse.Compound) ([]Filter, error) {
+func (c compiler) compileCompounds(ns []*parse.Compound) ([]Filter, error) {
 	qs := make([]Filter, len(ns))
 	for i, n := range ns {
-		q, err := compileCompound(n)
+		q, err := c.compileCompound(n)
 		if err != nil {
 			return nil, err
 		}
@@ -51,15 +66,18 @@ func compileCompounds(ns []*parse.Compound) ([]Filter, error) {
 	return qs, nil
 }
 
-func compileCompound(n *parse.Compound) (Filter, error) {
+func (c compiler) compileCompound(n *parse.Compound) (Filter, error) {
 	if pn, ok := cmpd.Primary(n); ok {
 		switch pn.Type {
 		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
 			s := pn.Value
 			ignoreCase := s == strings.ToLower(s)
+			if c.fuzzy {
+				return fuzzyFilter{s, ignoreCase}, nil
+			}
 			return substringFilter{s, ignoreCase}, nil
 		case parse.List:
-			return compileList(pn.Elements)
+			return c.compileList(pn.Elements)
 		}
 	}
 	return nil, notSupportedError{cmpd.Shape(n)}
@@ -67,7 +85,7 @@ func compileCompound(n *parse.Compound) (Filter, error) {
 
 var errEmptySubfilter = errors.New("empty subfilter")
 
-func compileList(elems []*parse.Compound) (Filter, error) {
+func (c compiler) compileList(elems []*parse.Compound) (Filter, error) {
 	if len(elems) == 0 {
 		return nil, errEmptySubfilter
 	}
@@ -94,13 +112,13 @@ func compileList(elems []*parse.Compound) (Filter, error) {
 		}
 		return regexpFilter{p}, nil
 	case "and":
-		qs, err := compileCompounds(elems[1:])
+		qs, err := c.compileCompounds(elems[1:])
 		if err != nil {
 			return nil, err
 		}
 		return andFilter{qs}, nil
 	case "or":
-		qs, err := compileCompounds(elems[1:])
+		qs, err := c.compileCompounds(elems[1:])
 		if err != nil {
 			return nil, err
 		}
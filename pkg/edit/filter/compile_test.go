@@ -85,10 +85,29 @@ func TestCompile(t *testing.T) {
 	)
 }
 
+func TestCompileFuzzy(t *testing.T) {
+	test(t,
+		That("bareword matches any string containing it as a subsequence").
+			FuzzyFilter("foo").Matches("foobar", "faoo", "f-o-o").DoesNotMatch("", "ofo"),
+		That("bareword is case-insensitive is filter is all lower case").
+			FuzzyFilter("foo").Matches("FOO", "FaOO").DoesNotMatch("OFO"),
+		That("bareword is case-sensitive is filter is not all lower case").
+			FuzzyFilter("Foo").Matches("FaoOo").DoesNotMatch("foo"),
+		That("space-separated words work like an AND filter").
+			FuzzyFilter("fo br").Matches("foobar", "bar foo").DoesNotMatch("foo"),
+		That("RE filter still works").
+			FuzzyFilter("[re f..]").Matches("foo").DoesNotMatch("fo"),
+	)
+}
+
 func test(t *testing.T, tests ...testCase) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			q, err := filter.Compile(test.filter)
+			compile := filter.Compile
+			if test.fuzzy {
+				compile = filter.CompileFuzzy
+			}
+			q, err := compile(test.filter)
 			if errType := getErrorType(err); errType != test.errorType {
 				t.Errorf("%q should have %s, but has %s",
 					test.filter, test.errorType, errType)
@@ -119,6 +138,7 @@ func test(t *testing.T, tests ...testCase) {
 type testCase struct {
 	name         string
 	filter       string
+	fuzzy        bool
 	matches      []string
 	doesntMatch  []string
 	errorType    errorType
@@ -134,6 +154,12 @@ func (t testCase) Filter(q string) testCase {
 	return t
 }
 
+func (t testCase) FuzzyFilter(q string) testCase {
+	t.filter = q
+	t.fuzzy = true
+	return t
+}
+
 func (t testCase) DoesNotParse(message string) testCase {
 	t.errorType = parseError
 	t.errorMessage = message
@@ -2,12 +2,21 @@ package filter
 
 import (
 	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/strutil"
 )
 
 // Filter represents a compiled filter, which can be used to match text.
 type Filter interface {
 	Match(s string) bool
+	// Rank matches s against the filter, and returns a score for ranking s
+	// against other strings, the byte indices in s of the runes that were
+	// matched, and whether s matches at all. A higher score indicates a better
+	// match.
+	Rank(s string) (score int, matched []int, ok bool)
 }
 
 type andFilter struct {
@@ -23,6 +32,20 @@ func (aq andFilter) Match(s string) bool {
 	return true
 }
 
+func (aq andFilter) Rank(s string) (int, []int, bool) {
+	total := 0
+	var allMatched []int
+	for _, q := range aq.queries {
+		score, matched, ok := q.Rank(s)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		allMatched = append(allMatched, matched...)
+	}
+	return total, normalizeMatched(allMatched), true
+}
+
 type orFilter struct {
 	queries []Filter
 }
@@ -36,6 +59,23 @@ func (oq orFilter) Match(s string) bool {
 	return false
 }
 
+func (oq orFilter) Rank(s string) (int, []int, bool) {
+	best, anyOK := 0, false
+	var allMatched []int
+	for _, q := range oq.queries {
+		score, matched, ok := q.Rank(s)
+		if !ok {
+			continue
+		}
+		if !anyOK || score > best {
+			best = score
+		}
+		anyOK = true
+		allMatched = append(allMatched, matched...)
+	}
+	return best, normalizeMatched(allMatched), anyOK
+}
+
 type substringFilter struct {
 	pattern    string
 	ignoreCase bool
@@ -48,6 +88,23 @@ func (sq substringFilter) Match(s string) bool {
 	return strings.Contains(s, sq.pattern)
 }
 
+func (sq substringFilter) Rank(s string) (int, []int, bool) {
+	t := s
+	if sq.ignoreCase {
+		t = strings.ToLower(s)
+	}
+	i := strings.Index(t, sq.pattern)
+	if i == -1 {
+		return 0, nil, false
+	}
+	if len(t) != len(s) {
+		// Lowercasing has changed the byte indices; don't report matched
+		// runes since they can't be reliably located.
+		return 0, nil, true
+	}
+	return 0, runeIndices(s, i, i+len(sq.pattern)), true
+}
+
 type regexpFilter struct {
 	pattern *regexp.Regexp
 }
@@ -55,3 +112,52 @@ type regexpFilter struct {
 func (rq regexpFilter) Match(s string) bool {
 	return rq.pattern.MatchString(s)
 }
+
+func (rq regexpFilter) Rank(s string) (int, []int, bool) {
+	loc := rq.pattern.FindStringIndex(s)
+	if loc == nil {
+		return 0, nil, false
+	}
+	return 0, runeIndices(s, loc[0], loc[1]), true
+}
+
+type fuzzyFilter struct {
+	pattern    string
+	ignoreCase bool
+}
+
+func (fq fuzzyFilter) Match(s string) bool {
+	_, _, ok := fq.Rank(s)
+	return ok
+}
+
+func (fq fuzzyFilter) Rank(s string) (int, []int, bool) {
+	return strutil.FuzzyMatch(fq.pattern, s, fq.ignoreCase)
+}
+
+// Returns the byte indices of the runes in s[from:to].
+func runeIndices(s string, from, to int) []int {
+	var indices []int
+	for i := from; i < to; {
+		indices = append(indices, i)
+		_, n := utf8.DecodeRuneInString(s[i:])
+		i += n
+	}
+	return indices
+}
+
+// Sorts and deduplicates indices.
+func normalizeMatched(indices []int) []int {
+	if len(indices) == 0 {
+		return nil
+	}
+	sort.Ints(indices)
+	j := 1
+	for i := 1; i < len(indices); i++ {
+		if indices[i] != indices[j-1] {
+			indices[j] = indices[i]
+			j++
+		}
+	}
+	return indices[:j]
+}
@@ -0,0 +1,57 @@
+package filter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/edit/filter"
+)
+
+var rankTests = []struct {
+	name        string
+	filter      string
+	fuzzy       bool
+	s           string
+	wantMatched []int
+	wantOK      bool
+}{
+	{"substring", "oo", false, "foo", []int{1, 2}, true},
+	{"substring not matching", "oo", false, "bar", nil, false},
+	{"case-insensitive substring", "oo", false, "FOO", []int{1, 2}, true},
+	{"regexp", "[re 'b.']", false, "foobar", []int{3, 4}, true},
+	{"and", "fo ar", false, "foobar", []int{0, 1, 4, 5}, true},
+	{"and not matching", "fo ba", false, "foo", nil, false},
+	{"or", "[or fo xx]", false, "foo", []int{0, 1}, true},
+	{"fuzzy", "fb", true, "foo bar", []int{0, 4}, true},
+	{"fuzzy not matching", "bf", true, "foo bar", nil, false},
+	{"non-ASCII", "界", false, "世界", []int{3}, true},
+}
+
+func TestRank(t *testing.T) {
+	for _, test := range rankTests {
+		t.Run(test.name, func(t *testing.T) {
+			compile := filter.Compile
+			if test.fuzzy {
+				compile = filter.CompileFuzzy
+			}
+			q, err := compile(test.filter)
+			if err != nil {
+				t.Fatalf("compile error: %v", err)
+			}
+			_, matched, ok := q.Rank(test.s)
+			if ok != test.wantOK || !reflect.DeepEqual(matched, test.wantMatched) {
+				t.Errorf("Rank(%q) -> %v, %v, want %v, %v",
+					test.s, matched, ok, test.wantMatched, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestRank_FuzzyScores(t *testing.T) {
+	q, _ := filter.CompileFuzzy("foo")
+	better, _, _ := q.Rank("foo")
+	worse, _, _ := q.Rank("f_o_o")
+	if better <= worse {
+		t.Errorf("contiguous match scored %v, not higher than %v", better, worse)
+	}
+}
@@ -3,19 +3,136 @@ package edit
 import (
 	"os"
 	"os/exec"
+	"sync"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/edit/highlight"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/parse"
 )
 
-func initHighlighter(appSpec *cli.AppSpec, ev *eval.Evaler) {
-	appSpec.Highlighter = highlight.NewHighlighter(highlight.Config{
+//elvdoc:var highlight:styles
+//
+// A map from the kinds of code regions to the styles used to highlight them,
+// in the same syntax as the arguments of [`styled`](builtin.html#styled), like
+// `'red bold'`. Regions whose kinds are missing from the map or mapped to an
+// empty string are not styled. Changes take effect immediately.
+//
+// The kinds of regions are:
+//
+// -   `bareword`, `single-quoted`, `double-quoted`, `variable`, `wildcard`,
+//     `tilde` and `comment` for the lexical elements of the code;
+//
+// -   `keyword` for keywords of special commands like `if` and `try`;
+//
+// -   `good-command` and `bad-command` for the heads of commands that are found
+//     or not found;
+//
+// -   `error` for code with parse or compilation errors;
+//
+// -   `diagnostic` for references to variables, modules and files that don't
+//     exist, which are found as you type;
+//
+// -   `>`, `>>`, `<`, `?>`, `|`, `?(`, `(`, `)`, `[`, `]`, `{`, `}` and `&`
+//     for punctuation.
+//
+// Example:
+//
+// ```elvish
+// set edit:highlight:styles[variable] = 'blue bold'
+// set edit:highlight:styles[comment] = ''
+// ```
+//
+// @cf edit:highlight:themes
+
+//elvdoc:var highlight:themes
+//
+// A read-only map from names of bundled themes to maps that can be assigned to
+// [`$edit:highlight:styles`](#edithighlightstyles). The themes are `default`,
+// `dark` for terminals with dark backgrounds, and `light` for terminals with
+// light backgrounds. Example:
+//
+// ```elvish
+// set edit:highlight:styles = $edit:highlight:themes[light]
+// ```
+
+func initHighlighter(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	hl := highlight.NewHighlighter(highlight.Config{
 		Check:      func(tree parse.Tree) error { return check(ev, tree) },
 		HasCommand: func(cmd string) bool { return hasCommand(ev, cmd) },
+
+		FindUndefinedVars: ev.FindUndefinedVars,
+		HasModule:         ev.HasModule,
+		HasFile:           hasFile,
 	})
+	appSpec.Highlighter = hl
+
+	var stylesMutex sync.RWMutex
+	styles := themeMap(highlight.Themes["default"])
+	themes := vals.EmptyMap
+	for name, theme := range highlight.Themes {
+		themes = themes.Assoc(name, themeMap(theme))
+	}
+	nb.AddNs("highlight", eval.NsBuilder{
+		"styles": vars.FromSetGet(
+			func(v interface{}) error {
+				m, ok := v.(vals.Map)
+				if !ok {
+					return errs.BadValue{What: "edit:highlight:styles",
+						Valid: "map", Actual: vals.Kind(v)}
+				}
+				theme, err := parseThemeMap(m)
+				if err != nil {
+					return err
+				}
+				parsed, err := highlight.ParseStyles(theme)
+				if err != nil {
+					return err
+				}
+				stylesMutex.Lock()
+				defer stylesMutex.Unlock()
+				styles = m
+				hl.SetStyles(parsed)
+				return nil
+			},
+			func() interface{} {
+				stylesMutex.RLock()
+				defer stylesMutex.RUnlock()
+				return styles
+			}),
+		"themes": vars.NewReadOnly(themes),
+	}.Ns())
+}
+
+func themeMap(theme map[string]string) vals.Map {
+	m := vals.EmptyMap
+	for kind, style := range theme {
+		m = m.Assoc(kind, style)
+	}
+	return m
+}
+
+func parseThemeMap(m vals.Map) (map[string]string, error) {
+	theme := make(map[string]string, m.Len())
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		kind, ok := k.(string)
+		if !ok {
+			return nil, errs.BadValue{What: "key of edit:highlight:styles",
+				Valid: "string", Actual: vals.Kind(k)}
+		}
+		style, ok := v.(string)
+		if !ok {
+			return nil, errs.BadValue{What: "style for " + kind,
+				Valid: "string", Actual: vals.Kind(v)}
+		}
+		theme[kind] = style
+	}
+	return theme, nil
 }
 
 func check(ev *eval.Evaler, tree parse.Tree) error {
@@ -61,41 +178,58 @@ func hasCommand(ev *eval.Evaler, cmd string) bool {
 }
 
 func hasQualifiedFn(ev *eval.Evaler, firstNs string, rest string) bool {
+	_, ok := qualifiedFn(ev, firstNs, rest)
+	return ok
+}
+
+// Finds the function with a qualified name, given the first namespace and the
+// rest of the name.
+func qualifiedFn(ev *eval.Evaler, firstNs string, rest string) (eval.Callable, bool) {
 	if rest == "" {
-		return false
+		return nil, false
 	}
 	modVal, ok := ev.Global().Index(firstNs)
 	if !ok {
 		modVal, ok = ev.Builtin().Index(firstNs)
 		if !ok {
-			return false
+			return nil, false
 		}
 	}
 	mod, ok := modVal.(*eval.Ns)
 	if !ok {
-		return false
+		return nil, false
 	}
 	segs := eval.SplitQNameSegs(rest)
 	for _, seg := range segs[:len(segs)-1] {
 		modVal, ok = mod.Index(seg)
 		if !ok {
-			return false
+			return nil, false
 		}
 		mod, ok = modVal.(*eval.Ns)
 		if !ok {
-			return false
+			return nil, false
 		}
 	}
-	return hasFn(mod, segs[len(segs)-1])
+	return fnIn(mod, segs[len(segs)-1])
 }
 
 func hasFn(ns *eval.Ns, name string) bool {
+	_, ok := fnIn(ns, name)
+	return ok
+}
+
+func fnIn(ns *eval.Ns, name string) (eval.Callable, bool) {
 	fnVar, ok := ns.Index(name + eval.FnSuffix)
 	if !ok {
-		return false
+		return nil, false
 	}
-	_, ok = fnVar.(eval.Callable)
-	return ok
+	fn, ok := fnVar.(eval.Callable)
+	return fn, ok
+}
+
+func hasFile(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func isDirOrExecutable(fname string) bool {
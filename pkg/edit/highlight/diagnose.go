@@ -0,0 +1,99 @@
+package highlight
+
+import (
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// Diagnostic is a problem in the code that is found asynchronously, like a
+// reference to a variable or a file that does not exist. Diagnostics are
+// delivered as late updates, and returned from Highlighter.Get along with the
+// parse and compilation errors.
+type Diagnostic struct {
+	diag.Ranging
+	Message string
+}
+
+// Error returns the message of the diagnostic.
+func (d Diagnostic) Error() string { return d.Message }
+
+// DiagnosticRange returns the range of the code the diagnostic is about.
+func (d Diagnostic) DiagnosticRange() (from, to int) { return d.From, d.To }
+
+// Returns whether any of the callbacks for finding diagnostics is set.
+func hasDiagnosers(cfg Config) bool {
+	return cfg.FindUndefinedVars != nil || cfg.HasModule != nil || cfg.HasFile != nil
+}
+
+// Finds diagnostics in the code. This may be slow, so it is called
+// asynchronously.
+func diagnose(tree parse.Tree, cfg Config) []Diagnostic {
+	var diags []Diagnostic
+	if cfg.FindUndefinedVars != nil {
+		for _, err := range cfg.FindUndefinedVars(tree) {
+			diags = append(diags, Diagnostic{err.Context.Ranging, err.Message})
+		}
+	}
+	if cfg.HasModule != nil || cfg.HasFile != nil {
+		diagnoseForms(tree.Root, cfg, &diags)
+	}
+	return diags
+}
+
+func diagnoseForms(n parse.Node, cfg Config, diags *[]Diagnostic) {
+	if form, ok := n.(*parse.Form); ok {
+		diagnoseForm(form, cfg, diags)
+	}
+	for _, child := range parse.Children(n) {
+		diagnoseForms(child, cfg, diags)
+	}
+}
+
+func diagnoseForm(n *parse.Form, cfg Config, diags *[]Diagnostic) {
+	add := func(r diag.Ranger, message string) {
+		*diags = append(*diags, Diagnostic{r.Range(), message})
+	}
+	if n.Head != nil && sourceText(n.Head) == "use" {
+		if cfg.HasModule != nil && len(n.Args) > 0 {
+			if spec, ok := cmpd.StringLiteral(n.Args[0]); ok && !cfg.HasModule(spec) {
+				add(n.Args[0], "no such module: "+spec)
+			}
+		}
+		return
+	}
+	if cfg.HasFile == nil {
+		return
+	}
+	for _, arg := range n.Args {
+		if path, ok := cmpd.StringLiteral(arg); ok && isPathLike(path) && !cfg.HasFile(path) {
+			add(arg, "no such file or directory: "+path)
+		}
+	}
+	for _, redir := range n.Redirs {
+		if redir.RightIsFd || redir.Right == nil {
+			continue
+		}
+		path, ok := cmpd.StringLiteral(redir.Right)
+		if !ok || path == "" {
+			continue
+		}
+		if redir.Mode == parse.Read {
+			if !cfg.HasFile(path) {
+				add(redir.Right, "no such file: "+path)
+			}
+		} else if dir := filepath.Dir(path); !cfg.HasFile(dir) {
+			add(redir.Right, "no such directory: "+dir)
+		}
+	}
+}
+
+// Returns whether an argument is certainly meant to be a path. Arguments that
+// only contain slashes in the middle are not, since they may be other things
+// like URLs.
+func isPathLike(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../")
+}
@@ -13,6 +13,14 @@ import (
 type Config struct {
 	Check      func(n parse.Tree) error
 	HasCommand func(name string) bool
+	// Callbacks for finding diagnostics, which are called asynchronously. Each
+	// of them may be nil, in which case the corresponding diagnostics are not
+	// found.
+	FindUndefinedVars func(n parse.Tree) []*diag.Error
+	HasModule         func(spec string) bool
+	HasFile           func(path string) bool
+	// Styles used for the regions of the code. If nil, DefaultStyles is used.
+	Styles Styles
 }
 
 // Information collected about a command region, used for asynchronous
@@ -27,9 +35,13 @@ type cmdRegion struct {
 var MaxBlockForLate = 10 * time.Millisecond
 
 // Highlights a piece of Elvish code.
-func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error) {
+func highlight(code string, cfg Config, lateCb func(ui.Text, []error)) (ui.Text, []error) {
 	var errors []error
 	var errorRegions []region
+	styles := cfg.Styles
+	if styles == nil {
+		styles = DefaultStyles
+	}
 
 	tree, errParse := parse.Parse(parse.Source{Name: "[tty]", Code: code}, parse.Config{})
 	if errParse != nil {
@@ -76,10 +88,10 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error)
 				cmdRegions = append(cmdRegions, cmdRegion{len(text), regionCode})
 			} else {
 				// Treat all commands as good commands.
-				styling = stylingForGoodCommand
+				styling = styles[goodCommandKind]
 			}
 		} else {
-			styling = stylingFor[r.typ]
+			styling = styles[r.typ]
 		}
 		seg := &ui.Segment{Text: regionCode}
 		if styling != nil {
@@ -94,35 +106,67 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error)
 		text = append(text, &ui.Segment{Text: code[lastEnd:]})
 	}
 
-	if cfg.HasCommand != nil && len(cmdRegions) > 0 {
-		// Launch a goroutine to style command regions asynchronously.
-		lateCh := make(chan ui.Text)
+	if (cfg.HasCommand != nil && len(cmdRegions) > 0) || hasDiagnosers(cfg) {
+		// Launch a goroutine to style command regions and find diagnostics
+		// asynchronously.
+		lateCh := make(chan lateResult)
 		go func() {
 			newText := text.Clone()
 			for _, cmdRegion := range cmdRegions {
 				var styling ui.Styling
 				if cfg.HasCommand(cmdRegion.cmd) {
-					styling = stylingForGoodCommand
+					styling = styles[goodCommandKind]
 				} else {
-					styling = stylingForBadCommand
+					styling = styles[badCommandKind]
+				}
+				if styling != nil {
+					seg := &newText[cmdRegion.seg]
+					*seg = ui.StyleSegment(*seg, styling)
+				}
+			}
+			var diagErrors []error
+			for _, d := range diagnose(tree, cfg) {
+				if hasRegion(errorRegions, d.From, d.To) {
+					// Already reported as an error, like the first undefined
+					// variable, which is also a compilation error.
+					continue
+				}
+				diagErrors = append(diagErrors, d)
+				if styling := styles[diagnosticKind]; styling != nil {
+					parts := newText.Partition(d.From, d.To)
+					newText = ui.Concat(parts[0], ui.StyleText(parts[1], styling), parts[2])
 				}
-				seg := &newText[cmdRegion.seg]
-				*seg = ui.StyleSegment(*seg, styling)
 			}
-			lateCh <- newText
+			lateCh <- lateResult{newText, diagErrors}
 		}()
-		// Block a short while for the late text to arrive, in order to reduce
-		// flickering. Otherwise, return the text already computed, and pass the
-		// late result to lateCb in another goroutine.
+		// Block a short while for the late result to arrive, in order to
+		// reduce flickering. Otherwise, return the text already computed, and
+		// pass the late result to lateCb in another goroutine.
 		select {
 		case late := <-lateCh:
-			return late, errors
+			return late.text, append(errors, late.errors...)
 		case <-time.After(MaxBlockForLate):
 			go func() {
-				lateCb(<-lateCh)
+				late := <-lateCh
+				lateCb(late.text, append(errors, late.errors...))
 			}()
 			return text, errors
 		}
 	}
 	return text, errors
 }
+
+// Result of asynchronous highlighting.
+type lateResult struct {
+	text   ui.Text
+	errors []error
+}
+
+func hasRegion(regions []region, begin, end int) bool {
+	for _, r := range regions {
+		if r.begin == begin && r.end == end {
+			return true
+		}
+	}
+	return false
+}
@@ -34,7 +34,7 @@ func (hl *Highlighter) Get(code string) (ui.Text, []error) {
 		return hl.state.styledCode, hl.state.errors
 	}
 
-	lateCb := func(styledCode ui.Text) {
+	lateCb := func(styledCode ui.Text, errors []error) {
 		hl.state.Lock()
 		if hl.state.code != code {
 			// Late result was delivered after code has changed. Unlock and
@@ -43,6 +43,7 @@ func (hl *Highlighter) Get(code string) (ui.Text, []error) {
 			return
 		}
 		hl.state.styledCode = styledCode
+		hl.state.errors = errors
 		// The channel send below might block, so unlock the state first.
 		hl.state.Unlock()
 		hl.lates <- struct{}{}
@@ -56,6 +57,17 @@ func (hl *Highlighter) Get(code string) (ui.Text, []error) {
 	return styledCode, errors
 }
 
+// SetStyles changes the styles used for highlighting, and discards the
+// highlighted code computed with the old styles.
+func (hl *Highlighter) SetStyles(styles Styles) {
+	hl.state.Lock()
+	defer hl.state.Unlock()
+	hl.cfg.Styles = styles
+	hl.state.code = ""
+	hl.state.styledCode = nil
+	hl.state.errors = nil
+}
+
 // LateUpdates returns a channel for notifying late updates.
 func (hl *Highlighter) LateUpdates() <-chan struct{} {
 	return hl.lates
@@ -1,7 +1,10 @@
 package highlight
 
 import (
+	"errors"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -72,6 +75,58 @@ func TestHighlighter_ParseErrors(t *testing.T) {
 	})
 }
 
+func TestHighlighter_SetStyles(t *testing.T) {
+	hl := NewHighlighter(Config{})
+	hl.Get("ls $x 'y'")
+
+	styles, err := ParseStyles(map[string]string{
+		"good-command": "blue", "variable": "bold", "single-quoted": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hl.SetStyles(styles)
+
+	got, _ := hl.Get("ls $x 'y'")
+	want := ui.MarkLines(
+		"ls $x 'y'", ui.RuneStylesheet{'/': ui.FgBlue, 'b': ui.Bold},
+		"// bb    ")
+	// Compare the VT strings, since unstyled regions are kept as separate
+	// segments.
+	if got.VTString() != want.VTString() {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseStyles(t *testing.T) {
+	tt.Test(t, tt.Fn("ParseStyles", ParseStyles), tt.Table{
+		Args(map[string]string{"comment": "red"}).
+			Rets(Styles{"comment": ui.FgRed}, nil),
+		Args(map[string]string{"bad-kind": "red"}).
+			Rets(Styles(nil), errors.New("unknown kind of region: bad-kind")),
+		Args(map[string]string{"comment": "bad-style"}).
+			Rets(Styles(nil), errors.New("invalid style for comment: bad-style")),
+	})
+	wantKinds := kindsOf(Themes["default"])
+	for name, theme := range Themes {
+		if _, err := ParseStyles(theme); err != nil {
+			t.Errorf("theme %s is invalid: %v", name, err)
+		}
+		if kinds := kindsOf(theme); !reflect.DeepEqual(kinds, wantKinds) {
+			t.Errorf("theme %s has kinds %v, want %v", name, kinds, wantKinds)
+		}
+	}
+}
+
+// Returns the kinds of regions styled by a theme, sorted.
+func kindsOf(theme map[string]string) []string {
+	kinds := make([]string, 0, len(theme))
+	for kind := range theme {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
 func TestHighlighter_CheckErrors(t *testing.T) {
 	var checkError error
 	// Make a highlighter whose Check callback returns checkError.
@@ -210,6 +265,73 @@ func TestHighlighter_HasCommand_LateResultOutOfOrder(t *testing.T) {
 	}
 }
 
+func TestHighlighter_Diagnostics(t *testing.T) {
+	// Force diagnostics to be delivered synchronously.
+	MaxBlockForLate = testutil.ScaledMs(100)
+	hl := NewHighlighter(Config{
+		FindUndefinedVars: func(tree parse.Tree) []*diag.Error {
+			i := strings.Index(tree.Source.Code, "$y")
+			if i == -1 {
+				return nil
+			}
+			return []*diag.Error{{Message: "variable $y not found",
+				Context: *diag.NewContext("[tty]", tree.Source.Code,
+					diag.Ranging{From: i, To: i + 2})}}
+		},
+		HasModule: func(spec string) bool { return spec == "str" },
+		HasFile:   func(path string) bool { return path == "./a" || path == "." },
+	})
+
+	diagStyles := ui.RuneStylesheet{
+		'v': ui.FgGreen,
+		'_': ui.Underlined,
+		'$': ui.Stylings(ui.FgMagenta, ui.Underlined),
+	}
+	tests := []struct {
+		code      string
+		wantText  ui.Text
+		wantDiags []error
+	}{
+		{
+			code: "use str; use bad",
+			wantText: ui.MarkLines(
+				"use str; use bad", diagStyles,
+				"vvv      vvv ___"),
+			wantDiags: []error{
+				Diagnostic{diag.Ranging{From: 13, To: 16}, "no such module: bad"}},
+		},
+		{
+			code: "echo $y ./a ./b",
+			wantText: ui.MarkLines(
+				"echo $y ./a ./b", diagStyles,
+				"vvvv $$     ___"),
+			wantDiags: []error{
+				Diagnostic{diag.Ranging{From: 5, To: 7}, "variable $y not found"},
+				Diagnostic{diag.Ranging{From: 12, To: 15}, "no such file or directory: ./b"}},
+		},
+		{
+			code: "echo < ./a > x < ./b > d/x",
+			wantText: ui.MarkLines(
+				"echo < ./a > x < ./b > d/x", diagStyles,
+				"vvvv v     v   v ___ v ___"),
+			wantDiags: []error{
+				Diagnostic{diag.Ranging{From: 17, To: 20}, "no such file: ./b"},
+				Diagnostic{diag.Ranging{From: 23, To: 26}, "no such directory: d"}},
+		},
+	}
+	for _, test := range tests {
+		text, diags := hl.Get(test.code)
+		// Compare the VT strings, since unstyled regions are kept as separate
+		// segments.
+		if text.VTString() != test.wantText.VTString() {
+			t.Errorf("got text %s, want %s", text.VTString(), test.wantText.VTString())
+		}
+		if !reflect.DeepEqual(diags, test.wantDiags) {
+			t.Errorf("got diagnostics %v, want %v", diags, test.wantDiags)
+		}
+	}
+}
+
 // Matchers.
 
 type anyMatcher struct{}
@@ -138,6 +138,8 @@ func emitRegionsInForm(n *parse.Form, f func(parse.Node, regionKind, string)) {
 		emitRegionsInFor(n, f)
 	case "try":
 		emitRegionsInTry(n, f)
+	case "match":
+		emitRegionsInMatch(n, f)
 	}
 	if !eval.IsBuiltinSpecial[head] {
 		for i, arg := range n.Args {
@@ -223,6 +225,54 @@ func emitRegionsInTry(n *parse.Form, f func(parse.Node, regionKind, string)) {
 	matchKW("finally")
 }
 
+func emitRegionsInMatch(n *parse.Form, f func(parse.Node, regionKind, string)) {
+	// Highlight rest variables in patterns, and "kind", "if" and "else".
+	matchKW := func(i int, text string) bool {
+		if i < len(n.Args) && sourceText(n.Args[i]) == text {
+			f(n.Args[i], semanticRegion, keywordRegion)
+			return true
+		}
+		return false
+	}
+	for i := 1; i < len(n.Args); i++ {
+		if matchKW(i, "else") {
+			return
+		}
+		emitRegionsInPattern(n.Args[i], f)
+		if matchKW(i+1, "kind") {
+			i += 2
+		}
+		if matchKW(i+1, "if") {
+			i += 2
+		}
+		// Skip the body.
+		i++
+	}
+}
+
+func emitRegionsInPattern(n *parse.Compound, f func(parse.Node, regionKind, string)) {
+	if len(n.Indexings) != 1 {
+		return
+	}
+	pn := n.Indexings[0].Head
+	switch pn.Type {
+	case parse.List:
+		for _, elem := range pn.Elements {
+			if strings.HasPrefix(sourceText(elem), "@") {
+				emitVariableRegion(elem, f)
+			} else {
+				emitRegionsInPattern(elem, f)
+			}
+		}
+	case parse.Map:
+		for _, pair := range pn.MapPairs {
+			if pair.Value != nil {
+				emitRegionsInPattern(pair.Value, f)
+			}
+		}
+	}
+}
+
 func emitRegionsInPrimary(n *parse.Primary, f func(parse.Node, regionKind, string)) {
 	switch n.Type {
 	case parse.Bareword:
@@ -167,6 +167,25 @@ func TestGetRegions(t *testing.T) {
 			{16, 17, lexicalRegion, "{"},
 			{18, 19, lexicalRegion, "}"},
 		}),
+
+		// The "match" special command.
+
+		Args("match x [@a] kind list if y { } else { }").Rets([]region{
+			{0, 5, semanticRegion, commandRegion}, // match
+			{6, 7, lexicalRegion, barewordRegion}, // x
+			{8, 9, lexicalRegion, "["},
+			{9, 11, semanticRegion, variableRegion}, // @a
+			{11, 12, lexicalRegion, "]"},
+			{13, 17, semanticRegion, keywordRegion}, // kind
+			{18, 22, lexicalRegion, barewordRegion}, // list
+			{23, 25, semanticRegion, keywordRegion}, // if
+			{26, 27, lexicalRegion, barewordRegion}, // y
+			{28, 29, lexicalRegion, "{"},
+			{30, 31, lexicalRegion, "}"},
+			{32, 36, semanticRegion, keywordRegion}, // else
+			{37, 38, lexicalRegion, "{"},
+			{39, 40, lexicalRegion, "}"},
+		}),
 	})
 }
 
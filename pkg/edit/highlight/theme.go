@@ -1,37 +1,150 @@
 package highlight
 
-import "src.elv.sh/pkg/ui"
-
-var stylingFor = map[string]ui.Styling{
-	barewordRegion:     nil,
-	singleQuotedRegion: ui.FgYellow,
-	doubleQuotedRegion: ui.FgYellow,
-	variableRegion:     ui.FgMagenta,
-	wildcardRegion:     nil,
-	tildeRegion:        nil,
-
-	commentRegion: ui.FgCyan,
-
-	">":  ui.FgGreen,
-	">>": ui.FgGreen,
-	"<":  ui.FgGreen,
-	"?>": ui.FgGreen,
-	"|":  ui.FgGreen,
-	"?(": ui.Bold,
-	"(":  ui.Bold,
-	")":  ui.Bold,
-	"[":  ui.Bold,
-	"]":  ui.Bold,
-	"{":  ui.Bold,
-	"}":  ui.Bold,
-	"&":  ui.Bold,
-
-	commandRegion: ui.FgGreen,
-	keywordRegion: ui.FgYellow,
-	errorRegion:   ui.Stylings(ui.FgBrightWhite, ui.BgRed),
-}
+import (
+	"fmt"
 
-var (
-	stylingForGoodCommand = ui.FgGreen
-	stylingForBadCommand  = ui.FgRed
+	"src.elv.sh/pkg/ui"
 )
+
+// Styles maps the kinds of regions to the stylings applied to them. The kinds
+// are the types of regions, like "variable" and "comment", the "good-command"
+// and "bad-command" kinds for commands that are found or not found, and the
+// "diagnostic" kind for regions with diagnostics. Regions of kinds missing from
+// the map are not styled.
+type Styles map[string]ui.Styling
+
+const (
+	goodCommandKind = "good-command"
+	badCommandKind  = "bad-command"
+	diagnosticKind  = "diagnostic"
+)
+
+// Themes contains the bundled themes, each mapping the kinds of regions to
+// styles in the same syntax as the argument of the styled builtin.
+var Themes = map[string]map[string]string{
+	"default": {
+		barewordRegion:     "",
+		singleQuotedRegion: "yellow",
+		doubleQuotedRegion: "yellow",
+		variableRegion:     "magenta",
+		wildcardRegion:     "",
+		tildeRegion:        "",
+
+		commentRegion: "cyan",
+
+		">":  "green",
+		">>": "green",
+		"<":  "green",
+		"?>": "green",
+		"|":  "green",
+		"?(": "bold",
+		"(":  "bold",
+		")":  "bold",
+		"[":  "bold",
+		"]":  "bold",
+		"{":  "bold",
+		"}":  "bold",
+		"&":  "bold",
+
+		keywordRegion:   "yellow",
+		errorRegion:     "bright-white bg-red",
+		goodCommandKind: "green",
+		badCommandKind:  "red",
+		diagnosticKind:  "underlined",
+	},
+	// Bright colors that stand out on dark backgrounds.
+	"dark": {
+		barewordRegion:     "",
+		singleQuotedRegion: "bright-yellow",
+		doubleQuotedRegion: "bright-yellow",
+		variableRegion:     "bright-magenta",
+		wildcardRegion:     "bright-blue",
+		tildeRegion:        "bright-blue",
+
+		commentRegion: "bright-black italic",
+
+		">":  "bright-green",
+		">>": "bright-green",
+		"<":  "bright-green",
+		"?>": "bright-green",
+		"|":  "bright-green",
+		"?(": "bold",
+		"(":  "bold",
+		")":  "bold",
+		"[":  "bold",
+		"]":  "bold",
+		"{":  "bold",
+		"}":  "bold",
+		"&":  "bold",
+
+		keywordRegion:   "bright-yellow bold",
+		errorRegion:     "bright-white bg-red",
+		goodCommandKind: "bright-green",
+		badCommandKind:  "bright-red",
+		diagnosticKind:  "underlined",
+	},
+	// Dark colors that are readable on light backgrounds, avoiding yellow and
+	// cyan.
+	"light": {
+		barewordRegion:     "",
+		singleQuotedRegion: "red",
+		doubleQuotedRegion: "red",
+		variableRegion:     "magenta",
+		wildcardRegion:     "blue",
+		tildeRegion:        "blue",
+
+		commentRegion: "bright-black italic",
+
+		">":  "green",
+		">>": "green",
+		"<":  "green",
+		"?>": "green",
+		"|":  "green",
+		"?(": "bold",
+		"(":  "bold",
+		")":  "bold",
+		"[":  "bold",
+		"]":  "bold",
+		"{":  "bold",
+		"}":  "bold",
+		"&":  "bold",
+
+		keywordRegion:   "blue bold",
+		errorRegion:     "white bg-red",
+		goodCommandKind: "green",
+		badCommandKind:  "red",
+		diagnosticKind:  "underlined",
+	},
+}
+
+// DefaultStyles is the parsed default theme.
+var DefaultStyles = mustParseStyles(Themes["default"])
+
+// ParseStyles parses a map from kinds of regions to styles, which are in the
+// same syntax as the argument of the styled builtin. Empty styles are allowed
+// and mean that the regions are not styled.
+func ParseStyles(m map[string]string) (Styles, error) {
+	styles := make(Styles, len(m))
+	for kind, style := range m {
+		if _, ok := Themes["default"][kind]; !ok {
+			return nil, fmt.Errorf("unknown kind of region: %s", kind)
+		}
+		if style == "" {
+			continue
+		}
+		styling := ui.ParseStyling(style)
+		if styling == nil {
+			return nil, fmt.Errorf("invalid style for %s: %s", kind, style)
+		}
+		styles[kind] = styling
+	}
+	return styles, nil
+}
+
+func mustParseStyles(m map[string]string) Styles {
+	styles, err := ParseStyles(m)
+	if err != nil {
+		panic(err)
+	}
+	return styles
+}
@@ -14,6 +14,7 @@ import (
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/tt"
+	"src.elv.sh/pkg/ui"
 )
 
 // High-level sanity test.
@@ -36,6 +37,51 @@ func TestHighlighter(t *testing.T) {
 	)
 }
 
+func TestHighlighter_Diagnostics(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "use bad")
+	f.TestTTY(t,
+		"~> use bad", Styles,
+		"   vvv ___", term.DotHere, "\n",
+		"no such module: bad",
+	)
+
+	// The message is only shown when the cursor is in the region.
+	f.TTYCtrl.Inject(term.K(ui.Left), term.K(ui.Left), term.K(ui.Left), term.K(ui.Left))
+	f.TestTTY(t,
+		"~> use", Styles,
+		"   vvv", term.DotHere, " bad", Styles,
+		" ___",
+	)
+}
+
+func TestHighlightStyles(t *testing.T) {
+	f := setup()
+	defer f.Cleanup()
+
+	evals(f.Evaler, `set edit:highlight:styles[variable] = blue`)
+	feedInput(f.TTYCtrl, "put $true")
+	f.TestTTY(t,
+		"~> put $true", Styles,
+		"   vvv /////", term.DotHere,
+	)
+
+	evals(f.Evaler,
+		`set edit:highlight:styles = $edit:highlight:themes[light]`,
+		`var light = (eq $edit:highlight:styles $edit:highlight:themes[light])`,
+		`var bad-kind = (bool ?(set edit:highlight:styles[bad-kind] = red))`,
+		`var bad-style = (bool ?(set edit:highlight:styles[comment] = bad-style))`,
+		`var bad-value = (bool ?(set edit:highlight:styles = [&comment=[red]]))`)
+	testGlobals(t, f.Evaler, map[string]interface{}{
+		"light":     true,
+		"bad-kind":  false,
+		"bad-style": false,
+		"bad-value": false,
+	})
+}
+
 // Fine-grained tests against the highlighter.
 
 func TestCheck(t *testing.T) {
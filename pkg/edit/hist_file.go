@@ -0,0 +1,298 @@
+package edit
+
+// Reading and writing the history files of other shells.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// An entry of a history file.
+type histFileEntry struct {
+	storedefs.Cmd
+	// Information about the command, or nil if there is none.
+	Info *storedefs.CmdInfo
+}
+
+type histFileFormat struct {
+	parse func(string) ([]histFileEntry, error)
+	write func(io.Writer, []histFileEntry) error
+}
+
+var histFileFormats = map[string]histFileFormat{
+	"bash":  {parseBashHistory, writeBashHistory},
+	"zsh":   {parseZshHistory, writeZshHistory},
+	"fish":  {parseFishHistory, writeFishHistory},
+	"jsonl": {parseJSONLinesHistory, writeJSONLinesHistory},
+}
+
+func infoWithStart(sec int64) *storedefs.CmdInfo {
+	return &storedefs.CmdInfo{Start: time.Unix(sec, 0)}
+}
+
+// Returns the start time of the entry in seconds since the Unix epoch, and
+// whether it is known.
+func (e histFileEntry) startUnix() (int64, bool) {
+	if e.Info == nil || e.Info.Start.IsZero() {
+		return 0, false
+	}
+	return e.Info.Start.Unix(), true
+}
+
+var bashTimestamp = regexp.MustCompile(`^#(\d+)$`)
+
+// Parses bash history. When HISTTIMEFORMAT is set, bash writes the timestamp
+// of each command in a comment line before it; in that case, all the lines up
+// to the next timestamp are part of the command, since they may come from a
+// multi-line command saved with the lithist option.
+func parseBashHistory(s string) ([]histFileEntry, error) {
+	lines := splitHistLines(s)
+	hasTimestamps := false
+	for _, line := range lines {
+		if bashTimestamp.MatchString(line) {
+			hasTimestamps = true
+			break
+		}
+	}
+	var entries []histFileEntry
+	var cur *histFileEntry
+	for _, line := range lines {
+		if m := bashTimestamp.FindStringSubmatch(line); m != nil {
+			var e histFileEntry
+			// A timestamp of 0 is written by writeBashHistory for commands
+			// whose start time is unknown.
+			if sec, _ := strconv.ParseInt(m[1], 10, 64); sec != 0 {
+				e.Info = infoWithStart(sec)
+			}
+			entries = append(entries, e)
+			cur = &entries[len(entries)-1]
+		} else if hasTimestamps && cur != nil {
+			if cur.Text == "" {
+				cur.Text = line
+			} else {
+				cur.Text += "\n" + line
+			}
+		} else if line != "" {
+			entries = append(entries, histFileEntry{Cmd: storedefs.Cmd{Text: line}})
+		}
+	}
+	return dropEmptyEntries(entries), nil
+}
+
+// Writes bash history with a timestamp before every command, using 0 for
+// commands whose start time is unknown. The timestamps delimit commands, so
+// multi-line commands are kept intact when read back, like bash does with the
+// lithist option.
+func writeBashHistory(w io.Writer, entries []histFileEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		sec, _ := e.startUnix()
+		fmt.Fprintf(bw, "#%d\n", sec)
+		bw.WriteString(e.Text)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+var zshExtended = regexp.MustCompile(`^: *(\d+):(\d+);`)
+
+// Parses zsh history, in either the extended format, where each entry looks
+// like ": <start>:<duration>;<command>", or the plain format. A line ending in
+// a backslash continues on the next line.
+func parseZshHistory(s string) ([]histFileEntry, error) {
+	lines := splitHistLines(unmetafyZsh(s))
+	var entries []histFileEntry
+	for i := 0; i < len(lines); i++ {
+		text := lines[i]
+		for strings.HasSuffix(text, `\`) && i+1 < len(lines) {
+			i++
+			text = text[:len(text)-1] + "\n" + lines[i]
+		}
+		var e histFileEntry
+		if m := zshExtended.FindStringSubmatch(text); m != nil {
+			sec, _ := strconv.ParseInt(m[1], 10, 64)
+			dur, _ := strconv.ParseInt(m[2], 10, 64)
+			e.Info = infoWithStart(sec)
+			e.Info.Duration = time.Duration(dur) * time.Second
+			text = text[len(m[0]):]
+		}
+		e.Text = text
+		entries = append(entries, e)
+	}
+	return dropEmptyEntries(entries), nil
+}
+
+// Writes zsh history, using the extended format for commands whose start time
+// is known and the plain format for other commands.
+func writeZshHistory(w io.Writer, entries []histFileEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		if sec, ok := e.startUnix(); ok {
+			fmt.Fprintf(bw, ": %d:%d;", sec, int64(e.Info.Duration/time.Second))
+		}
+		bw.WriteString(metafyZsh(strings.ReplaceAll(e.Text, "\n", "\\\n")))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// Zsh saves bytes that have special meanings internally as zshMeta followed
+// by the byte XOR'ed with 32.
+const zshMeta = 0x83
+
+func isZshMeta(b byte) bool { return b == 0 || zshMeta <= b && b <= 0xa2 }
+
+func unmetafyZsh(s string) string {
+	if strings.IndexByte(s, zshMeta) == -1 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			sb.WriteByte(s[i] ^ 32)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+func metafyZsh(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isZshMeta(s[i]) {
+			sb.WriteByte(zshMeta)
+			sb.WriteByte(s[i] ^ 32)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// Parses fish history, which is in a subset of YAML. Only the "cmd" and "when"
+// fields of each entry are used.
+func parseFishHistory(s string) ([]histFileEntry, error) {
+	var entries []histFileEntry
+	for _, line := range splitHistLines(s) {
+		if strings.HasPrefix(line, "- cmd: ") {
+			text := unescapeFish(strings.TrimPrefix(line, "- cmd: "))
+			entries = append(entries, histFileEntry{Cmd: storedefs.Cmd{Text: text}})
+		} else if strings.HasPrefix(line, "  when: ") && len(entries) > 0 {
+			sec, err := strconv.ParseInt(strings.TrimPrefix(line, "  when: "), 10, 64)
+			if err == nil {
+				entries[len(entries)-1].Info = infoWithStart(sec)
+			}
+		}
+	}
+	return dropEmptyEntries(entries), nil
+}
+
+func writeFishHistory(w io.Writer, entries []histFileEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(bw, "- cmd: %s\n", escapeFish(e.Text))
+		if sec, ok := e.startUnix(); ok {
+			fmt.Fprintf(bw, "  when: %d\n", sec)
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	fishEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	fishUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escapeFish(s string) string   { return fishEscaper.Replace(s) }
+func unescapeFish(s string) string { return fishUnescaper.Replace(s) }
+
+// An entry in the JSON lines format, using the same keys as the output of
+// edit:command-history.
+type jsonLinesEntry struct {
+	ID        int      `json:"id,omitempty"`
+	Cmd       string   `json:"cmd"`
+	Dir       string   `json:"dir,omitempty"`
+	Start     *float64 `json:"start,omitempty"`
+	Duration  *float64 `json:"duration,omitempty"`
+	Exception string   `json:"exception,omitempty"`
+	Session   string   `json:"session,omitempty"`
+}
+
+func parseJSONLinesHistory(s string) ([]histFileEntry, error) {
+	var entries []histFileEntry
+	for i, line := range splitHistLines(s) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var j jsonLinesEntry
+		err := json.Unmarshal([]byte(line), &j)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		e := histFileEntry{Cmd: storedefs.Cmd{Text: j.Cmd}}
+		if j.Start != nil || j.Duration != nil || j.Dir != "" || j.Exception != "" || j.Session != "" {
+			e.Info = &storedefs.CmdInfo{
+				Dir: j.Dir, Exception: j.Exception, Session: j.Session}
+			if j.Start != nil {
+				e.Info.Start = time.Unix(0, int64(*j.Start*float64(time.Second)))
+			}
+			if j.Duration != nil {
+				e.Info.Duration = time.Duration(*j.Duration * float64(time.Second))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return dropEmptyEntries(entries), nil
+}
+
+func writeJSONLinesHistory(w io.Writer, entries []histFileEntry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, e := range entries {
+		j := jsonLinesEntry{ID: e.Seq, Cmd: e.Text}
+		if e.Info != nil {
+			j.Dir, j.Exception, j.Session = e.Info.Dir, e.Info.Exception, e.Info.Session
+			if !e.Info.Start.IsZero() {
+				start := float64(e.Info.Start.UnixNano()) / float64(time.Second)
+				j.Start = &start
+			}
+			duration := e.Info.Duration.Seconds()
+			j.Duration = &duration
+		}
+		err := enc.Encode(j)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Splits the content of a history file into lines, dropping the trailing
+// newline.
+func splitHistLines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func dropEmptyEntries(entries []histFileEntry) []histFileEntry {
+	filtered := entries[:0]
+	for _, e := range entries {
+		if strings.TrimSpace(e.Text) != "" {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,138 @@
+package edit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+func entry(text string) histFileEntry {
+	return histFileEntry{Cmd: storedefs.Cmd{Text: text}}
+}
+
+func entryAt(text string, sec int64, dur time.Duration) histFileEntry {
+	return histFileEntry{
+		Cmd:  storedefs.Cmd{Text: text},
+		Info: &storedefs.CmdInfo{Start: time.Unix(sec, 0), Duration: dur}}
+}
+
+var parseHistFileTests = []struct {
+	name   string
+	format string
+	input  string
+	want   []histFileEntry
+}{
+	{"bash", "bash",
+		"echo foo\n\nls -l\n",
+		[]histFileEntry{entry("echo foo"), entry("ls -l")}},
+	{"bash with timestamps", "bash",
+		"#1600000000\necho foo\n#1600000010\nfor x in a b; do\necho $x\ndone\n",
+		[]histFileEntry{
+			entryAt("echo foo", 1600000000, 0),
+			entryAt("for x in a b; do\necho $x\ndone", 1600000010, 0)}},
+	{"zsh", "zsh",
+		"echo foo\nls\n",
+		[]histFileEntry{entry("echo foo"), entry("ls")}},
+	{"zsh extended", "zsh",
+		": 1600000000:3;echo foo\n: 1600000010:0;echo a\\\necho b\n",
+		[]histFileEntry{
+			entryAt("echo foo", 1600000000, 3*time.Second),
+			entryAt("echo a\necho b", 1600000010, 0)}},
+	{"zsh metafied", "zsh",
+		": 1600000000:0;echo \xe4\xbd\x83\x80\n",
+		[]histFileEntry{entryAt("echo 你", 1600000000, 0)}},
+	{"fish", "fish",
+		"- cmd: echo foo\n  when: 1600000000\n  paths:\n    - foo\n" +
+			`- cmd: echo a\necho \\b` + "\n  when: 1600000010\n- cmd: ls\n",
+		[]histFileEntry{
+			entryAt("echo foo", 1600000000, 0),
+			entryAt("echo a\necho \\b", 1600000010, 0),
+			entry("ls")}},
+	{"jsonl", "jsonl",
+		`{"id":1,"cmd":"echo foo"}` + "\n" +
+			`{"id":2,"cmd":"false","dir":"/tmp","start":1600000000,"duration":2,"exception":"failed","session":"s"}` + "\n",
+		[]histFileEntry{
+			entry("echo foo"),
+			{Cmd: storedefs.Cmd{Text: "false"}, Info: &storedefs.CmdInfo{
+				Dir: "/tmp", Start: time.Unix(1600000000, 0),
+				Duration: 2 * time.Second, Exception: "failed", Session: "s"}}}},
+}
+
+func TestParseHistFile(t *testing.T) {
+	for _, test := range parseHistFileTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := histFileFormats[test.format].parse(test.input)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseHistFile_BadJSONLines(t *testing.T) {
+	_, err := histFileFormats["jsonl"].parse(`{"cmd":"echo"}` + "\n{\n")
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Errorf("got error %v, want error on line 2", err)
+	}
+}
+
+var writeHistFileEntries = []histFileEntry{
+	entry("echo foo"),
+	entryAt("echo a\necho \\b 你", 1600000000, 3*time.Second),
+}
+
+var writeHistFileTests = []struct {
+	format string
+	want   string
+}{
+	{"bash", "#0\necho foo\n#1600000000\necho a\necho \\b 你\n"},
+	{"zsh", "echo foo\n: 1600000000:3;echo a\\\necho \\b \xe4\xbd\x83\x80\n"},
+	{"fish", "- cmd: echo foo\n- cmd: echo a\\necho \\\\b 你\n  when: 1600000000\n"},
+	{"jsonl", `{"cmd":"echo foo"}` + "\n" +
+		`{"cmd":"echo a\necho \\b 你","start":1600000000,"duration":3}` + "\n"},
+}
+
+func TestWriteHistFile(t *testing.T) {
+	for _, test := range writeHistFileTests {
+		t.Run(test.format, func(t *testing.T) {
+			var sb strings.Builder
+			err := histFileFormats[test.format].write(&sb, writeHistFileEntries)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if got := sb.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHistFile_RoundTrip(t *testing.T) {
+	entries := []histFileEntry{
+		entry("for x in a b; do\necho $x\ndone"),
+		entry("echo foo"),
+		entryAt("echo a\necho b", 1600000000, 0),
+	}
+	for _, name := range []string{"bash", "zsh", "fish", "jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			format := histFileFormats[name]
+			var sb strings.Builder
+			if err := format.write(&sb, entries); err != nil {
+				t.Fatalf("write: got error %v", err)
+			}
+			got, err := format.parse(sb.String())
+			if err != nil {
+				t.Fatalf("parse: got error %v", err)
+			}
+			if !reflect.DeepEqual(got, entries) {
+				t.Errorf("got %v, want %v", got, entries)
+			}
+		})
+	}
+}
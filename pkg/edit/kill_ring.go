@@ -0,0 +1,151 @@
+package edit
+
+// Implementation of the kill ring.
+
+import (
+	"sync"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+)
+
+//elvdoc:var kill-ring
+//
+// A list of the most recently killed texts, the most recent one first. Texts
+// are added to the kill ring by the `edit:kill-*` commands, except
+// `edit:kill-rune-left` and `edit:kill-rune-right`. When a kill immediately
+// follows another kill, the two texts are joined into one entry.
+//
+// This variable is read-only.
+//
+// @cf edit:yank edit:yank-pop
+
+//elvdoc:fn yank
+//
+// Inserts the most recently killed text at the dot.
+//
+// @cf edit:kill-ring edit:yank-pop
+
+//elvdoc:fn yank-pop
+//
+// Replaces the text just inserted by `edit:yank` or `edit:yank-pop` with the
+// previous entry in the kill ring, going back to the most recent entry after
+// the oldest one. Does nothing if the previous change to the code buffer was
+// not a yank.
+//
+// @cf edit:kill-ring edit:yank
+
+// Maximum number of entries in the kill ring.
+const killRingSize = 60
+
+type killRing struct {
+	mu sync.Mutex
+	// Killed texts, the most recent one last.
+	entries []string
+	// Whether the last change to the buffer was a kill, and the buffer after
+	// it. Used for joining consecutive kills.
+	lastIsKill bool
+	afterKill  tk.CodeBuffer
+	// Whether the last change to the buffer was a yank, the buffer after it,
+	// where the yanked text starts, and which entry was yanked, counting from
+	// the most recent one. Used for yank-pop.
+	lastIsYank bool
+	afterYank  tk.CodeBuffer
+	yankFrom   int
+	yankIndex  int
+}
+
+// Kills the text between the dot and the position the mover moves the dot to,
+// and adds the text to the kill ring.
+func (kr *killRing) kill(buf *tk.CodeBuffer, m pureMover) {
+	before := *buf
+	newDot := m(buf.Content, buf.Dot)
+	left := newDot < buf.Dot
+	var text string
+	if left {
+		text = buf.Content[newDot:buf.Dot]
+	} else {
+		text = buf.Content[buf.Dot:newDot]
+	}
+	makeKill(m)(buf)
+	if text == "" {
+		return
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if kr.lastIsKill && before == kr.afterKill && len(kr.entries) > 0 {
+		last := &kr.entries[len(kr.entries)-1]
+		if left {
+			*last = text + *last
+		} else {
+			*last += text
+		}
+	} else {
+		kr.entries = append(kr.entries, text)
+		if len(kr.entries) > killRingSize {
+			kr.entries = kr.entries[len(kr.entries)-killRingSize:]
+		}
+	}
+	kr.lastIsKill, kr.afterKill = true, *buf
+	kr.lastIsYank = false
+}
+
+func (kr *killRing) yank(buf *tk.CodeBuffer) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if len(kr.entries) == 0 {
+		return
+	}
+	kr.yankFrom = buf.Dot
+	buf.InsertAtDot(kr.entries[len(kr.entries)-1])
+	kr.lastIsYank, kr.afterYank, kr.yankIndex = true, *buf, 0
+	kr.lastIsKill = false
+}
+
+func (kr *killRing) yankPop(buf *tk.CodeBuffer) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if !kr.lastIsYank || *buf != kr.afterYank || len(kr.entries) == 0 {
+		return
+	}
+	kr.yankIndex = (kr.yankIndex + 1) % len(kr.entries)
+	text := kr.entries[len(kr.entries)-1-kr.yankIndex]
+	buf.Content = buf.Content[:kr.yankFrom] + text + buf.Content[buf.Dot:]
+	buf.Dot = kr.yankFrom + len(text)
+	kr.afterYank = *buf
+}
+
+func (kr *killRing) list() vals.List {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	l := vals.EmptyList
+	for i := len(kr.entries) - 1; i >= 0; i-- {
+		l = l.Cons(kr.entries[i])
+	}
+	return l
+}
+
+func initKillRing(app cli.App, nb eval.NsBuilder) {
+	kr := &killRing{}
+	nb.Add("kill-ring", vars.FromGet(func() interface{} { return kr.list() }))
+
+	mutate := func(f func(*tk.CodeBuffer)) func() {
+		return func() {
+			app.CodeArea().MutateState(func(s *tk.CodeAreaState) { f(&s.Buffer) })
+		}
+	}
+	fns := map[string]interface{}{
+		"yank":     mutate(kr.yank),
+		"yank-pop": mutate(kr.yankPop),
+	}
+	for name, m := range killRingBuiltinsData {
+		// Make a lexically scoped copy of m.
+		m2 := m
+		fns[name] = mutate(func(buf *tk.CodeBuffer) { kr.kill(buf, m2) })
+	}
+	nb.AddGoFns("<edit>", fns)
+}
@@ -0,0 +1,89 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+var killRingTests = []struct {
+	name      string
+	bufBefore tk.CodeBuffer
+	code      []string
+	bufAfter  tk.CodeBuffer
+	wantRing  vals.List
+}{
+	{
+		"kill and yank",
+		tk.CodeBuffer{Content: "echo foo", Dot: 8},
+		[]string{"edit:kill-word-left", "edit:move-dot-sol", "edit:yank"},
+		tk.CodeBuffer{Content: "fooecho ", Dot: 3},
+		vals.MakeList("foo"),
+	},
+	{
+		"consecutive kills to the left are prepended",
+		tk.CodeBuffer{Content: "echo foo bar", Dot: 12},
+		[]string{"edit:kill-word-left", "edit:kill-word-left"},
+		tk.CodeBuffer{Content: "echo ", Dot: 5},
+		vals.MakeList("foo bar"),
+	},
+	{
+		"consecutive kills to the right are appended",
+		tk.CodeBuffer{Content: "echo foo\nbar", Dot: 5},
+		[]string{"edit:kill-word-right", "edit:kill-line-right"},
+		tk.CodeBuffer{Content: "echo ", Dot: 5},
+		vals.MakeList("foo\nbar"),
+	},
+	{
+		"non-consecutive kills are separate entries",
+		tk.CodeBuffer{Content: "echo foo bar", Dot: 12},
+		[]string{"edit:kill-word-left", "edit:move-dot-left", "edit:kill-word-left"},
+		tk.CodeBuffer{Content: "echo  ", Dot: 5},
+		vals.MakeList("foo", "bar"),
+	},
+	{
+		"killing runes does not touch the kill ring",
+		tk.CodeBuffer{Content: "ab", Dot: 2},
+		[]string{"edit:kill-rune-left"},
+		tk.CodeBuffer{Content: "a", Dot: 1},
+		vals.EmptyList,
+	},
+	{
+		"yank-pop cycles through the kill ring",
+		tk.CodeBuffer{Content: "a b c", Dot: 5},
+		[]string{
+			"edit:kill-word-left", "edit:move-dot-left",
+			"edit:kill-word-left", "edit:move-dot-left",
+			"edit:kill-word-left",
+			"edit:yank", "edit:yank-pop", "edit:yank-pop", "edit:yank-pop"},
+		tk.CodeBuffer{Content: "a  ", Dot: 1},
+		vals.MakeList("a", "b", "c"),
+	},
+	{
+		"yank-pop does nothing after other changes",
+		tk.CodeBuffer{Content: "a b", Dot: 3},
+		[]string{
+			"edit:kill-word-left", "edit:move-dot-left",
+			"edit:kill-word-left",
+			"edit:yank", "edit:insert-at-dot x", "edit:yank-pop"},
+		tk.CodeBuffer{Content: "ax ", Dot: 2},
+		vals.MakeList("a", "b"),
+	},
+}
+
+func TestKillRing(t *testing.T) {
+	for _, test := range killRingTests {
+		t.Run(test.name, func(t *testing.T) {
+			f := setup()
+			defer f.Cleanup()
+			f.SetCodeBuffer(test.bufBefore)
+			evals(f.Evaler, test.code...)
+			if buf := f.Editor.app.CodeArea().CopyState().Buffer; buf != test.bufAfter {
+				t.Errorf("got buf %v, want %v", buf, test.bufAfter)
+			}
+			evals(f.Evaler, "ring = $edit:kill-ring")
+			testGlobal(t, f.Evaler, "ring", test.wantRing)
+		})
+	}
+}
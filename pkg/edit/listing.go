@@ -15,12 +15,30 @@ import (
 	"src.elv.sh/pkg/store/storedefs"
 )
 
-func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore histutil.Store, nb eval.NsBuilder) {
+//elvdoc:var listing:fuzzy
+//
+// Whether the filters of history listing, location, lastcmd, navigation and
+// completion modes match fuzzily, defaults to `$false`.
+//
+// When this is on, a word in the filter matches a string if the characters of
+// the word appear in the string in order, not necessarily next to each other.
+// The matches are sorted by how well they match: characters that are next to
+// each other, at the beginning of words, or of the same case as in the filter
+// make better matches.
+//
+// Regardless of this setting, the matched characters are highlighted.
+
+// Initializes the listing modes, and returns the filter configuration that
+// other modes with lists can use.
+func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore histutil.Store, nb eval.NsBuilder) mode.FilterSpec {
 	bindingVar := newBindingVar(emptyBindingsMap)
+	fuzzyVar := newBoolVar(false)
+	rankingFilter := makeRankingFilterSpec(func() bool { return fuzzyVar.Get().(bool) })
 	app := ed.app
 	nb.AddNs("listing",
 		eval.NsBuilder{
 			"binding": bindingVar,
+			"fuzzy":   fuzzyVar,
 		}.AddGoFns("<edit:listing>:", map[string]interface{}{
 			"accept":       func() { listingAccept(app) },
 			"accept-close": func() { listingAcceptClose(app) },
@@ -38,23 +56,32 @@ func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore his
 			*/
 		}).Ns())
 
-	initHistlist(ed, ev, histStore, bindingVar, nb)
-	initLastcmd(ed, ev, histStore, bindingVar, nb)
-	initLocation(ed, ev, st, bindingVar, nb)
+	initHistlist(ed, ev, histStore, bindingVar, rankingFilter, nb)
+	initLastcmd(ed, ev, histStore, bindingVar, rankingFilter, nb)
+	initLocation(ed, ev, st, bindingVar, rankingFilter, nb)
+	return rankingFilter
 }
 
-var filterSpec = mode.FilterSpec{
-	Maker: func(f string) func(string) bool {
-		q, _ := filter.Compile(f)
-		if q == nil {
-			return func(string) bool { return true }
-		}
-		return q.Match
-	},
-	Highlighter: filter.Highlight,
+// Returns a filter configuration that ranks items, matching fuzzily when the
+// fuzzy function returns true.
+func makeRankingFilterSpec(fuzzy func() bool) mode.FilterSpec {
+	return mode.FilterSpec{
+		Ranker: func(f string) func(string) (int, []int, bool) {
+			compile := filter.Compile
+			if fuzzy() {
+				compile = filter.CompileFuzzy
+			}
+			q, _ := compile(f)
+			if q == nil {
+				return func(string) (int, []int, bool) { return 0, nil, true }
+			}
+			return q.Rank
+		},
+		Highlighter: filter.Highlight,
+	}
 }
 
-func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, fs mode.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	dedup := newBoolVar(true)
@@ -69,7 +96,7 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 					Dedup: func() bool {
 						return dedup.Get().(bool)
 					},
-					Filter: filterSpec,
+					Filter: fs,
 				})
 				startMode(ed.app, w, err)
 			},
@@ -81,7 +108,7 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 		}).Ns())
 }
 
-func initLastcmd(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+func initLastcmd(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, fs mode.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	nb.AddNs("lastcmd",
@@ -90,12 +117,12 @@ func initLastcmd(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBi
 		}.AddGoFn("<edit:lastcmd>", "start", func() {
 			// TODO: Specify wordifier
 			w, err := mode.NewLastcmd(ed.app, mode.LastcmdSpec{
-				Bindings: bindings, Store: histStore})
+				Bindings: bindings, Store: histStore, Filter: fs})
 			startMode(ed.app, w, err)
 		}).Ns())
 }
 
-func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBindingVar vars.PtrVar, fs mode.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	pinnedVar := newListVar(vals.EmptyList)
 	hiddenVar := newListVar(vals.EmptyList)
@@ -117,7 +144,7 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 				IteratePinned:     adaptToIterateString(pinnedVar),
 				IterateHidden:     adaptToIterateString(hiddenVar),
 				IterateWorkspaces: workspaceIterator,
-				Filter:            filterSpec,
+				Filter:            fs,
 			})
 			startMode(ed.app, w, err)
 		}).Ns())
@@ -50,9 +50,10 @@ func TestHistlistAddon(t *testing.T) {
 		"~> \n",
 		" HISTORY (dedup on)  l", Styles,
 		"********************  ", term.DotHere, "\n",
-		"   3 ls\n",
+		"   3 ls", Styles,
+		"     _ ", "\n",
 		"   4 LS                                           ", Styles,
-		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		"+++++U++++++++++++++++++++++++++++++++++++++++++++",
 	)
 
 	// Filtering is case-sensitive when filter is not all lower case.
@@ -62,7 +63,29 @@ func TestHistlistAddon(t *testing.T) {
 		" HISTORY (dedup on)  L", Styles,
 		"********************  ", term.DotHere, "\n",
 		"   4 LS                                           ", Styles,
-		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		"+++++U++++++++++++++++++++++++++++++++++++++++++++",
+	)
+}
+
+func TestHistlistAddon_Fuzzy(t *testing.T) {
+	f := setup(
+		rc("edit:listing:fuzzy = $true"),
+		storeOp(func(s storedefs.Store) {
+			s.AddCmd("gcc")
+			s.AddCmd("echo lorem")
+			s.AddCmd("git checkout")
+		}))
+	defer f.Cleanup()
+
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl), term.K('g'), term.K('c'))
+	f.TestTTY(t,
+		"~> \n",
+		" HISTORY (dedup on)  gc", Styles,
+		"********************   ", term.DotHere, "\n",
+		"   3 git checkout", Styles,
+		"     _   _       ", "\n",
+		"   1 gcc                                          ", Styles,
+		"+++++UU+++++++++++++++++++++++++++++++++++++++++++",
 	)
 }
 
@@ -99,7 +99,7 @@ func convertNavWidthRatio(v interface{}) [3]int {
 	return ret
 }
 
-func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+func initNavigation(ed *Editor, ev *eval.Evaler, fs mode.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	widthRatioVar := newListVar(vals.MakeList(1.0, 3.0, 4.0))
@@ -124,7 +124,7 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 					WidthRatio: func() [3]int {
 						return convertNavWidthRatio(widthRatioVar.Get())
 					},
-					Filter: filterSpec,
+					Filter: fs,
 				})
 				startMode(app, w, nil)
 			},
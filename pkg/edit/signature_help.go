@@ -0,0 +1,226 @@
+package edit
+
+// Implementation of the signature help shown under the code.
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+	"src.elv.sh/pkg/ui"
+)
+
+//elvdoc:var signature-help
+//
+// Whether to show the signature of the function being called, defaults to
+// `$true`.
+//
+// When this is on and the dot is in a command whose head is a function
+// defined in Elvish or a builtin function, the editor shows its arguments and
+// options under the code, with the argument at the dot in bold. For functions
+// defined in Elvish, the first sentence of the comment before the definition
+// is shown too. Example:
+//
+// ```elvish-transcript
+// ~> # Greets someone.
+//    fn greet [name &greeting=hello]{ echo $greeting $name }
+// ~> greet world
+// greet name &greeting=hello  # Greets someone.
+// ```
+//
+// Functions implemented in Go don't have names for their arguments, so the
+// kinds of the arguments are shown instead, like `string` and `number`.
+//
+// The signature is only shown or updated after the dot has stayed at the same
+// place for a short while, so that typing is not slowed down.
+
+const signatureHelperLatesBufferSize = 1
+
+// How long the code and dot have to stay unchanged before the signature help
+// is computed. It can be changed for tests.
+var signatureHelpDelay = 200 * time.Millisecond
+
+func initSignatureHelp(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	enabled := newBoolVar(true)
+	appSpec.StatusLine = newSignatureHelper(
+		func() bool { return enabled.Get().(bool) },
+		func(code string, dot int) ui.Text { return signatureHelp(ev, code, dot) })
+	nb.Add("signature-help", enabled)
+}
+
+// An implementation of cli.StatusLine that computes the signature help after
+// the code and dot have stayed unchanged for signatureHelpDelay.
+type signatureHelper struct {
+	enabled func() bool
+	compute func(code string, dot int) ui.Text
+	lates   chan struct{}
+
+	mu sync.Mutex
+	// The code and dot of the last call to Get.
+	code  string
+	dot   int
+	timer *time.Timer
+	// The last computed result, and the code and dot it was computed for.
+	result    ui.Text
+	resultFor string
+	resultDot int
+}
+
+func newSignatureHelper(enabled func() bool, compute func(string, int) ui.Text) *signatureHelper {
+	return &signatureHelper{
+		enabled: enabled, compute: compute,
+		lates: make(chan struct{}, signatureHelperLatesBufferSize)}
+}
+
+// Get returns the signature help for the given code and dot, scheduling a
+// computation if there isn't one for them already.
+//
+// While waiting for the computation, the last result is returned if the code
+// before its dot is unchanged, since the dot is very likely to be in the same
+// command. This keeps the signature help shown while the user is typing
+// arguments.
+func (sh *signatureHelper) Get(code string, dot int) ui.Text {
+	if code == "" || !sh.enabled() {
+		return nil
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if code == sh.resultFor && dot == sh.resultDot {
+		return sh.result
+	}
+	if code != sh.code || dot != sh.dot {
+		sh.code, sh.dot = code, dot
+		if sh.timer != nil {
+			sh.timer.Stop()
+		}
+		sh.timer = time.AfterFunc(signatureHelpDelay, func() { sh.work(code, dot) })
+	}
+	if sh.resultDot <= dot && strings.HasPrefix(code, sh.resultFor[:sh.resultDot]) {
+		return sh.result
+	}
+	return nil
+}
+
+func (sh *signatureHelper) work(code string, dot int) {
+	result := sh.compute(code, dot)
+	sh.mu.Lock()
+	if code != sh.code || dot != sh.dot {
+		// The code or dot has changed; the computation for them is scheduled.
+		sh.mu.Unlock()
+		return
+	}
+	sh.result, sh.resultFor, sh.resultDot = result, code, dot
+	sh.mu.Unlock()
+	select {
+	case sh.lates <- struct{}{}:
+	default:
+		// An update is already pending.
+	}
+}
+
+// LateUpdates returns a channel for notifying late updates.
+func (sh *signatureHelper) LateUpdates() <-chan struct{} {
+	return sh.lates
+}
+
+// Returns the signature help for the command at the dot, or nil if the command
+// is not a function.
+func signatureHelp(ev *eval.Evaler, code string, dot int) ui.Text {
+	tree, _ := parse.Parse(parse.Source{Name: "[tty]", Code: code}, parse.Config{})
+	form := formAt(tree.Root, dot)
+	if form == nil || form.Head == nil || dot <= form.Head.Range().To {
+		return nil
+	}
+	head, ok := cmpd.StringLiteral(form.Head)
+	if !ok {
+		return nil
+	}
+	fn, ok := functionOf(ev, head)
+	if !ok {
+		return nil
+	}
+	sig, ok := eval.SignatureOf(fn)
+	if !ok {
+		return nil
+	}
+
+	// Find the argument or option at the dot.
+	currentArg, currentOpt := -1, ""
+	for _, opt := range form.Opts {
+		if r := opt.Range(); r.From <= dot && dot <= r.To {
+			currentOpt, _ = cmpd.StringLiteral(opt.Key)
+		}
+	}
+	if currentOpt == "" {
+		i, n := len(form.Args), len(form.Args)
+		for j, arg := range form.Args {
+			if r := arg.Range(); dot <= r.To {
+				i = j
+				if dot < r.From {
+					// The dot is in the space before the argument, where a
+					// new argument is being inserted.
+					n++
+				}
+				break
+			}
+		}
+		if i == len(form.Args) {
+			n++
+		}
+		currentArg = sig.ArgIndex(i, n)
+	}
+
+	text := ui.T(head)
+	for i, arg := range sig.Args {
+		text = append(text, &ui.Segment{Text: " "})
+		if i == currentArg {
+			text = append(text, ui.T(arg, ui.Bold)...)
+		} else {
+			text = append(text, ui.T(arg)...)
+		}
+	}
+	for _, opt := range sig.Opts {
+		text = append(text, &ui.Segment{Text: " "})
+		if opt.Name == currentOpt {
+			text = append(text, ui.T(opt.String(), ui.Bold)...)
+		} else {
+			text = append(text, ui.T(opt.String())...)
+		}
+	}
+	if sig.Summary != "" {
+		text = append(text, ui.T("  # "+sig.Summary)...)
+	}
+	return text
+}
+
+// Returns the innermost form containing the position.
+func formAt(n parse.Node, pos int) *parse.Form {
+	r := n.Range()
+	if pos < r.From || r.To < pos {
+		return nil
+	}
+	for _, child := range parse.Children(n) {
+		if form := formAt(child, pos); form != nil {
+			return form
+		}
+	}
+	form, _ := n.(*parse.Form)
+	return form
+}
+
+// Finds the function that a command refers to. Unlike hasCommand, it doesn't
+// look for external commands.
+func functionOf(ev *eval.Evaler, cmd string) (eval.Callable, bool) {
+	first, rest := eval.SplitQName(cmd)
+	if rest != "" {
+		return qualifiedFn(ev, first, rest)
+	}
+	if fn, ok := fnIn(ev.Global(), first); ok {
+		return fn, true
+	}
+	return fnIn(ev.Builtin(), first)
+}
@@ -0,0 +1,97 @@
+package edit
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	// Make the signature help show up within the timeout of TestTTY.
+	oldDelay := signatureHelpDelay
+	signatureHelpDelay = 0
+	defer func() { signatureHelpDelay = oldDelay }()
+	f := setup(rc("# Does things.\nfn f [a b &opt=foo]{ }"))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "f x ")
+	f.TestTTY(t,
+		"~> f x ", Styles,
+		"   v   ", term.DotHere, "\n",
+		"f a b &opt=foo  # Does things.", Styles,
+		"    b",
+	)
+
+	// The dot moves to the first argument.
+	f.TTYCtrl.Inject(term.K(ui.Left), term.K(ui.Left))
+	f.TestTTY(t,
+		"~> f ", Styles,
+		"   v ", term.DotHere, "x \n",
+		"f a b &opt=foo  # Does things.", Styles,
+		"  b",
+	)
+}
+
+func TestSignatureHelp_Disabled(t *testing.T) {
+	f := setup(rc("edit:signature-help = $false", "fn f [a]{ }"))
+	defer f.Cleanup()
+
+	feedInput(f.TTYCtrl, "f x")
+	// Wait for longer than the delay, since the signature help would only
+	// show up after it.
+	time.Sleep(signatureHelpDelay + testutil.ScaledMs(50))
+	f.TestTTY(t,
+		"~> f x", Styles,
+		"   v  ", term.DotHere,
+	)
+}
+
+var signatureHelpTests = []struct {
+	name string
+	code string
+	dot  int
+	want ui.Text
+}{
+	{"first arg", "f x", 3,
+		ui.Concat(ui.T("f "), ui.T("a", ui.Bold), ui.T(" @rest c &opt=foo"))},
+	{"new arg", "f x ", 4,
+		ui.Concat(ui.T("f a @rest "), ui.T("c", ui.Bold), ui.T(" &opt=foo"))},
+	{"middle arg", "f x y z", 4,
+		ui.Concat(ui.T("f a "), ui.T("@rest", ui.Bold), ui.T(" c &opt=foo"))},
+	{"last arg", "f x y z", 7,
+		ui.Concat(ui.T("f a @rest "), ui.T("c", ui.Bold), ui.T(" &opt=foo"))},
+	{"option", "f &opt=x", 8,
+		ui.Concat(ui.T("f a @rest c "), ui.T("&opt=foo", ui.Bold))},
+	{"nested form", "echo (f x)", 9,
+		ui.Concat(ui.T("f "), ui.T("a", ui.Bold), ui.T(" @rest c &opt=foo"))},
+	{"builtin", "str:join x", 10,
+		ui.Concat(ui.T("str:join "), ui.T("string", ui.Bold), ui.T(" inputs?"))},
+	{"dot in head", "f x", 1, nil},
+	{"not a function", "echox x", 7, nil},
+	{"special command", "if x", 4, nil},
+}
+
+func TestSignatureHelp_Compute(t *testing.T) {
+	ev := eval.NewEvaler()
+	ev.AddModule("str", eval.NsBuilder{}.AddGoFns("str:", map[string]interface{}{
+		"join": func(sep string, inputs eval.Inputs) {},
+	}).Ns())
+	code := "use str; fn f [a @rest c &opt=foo]{ }"
+	err := ev.Eval(parse.Source{Name: "[test]", Code: code}, eval.EvalCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range signatureHelpTests {
+		t.Run(test.name, func(t *testing.T) {
+			got := signatureHelp(ev, test.code, test.dot)
+			if got.VTString() != test.want.VTString() {
+				t.Errorf("got %q, want %q", got.VTString(), test.want.VTString())
+			}
+		})
+	}
+}
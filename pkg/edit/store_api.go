@@ -2,12 +2,17 @@ package edit
 
 import (
 	"errors"
+	"io"
+	"io/ioutil"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/parse/parseutil"
 	"src.elv.sh/pkg/store/storedefs"
 )
@@ -26,6 +31,22 @@ var errStoreOffline = errors.New("store offline")
 // sequence number of the command, and a `cmd` key for the text of the command.
 // If `&cmd-only` is `$true`, only the text of each command is output.
 //
+// Commands executed in the interactive REPL also have the following keys, which
+// are absent from entries added before this information was recorded:
+//
+// -   `dir`: The working directory when the command was started.
+//
+// -   `start`: The time when the command was started, as a number of seconds
+//     since the Unix epoch.
+//
+// -   `duration`: How long the command took to run, in seconds.
+//
+// -   `exception`: The message of the exception the command terminated with,
+//     or `$nil` if it terminated normally.
+//
+// -   `session`: An identifier of the session the command was run in; see
+//     `$edit:session-id`.
+//
 // All entries are output by default. If `&dedup` is `$true`, only the most
 // recent instance of each command (when comparing just the `cmd` key) is
 // output.
@@ -41,13 +62,23 @@ var errStoreOffline = errors.New("store offline")
 // edit:command-history &cmd-only &newest-first | take 1
 // ```
 //
-// @cf builtin:dir-history
+// The following finds commands run in the current directory that failed:
+//
+// ```elvish
+// edit:command-history | each [c]{
+//   if (and (has-key $c exception) $c[exception] (eq $c[dir] $pwd)) {
+//     put $c[cmd]
+//   }
+// }
+// ```
+//
+// @cf builtin:dir-history edit:session-id
 
 type cmdhistOpt struct{ CmdOnly, Dedup, NewestFirst bool }
 
 func (o *cmdhistOpt) SetDefaultOptions() {}
 
-func commandHistory(opts cmdhistOpt, fuser histutil.Store, out eval.ValueOutput) error {
+func commandHistory(opts cmdhistOpt, fuser histutil.Store, st storedefs.Store, out eval.ValueOutput) error {
 	if fuser == nil {
 		return errStoreOffline
 	}
@@ -68,8 +99,16 @@ func commandHistory(opts cmdhistOpt, fuser histutil.Store, out eval.ValueOutput)
 			}
 		}
 	} else {
+		infos, err := cmdInfos(st, cmds)
+		if err != nil {
+			return err
+		}
 		for _, cmd := range cmds {
-			err := out.Put(vals.MakeMap("id", cmd.Seq, "cmd", cmd.Text))
+			m := vals.MakeMap("id", cmd.Seq, "cmd", cmd.Text)
+			if info, ok := infos[cmd.Seq]; ok {
+				m = assocCmdInfo(m, info)
+			}
+			err := out.Put(m)
 			if err != nil {
 				return err
 			}
@@ -78,6 +117,36 @@ func commandHistory(opts cmdhistOpt, fuser histutil.Store, out eval.ValueOutput)
 	return nil
 }
 
+// Returns the information about the given commands, keyed by their sequence
+// numbers.
+func cmdInfos(st storedefs.Store, cmds []storedefs.Cmd) (map[int]storedefs.CmdInfo, error) {
+	if st == nil || len(cmds) == 0 {
+		return nil, nil
+	}
+	from, upto := cmds[0].Seq, cmds[0].Seq+1
+	for _, cmd := range cmds {
+		if cmd.Seq < from {
+			from = cmd.Seq
+		} else if cmd.Seq >= upto {
+			upto = cmd.Seq + 1
+		}
+	}
+	return st.CmdInfos(from, upto)
+}
+
+func assocCmdInfo(m vals.Map, info storedefs.CmdInfo) vals.Map {
+	var exception interface{}
+	if info.Exception != "" {
+		exception = info.Exception
+	}
+	return m.
+		Assoc("dir", info.Dir).
+		Assoc("start", float64(info.Start.UnixNano())/float64(time.Second)).
+		Assoc("duration", info.Duration.Seconds()).
+		Assoc("exception", exception).
+		Assoc("session", info.Session)
+}
+
 func dedupCmds(allCmds []storedefs.Cmd, newestFirst bool) []storedefs.Cmd {
 	// Capacity allocation below is based on some personal empirical observation.
 	uniqCmds := make([]storedefs.Cmd, 0, len(allCmds)/4)
@@ -102,6 +171,176 @@ func reverseCmds(cmds []storedefs.Cmd) {
 	}
 }
 
+//elvdoc:fn import-history
+//
+// ```elvish
+// edit:import-history &format=''
+// ```
+//
+// Reads the history file of another shell from the byte input, adds the
+// commands in it to the command history, and outputs the number of commands
+// added.
+//
+// The `&format` option must be one of:
+//
+// -   `bash`: The format of `~/.bash_history`. If the file has timestamps
+//     (when `$HISTTIMEFORMAT` is set in bash), they are kept.
+//
+// -   `zsh`: The format of `~/.zsh_history`, with or without the
+//     `EXTENDED_HISTORY` option. Timestamps and durations are kept.
+//
+// -   `fish`: The format of `~/.local/share/fish/fish_history`. Timestamps are
+//     kept.
+//
+// -   `jsonl`: The format written by `edit:export-history &format=jsonl`. All
+//     the information about the commands is kept.
+//
+// Commands that are already in the command history are skipped, and so are
+// all but the last instance of each command in the input, so importing the
+// same file again does nothing. The imported commands are added after existing
+// ones, so it is best to import history before using Elvish interactively.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> edit:import-history &format=bash < ~/.bash_history
+// ▶ (num 1024)
+// ```
+//
+// @cf edit:export-history
+
+type importHistoryOpt struct{ Format string }
+
+func (o *importHistoryOpt) SetDefaultOptions() {}
+
+func importHistory(opts importHistoryOpt, st storedefs.Store, in io.Reader) (int, error) {
+	if st == nil {
+		return 0, errStoreOffline
+	}
+	format, err := getHistFileFormat(opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	content, err := ioutil.ReadAll(in)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := format.parse(string(content))
+	if err != nil {
+		return 0, err
+	}
+
+	upto, err := st.NextCmdSeq()
+	if err != nil {
+		return 0, err
+	}
+	existing, err := st.CmdsWithSeq(0, upto)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(existing)+len(entries))
+	for _, cmd := range existing {
+		seen[cmd.Text] = true
+	}
+	// Keep the last instance of each command in the input.
+	var texts []string
+	var infos []*storedefs.CmdInfo
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !seen[entries[i].Text] {
+			seen[entries[i].Text] = true
+			texts = append(texts, entries[i].Text)
+			infos = append(infos, entries[i].Info)
+		}
+	}
+	if len(texts) == 0 {
+		return 0, nil
+	}
+	reverseStrings(texts)
+	infoMap := make(map[int]storedefs.CmdInfo)
+	for i, info := range infos {
+		if info != nil {
+			// The infos are in reverse order of the commands.
+			infoMap[len(infos)-1-i] = *info
+		}
+	}
+	_, err = st.AddCmds(texts, infoMap)
+	if err != nil {
+		return 0, err
+	}
+	return len(texts), nil
+}
+
+func reverseStrings(ss []string) {
+	for i, j := 0, len(ss)-1; i < j; i, j = i+1, j-1 {
+		ss[i], ss[j] = ss[j], ss[i]
+	}
+}
+
+//elvdoc:fn export-history
+//
+// ```elvish
+// edit:export-history &format=jsonl
+// ```
+//
+// Writes the command history to the byte output, in oldest to newest order.
+//
+// The `&format` option can be `bash`, `zsh` or `fish`, to write in the format
+// of the history file of that shell (see `edit:import-history`), or `jsonl`
+// (the default), to write one JSON object per line with the same keys as the
+// maps output by `edit:command-history`.
+//
+// Example:
+//
+// ```elvish
+// edit:export-history &format=zsh > ~/.zsh_history
+// ```
+//
+// @cf edit:import-history edit:command-history
+
+type exportHistoryOpt struct{ Format string }
+
+func (o *exportHistoryOpt) SetDefaultOptions() { o.Format = "jsonl" }
+
+func exportHistory(opts exportHistoryOpt, st storedefs.Store, out io.Writer) error {
+	if st == nil {
+		return errStoreOffline
+	}
+	format, err := getHistFileFormat(opts.Format)
+	if err != nil {
+		return err
+	}
+	upto, err := st.NextCmdSeq()
+	if err != nil {
+		return err
+	}
+	cmds, err := st.CmdsWithSeq(0, upto)
+	if err != nil {
+		return err
+	}
+	infos, err := st.CmdInfos(0, upto)
+	if err != nil {
+		return err
+	}
+	entries := make([]histFileEntry, len(cmds))
+	for i, cmd := range cmds {
+		entries[i].Cmd = cmd
+		if info, ok := infos[cmd.Seq]; ok {
+			entries[i].Info = &info
+		}
+	}
+	return format.write(out, entries)
+}
+
+func getHistFileFormat(name string) (histFileFormat, error) {
+	format, ok := histFileFormats[name]
+	if !ok {
+		return histFileFormat{}, errs.BadValue{
+			What:  "&format option",
+			Valid: "bash, zsh, fish or jsonl", Actual: parse.Quote(name)}
+	}
+	return format, nil
+}
+
 //elvdoc:fn insert-last-word
 //
 // Inserts the last word of the last command.
@@ -122,10 +361,23 @@ func insertLastWord(app cli.App, histStore histutil.Store) error {
 	return nil
 }
 
-func initStoreAPI(app cli.App, nb eval.NsBuilder, fuser histutil.Store) {
+func initStoreAPI(app cli.App, nb eval.NsBuilder, fuser *histStore, st storedefs.Store) {
 	nb.AddGoFns("<edit>", map[string]interface{}{
 		"command-history": func(fm *eval.Frame, opts cmdhistOpt) error {
-			return commandHistory(opts, fuser, fm.ValueOutput())
+			return commandHistory(opts, fuser, st, fm.ValueOutput())
+		},
+		"import-history": func(fm *eval.Frame, opts importHistoryOpt) (int, error) {
+			n, err := importHistory(opts, st, fm.InputFile())
+			if n > 0 {
+				// Make the imported commands visible in this session.
+				if err := fuser.FastForward(); err != nil {
+					return n, err
+				}
+			}
+			return n, err
+		},
+		"export-history": func(fm *eval.Frame, opts exportHistoryOpt) error {
+			return exportHistory(opts, st, fm.ByteOutput())
 		},
 		"insert-last-word": func() { insertLastWord(app, fuser) },
 	})
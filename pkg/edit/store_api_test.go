@@ -2,6 +2,7 @@ package edit
 
 import (
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval/vals"
@@ -82,6 +83,74 @@ func TestCommandHistory(t *testing.T) {
 	testThatOutputErrorIsBubbled(t, f, "edit:command-history &cmd-only")
 }
 
+func TestCommandHistory_CmdInfo(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo")
+		s.AddCmd("false")
+		s.SetCmdInfo(2, storedefs.CmdInfo{
+			Dir: "/tmp", Start: time.Unix(1600000000, 500000000),
+			Duration: 250 * time.Millisecond, Exception: "failed",
+			Session: "session"})
+	}))
+	defer f.Cleanup()
+
+	evals(f.Evaler, `@cmds = (edit:command-history)`)
+	testGlobal(t, f.Evaler, "cmds",
+		vals.MakeList(
+			cmdMap(1, "echo foo"),
+			cmdMap(2, "false").
+				Assoc("dir", "/tmp").Assoc("start", 1600000000.5).
+				Assoc("duration", 0.25).Assoc("exception", "failed").
+				Assoc("session", "session")))
+}
+
+func TestImportHistory(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo")
+	}))
+	defer f.Cleanup()
+
+	zshHistory := `": 1600000000:3;echo bar\n: 1600000001:0;echo foo\n: 1600000002:1;echo bar\n"`
+	evals(f.Evaler,
+		`n = (print `+zshHistory+` | edit:import-history &format=zsh)`,
+		`@cmds = (edit:command-history &cmd-only)`)
+	testGlobal(t, f.Evaler, "n", 1)
+	testGlobal(t, f.Evaler, "cmds", vals.MakeList("echo foo", "echo bar"))
+	wantInfo := storedefs.CmdInfo{Start: time.Unix(1600000002, 0), Duration: time.Second}
+	if info, err := f.Store.CmdInfo(2); !info.Start.Equal(wantInfo.Start) || info.Duration != wantInfo.Duration || err != nil {
+		t.Errorf("CmdInfo(2) => (%v, %v), want (%v, nil)", info, err, wantInfo)
+	}
+
+	// Importing the same history again adds nothing.
+	evals(f.Evaler, `n = (print `+zshHistory+` | edit:import-history &format=zsh)`)
+	testGlobal(t, f.Evaler, "n", 0)
+
+	evals(f.Evaler, `ret = (bool ?(print echo | edit:import-history &format=csh))`)
+	testGlobal(t, f.Evaler, "ret", false)
+}
+
+func TestExportHistory(t *testing.T) {
+	f := setup(storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo")
+		s.AddCmd("echo bar")
+		s.SetCmdInfo(2, storedefs.CmdInfo{
+			Start: time.Unix(1600000000, 0), Duration: 3 * time.Second})
+	}))
+	defer f.Cleanup()
+
+	evals(f.Evaler,
+		`zsh = (edit:export-history &format=zsh | slurp)`,
+		`@jsonl = (edit:export-history | from-json)`)
+	testGlobal(t, f.Evaler, "zsh", "echo foo\n: 1600000000:3;echo bar\n")
+	testGlobal(t, f.Evaler, "jsonl", vals.MakeList(
+		vals.MakeMap("id", 1.0, "cmd", "echo foo"),
+		vals.MakeMap("id", 2.0, "cmd", "echo bar",
+			"start", 1600000000.0, "duration", 3.0)))
+
+	evals(f.Evaler, `ret = (bool ?(edit:export-history &format=csh))`)
+	testGlobal(t, f.Evaler, "ret", false)
+}
+
 func cmdMap(id int, cmd string) vals.Map {
 	return vals.MakeMap("id", id, "cmd", cmd)
 }
@@ -10,8 +10,6 @@ import (
 
 // Command and process control.
 
-// TODO(xiaq): Document "fg".
-
 func init() {
 	addBuiltinFns(map[string]interface{}{
 		// Command resolution
@@ -20,6 +18,8 @@ func init() {
 		"search-external": searchExternal,
 
 		// Process control
+		"jobs": jobs,
+		"bg":   bg,
 		"fg":   fg,
 		"exec": execFn,
 		"exit": exit,
@@ -88,6 +88,87 @@ func searchExternal(cmd string) (string, error) {
 	return exec.LookPath(cmd)
 }
 
+//elvdoc:fn jobs
+//
+// ```elvish
+// jobs
+// ```
+//
+// Output a map for each job in the job table, with the following fields:
+//
+// -   `num`: The job number, which can be used with [`bg`](#bg) and
+//     [`fg`](#fg).
+//
+// -   `state`: Either `running` or `stopped`.
+//
+// -   `pgid`: The process group ID of the external commands of the job, or 0
+//     if the job has not started any external command.
+//
+// -   `cmd`: The source code of the pipeline.
+//
+// The job table contains all the background jobs, and foreground jobs that
+// have been stopped. When Elvish is run interactively, the external commands
+// in a foreground pipeline can be stopped by pressing <kbd>Ctrl-Z</kbd>; the
+// pipeline then becomes a stopped job and Elvish returns to the prompt.
+//
+// Example (your output will differ):
+//
+// ```elvish-transcript
+// ~> vim foo.txt
+// job 1 stopped: vim foo.txt
+// ~> sleep 100 &
+// ~> jobs
+// ▶ [&num=(num 1) &state=stopped &pgid=(num 37502) &cmd='vim foo.txt']
+// ▶ [&num=(num 2) &state=running &pgid=(num 0) &cmd='sleep 100 &']
+// ```
+//
+// Note that only external commands can be stopped. Elvish code in a stopped
+// job keeps running until it has to wait for a stopped external command.
+//
+// @cf bg fg num-bg-jobs
+
+func jobs(fm *Frame) error {
+	out := fm.ValueOutput()
+	for _, j := range fm.Evaler.jobs.list() {
+		err := out.Put(j.info())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//elvdoc:fn bg
+//
+// ```elvish
+// bg $job?
+// ```
+//
+// Continue a stopped job in the background. The job can be specified by its
+// number, with an optional `%` prefix (like `1` or `%1`), or as `%%` or `%+`,
+// which refer to the job with the largest number. If `$job` is omitted, it also
+// defaults to the job with the largest number.
+//
+// This command always raises an exception on Windows with the message "not
+// supported on Windows".
+//
+// @cf fg jobs
+
+//elvdoc:fn fg
+//
+// ```elvish
+// fg $job?
+// ```
+//
+// Put a job in the foreground, continuing it if it is stopped, and wait for it
+// to finish or stop again. The exceptions thrown by the job, if any, are
+// rethrown. The job is specified in the same way as [`bg`](#bg).
+//
+// This command always raises an exception on Windows with the message "not
+// supported on Windows".
+//
+// @cf bg jobs
+
 //elvdoc:fn exit
 //
 // ```elvish
@@ -3,22 +3,16 @@
 package eval
 
 import (
-	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
 	"syscall"
 
 	"src.elv.sh/pkg/env"
-	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
-	"src.elv.sh/pkg/sys"
 )
 
-// ErrNotInSameProcessGroup is thrown when the process IDs passed to fg are not
-// in the same process group.
-var ErrNotInSameProcessGroup = errors.New("not in the same process group")
-
 //elvdoc:fn exec
 //
 // ```elvish
@@ -68,51 +62,29 @@ func decSHLVL() {
 	os.Setenv(env.SHLVL, strconv.Itoa(i-1))
 }
 
-func fg(pids ...int) error {
-	if len(pids) == 0 {
-		return errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: -1, Actual: len(pids)}
-	}
-	var thepgid int
-	for i, pid := range pids {
-		pgid, err := syscall.Getpgid(pid)
-		if err != nil {
-			return err
-		}
-		if i == 0 {
-			thepgid = pgid
-		} else if pgid != thepgid {
-			return ErrNotInSameProcessGroup
-		}
+func bg(fm *Frame, specs ...string) error {
+	j, err := fm.Evaler.jobs.find(specs)
+	if err != nil {
+		return err
 	}
+	j.toBackground()
+	return continueJob(j)
+}
 
-	err := sys.Tcsetpgrp(0, thepgid)
+func fg(fm *Frame, specs ...string) error {
+	j, err := fm.Evaler.jobs.find(specs)
 	if err != nil {
 		return err
 	}
-
-	errors := make([]Exception, len(pids))
-
-	for i, pid := range pids {
-		err := syscall.Kill(pid, syscall.SIGCONT)
-		if err != nil {
-			errors[i] = &exception{err, nil}
-		}
+	j.toForeground()
+	fmt.Fprintln(fm.ErrorFile(), j.source)
+	err = putJobInFg(j)
+	if err != nil {
+		return err
 	}
-
-	for i, pid := range pids {
-		if errors[i] != nil {
-			continue
-		}
-		var ws syscall.WaitStatus
-		_, err = syscall.Wait4(pid, &ws, syscall.WUNTRACED, nil)
-		if err != nil {
-			errors[i] = &exception{err, nil}
-		} else {
-			// TODO find command name
-			errors[i] = &exception{NewExternalCmdExit(
-				"[pid "+strconv.Itoa(pid)+"]", ws, pid), nil}
-		}
+	err = continueJob(j)
+	if err != nil {
+		return err
 	}
-
-	return MakePipelineError(errors)
+	return fm.waitForegroundJob(j)
 }
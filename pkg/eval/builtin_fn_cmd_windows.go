@@ -8,6 +8,10 @@ func execFn(...interface{}) error {
 	return errNotSupportedOnWindows
 }
 
-func fg(...int) error {
+func bg(*Frame, ...string) error {
+	return errNotSupportedOnWindows
+}
+
+func fg(*Frame, ...string) error {
 	return errNotSupportedOnWindows
 }
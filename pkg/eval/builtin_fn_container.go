@@ -11,6 +11,7 @@ import (
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/persistent/hashmap"
 )
 
@@ -20,7 +21,8 @@ func init() {
 	addBuiltinFns(map[string]interface{}{
 		"ns": nsFn,
 
-		"make-map": makeMap,
+		"make-map":    makeMap,
+		"record-type": recordType,
 
 		"range":  rangeFn,
 		"repeat": repeat,
@@ -134,6 +136,95 @@ func makeMap(input Inputs) (vals.Map, error) {
 	return m, errMakeMap
 }
 
+//elvdoc:fn record-type
+//
+// ```elvish
+// record-type $name $fields
+// ```
+//
+// Declares a record type named `$name`, and outputs a function that
+// constructs records of the type. The keys of the `$fields` map are the names
+// of the fields of the record type, and the values are their default values.
+//
+// The constructor takes the values of the fields as options; fields that are
+// not given get their default values. It is an error to pass an option that is
+// not a field. Records behave like maps with the given fields, sorted by name.
+// Their kind, as reported by [`kind-of`](#kind-of), is `$name`.
+//
+// Assigning to a field of a record with [`assoc`](#assoc) creates a new record
+// of the same type, and it is an error to assign to a field that does not
+// exist. Two records are equal if they have the same type and equal fields;
+// record types with the same name and fields declared separately are
+// different types.
+//
+// Examples:
+//
+// ```elvish-transcript
+// ~> var point~ = (record-type point [&x=(num 0) &y=(num 0)])
+// ~> var p = (point &x=(num 1))
+// ~> put $p
+// ▶ [&x=(num 1) &y=(num 0)]
+// ~> kind-of $p
+// ▶ point
+// ~> assoc $p y (num 2)
+// ▶ [&x=(num 1) &y=(num 2)]
+// ~> assoc $p z (num 2)
+// Exception: no such key: z
+// [tty 3], line 1: assoc $p z (num 2)
+// ~> point &z=(num 2)
+// Exception: unsupported option: z
+// [tty 4], line 1: point &z=(num 2)
+// ```
+
+func recordType(name string, fields vals.Map) (Callable, error) {
+	if name == "" {
+		return nil, errs.BadValue{
+			What: "record type name", Valid: "non-empty string", Actual: "empty string"}
+	}
+	names := make([]string, 0, fields.Len())
+	for it := fields.Iterator(); it.HasElem(); it.Next() {
+		k, _ := it.Elem()
+		field, ok := k.(string)
+		if !ok {
+			return nil, errs.BadValue{
+				What: "field name", Valid: "string", Actual: vals.Kind(k)}
+		}
+		if field == "" {
+			return nil, errs.BadValue{
+				What: "field name", Valid: "non-empty string", Actual: "empty string"}
+		}
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	defaults := make([]interface{}, len(names))
+	for i, field := range names {
+		defaults[i], _ = fields.Index(field)
+	}
+	t := vals.NewRecordType(name, names)
+
+	return NewGoFn(name, func(opts RawOptions) (vals.Record, error) {
+		var unsupported []string
+		for k := range opts {
+			if !t.HasField(k) {
+				unsupported = append(unsupported, parse.Quote(k))
+			}
+		}
+		if len(unsupported) > 0 {
+			sort.Strings(unsupported)
+			return vals.Record{}, UnsupportedOptionsError{unsupported}
+		}
+		values := make([]interface{}, len(names))
+		for i, field := range names {
+			if v, ok := opts[field]; ok {
+				values[i] = v
+			} else {
+				values[i] = defaults[i]
+			}
+		}
+		return t.New(values), nil
+	}), nil
+}
+
 //elvdoc:fn range
 //
 // ```elvish
@@ -48,6 +48,42 @@ func TestMakeMap(t *testing.T) {
 var maxInt = 1<<((unsafe.Sizeof(0)*8)-1) - 1
 var maxDenseIntInFloat = float64(1 << 53)
 
+func TestRecordType(t *testing.T) {
+	Test(t,
+		That("var p~ = (record-type point [&x=(num 0) &y=(num 0)])",
+			"repr (p &y=(num 2)); kind-of (p)").
+			Prints("[&x=(num 0) &y=(num 2)]\n").Puts("point"),
+		That("var p~ = (record-type point [&x=(num 0) &y=(num 0)])",
+			"var a = (p &x=(num 1))",
+			"put $a[x] (keys $a) (has-key $a z) (repr $a)").
+			Puts(1, "x", "y", false, "[&x=(num 1) &y=(num 0)]"),
+		// Equality.
+		That("var p~ = (record-type point [&x=a])",
+			"eq (p) (p); eq (p) (p &x=b); eq (p) [&x=a]").
+			Puts(true, false, false),
+		That("var p~ = (record-type point [&x=a])",
+			"var q~ = (record-type point [&x=a])",
+			"eq (p) (q)").
+			Puts(false),
+		// Assoc creates a new record and validates the field.
+		That("var p~ = (record-type point [&x=a])",
+			"var a = (p)",
+			"var b = (assoc $a x b)",
+			"put $a[x] $b[x] (kind-of $b)").
+			Puts("a", "b", "point"),
+		That("var p~ = (record-type point [&x=a])", "assoc (p) y b").
+			Throws(vals.NoSuchKey("y"), "assoc (p) y b"),
+		// Unknown fields are rejected by the constructor.
+		That("var p~ = (record-type point [&x=a])", "p &y=b &z=c").
+			Throws(UnsupportedOptionsError{[]string{"y", "z"}}, "p &y=b &z=c"),
+		// Bad declarations.
+		That("record-type '' [&x=a]").Throws(errs.BadValue{
+			What: "record type name", Valid: "non-empty string", Actual: "empty string"}),
+		That("record-type point [&(num 1)=a]").Throws(errs.BadValue{
+			What: "field name", Valid: "string", Actual: "number"}),
+	)
+}
+
 func TestRange(t *testing.T) {
 	Test(t,
 		That("range 3").Puts(0, 1, 2),
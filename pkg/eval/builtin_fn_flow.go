@@ -1,10 +1,19 @@
 package eval
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 )
 
@@ -21,6 +30,9 @@ func init() {
 		"return":      returnFn,
 		"break":       breakFn,
 		"continue":    continueFn,
+		"defer":       deferFn,
+		// Cancellation.
+		"with-timeout": withTimeout,
 		// Iterations.
 		"each":  each,
 		"peach": peach,
@@ -145,7 +157,7 @@ func each(fm *Frame, f Callable, inputs Inputs) error {
 //elvdoc:fn peach
 //
 // ```elvish
-// peach $f $input-list?
+// peach &num-workers=(num 0) &ordered=$false &keep-going=$false $f $input-list?
 // ```
 //
 // Calls `$f` on all inputs, possibly in parallel.
@@ -176,28 +188,110 @@ func each(fm *Frame, f Callable, inputs Inputs) error {
 // ▶ (num 1328)
 // ```
 //
+// The behavior of `peach` can be changed with the following options:
+//
+// -   If `&num-workers` is positive, at most that many calls of `$f` run at the
+//     same time, and `peach` reads more inputs only when a call finishes. The
+//     default value 0 means no limit.
+//
+// -   If `&ordered` is true, the outputs of each call of `$f` are buffered, and
+//     written in the same order as the inputs. The byte outputs of a call are
+//     written before its value outputs.
+//
+// -   If `&keep-going` is true, an exception thrown by `$f` does not terminate
+//     `peach` early. After all the inputs have been processed, `peach` throws
+//     one exception whose reason has a `type` field of `peach` and a `failures`
+//     field, a list of maps with an `input` and an `exception` field, in the
+//     same order as the inputs.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> range 1 6 | peach &num-workers=2 &ordered [x]{ sleep (* (rand) 0.1); put $x }
+// ▶ (num 1)
+// ▶ (num 2)
+// ▶ (num 3)
+// ▶ (num 4)
+// ▶ (num 5)
+// ~> var e = ?(peach &keep-going [x]{ if (> $x 2) { fail bad } } [1 2 3 4])
+// ~> each [f]{ put $f[input] } $e[reason][failures]
+// ▶ 3
+// ▶ 4
+// ```
+//
 // This command is intended for homogeneous processing of possibly unbound data. If
 // you need to do a fixed number of heterogeneous things in parallel, use
 // `run-parallel`.
 //
 // @cf each run-parallel
 
-func peach(fm *Frame, f Callable, inputs Inputs) error {
+type peachOpts struct {
+	NumWorkers int
+	Ordered    bool
+	KeepGoing  bool
+}
+
+func (*peachOpts) SetDefaultOptions() {}
+
+func peach(fm *Frame, opts peachOpts, f Callable, inputs Inputs) error {
+	if opts.NumWorkers < 0 {
+		return errs.OutOfRange{What: "num-workers",
+			ValidLow: "0", ValidHigh: "inf", Actual: strconv.Itoa(opts.NumWorkers)}
+	}
 	var wg sync.WaitGroup
 	var broken int32
 	var errMu sync.Mutex
 	var err error
+	var failures []indexedPeachFailure
 
+	// Limits the number of running calls when &num-workers is positive.
+	var workers chan struct{}
+	if opts.NumWorkers > 0 {
+		workers = make(chan struct{}, opts.NumWorkers)
+	}
+	var out *orderedOutput
+	if opts.Ordered {
+		out = &orderedOutput{fm: fm, pending: make(map[int]bufferedOutput)}
+	}
+
+	nextIndex := 0
 	inputs(func(v interface{}) {
 		if atomic.LoadInt32(&broken) != 0 {
 			return
 		}
+		if workers != nil {
+			workers <- struct{}{}
+		}
+		index := nextIndex
+		nextIndex++
 		wg.Add(1)
 		go func() {
 			newFm := fm.fork("closure of peach")
 			newFm.ports[0] = DummyInputPort
-			ex := f.Call(newFm, []interface{}{v}, NoOpts)
+			var ex error
+			var collect func() bufferedOutput
+			if out != nil {
+				var port *Port
+				port, collect, ex = bufferPort()
+				if ex == nil {
+					// The port is closed by collect, not newFm.Close.
+					newFm.ports[1] = port.fork()
+				}
+			}
+			if ex == nil {
+				ex = f.Call(newFm, []interface{}{v}, NoOpts)
+			}
 			newFm.Close()
+			if out != nil {
+				var buffered bufferedOutput
+				if collect != nil {
+					buffered = collect()
+				}
+				out.write(index, buffered)
+			}
+			if workers != nil {
+				<-workers
+			}
 
 			if ex != nil {
 				switch Reason(ex) {
@@ -207,18 +301,144 @@ func peach(fm *Frame, f Callable, inputs Inputs) error {
 					atomic.StoreInt32(&broken, 1)
 				default:
 					errMu.Lock()
-					err = diag.Errors(err, ex)
-					defer errMu.Unlock()
-					atomic.StoreInt32(&broken, 1)
+					if opts.KeepGoing {
+						failures = append(failures, indexedPeachFailure{
+							index, PeachFailure{v, toException(ex, newFm)}})
+					} else {
+						err = diag.Errors(err, ex)
+						atomic.StoreInt32(&broken, 1)
+					}
+					errMu.Unlock()
 				}
 			}
 			wg.Done()
 		}()
 	})
 	wg.Wait()
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool {
+			return failures[i].index < failures[j].index
+		})
+		e := PeachError{make([]PeachFailure, len(failures))}
+		for i, failure := range failures {
+			e.Failures[i] = failure.PeachFailure
+		}
+		return e
+	}
 	return err
 }
 
+// Converts an error returned by a Callable to an Exception.
+func toException(err error, fm *Frame) Exception {
+	if exc, ok := err.(Exception); ok {
+		return exc
+	}
+	return &exception{err, fm.traceback}
+}
+
+// Outputs of a call of the function passed to peach, buffered when &ordered
+// is true.
+type bufferedOutput struct {
+	bytes  []byte
+	values []interface{}
+}
+
+// Returns an output port that buffers the outputs, and a function to obtain
+// the outputs after the port is no longer used.
+func bufferPort() (*Port, func() bufferedOutput, error) {
+	var buffered bufferedOutput
+	port, done, err := PipePort(
+		func(ch <-chan interface{}) {
+			for v := range ch {
+				buffered.values = append(buffered.values, v)
+			}
+		},
+		func(r *os.File) {
+			buffered.bytes, _ = ioutil.ReadAll(r)
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return port, func() bufferedOutput {
+		done()
+		return buffered
+	}, nil
+}
+
+// Writes buffered outputs in the order of inputs.
+type orderedOutput struct {
+	fm      *Frame
+	mutex   sync.Mutex
+	next    int
+	pending map[int]bufferedOutput
+}
+
+// Records the outputs of the call with the given index, and writes all the
+// outputs that are no longer waiting for an earlier call.
+func (o *orderedOutput) write(index int, buffered bufferedOutput) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.pending[index] = buffered
+	for {
+		buffered, ok := o.pending[o.next]
+		if !ok {
+			break
+		}
+		delete(o.pending, o.next)
+		o.next++
+		o.fm.ByteOutput().Write(buffered.bytes)
+		out := o.fm.ValueOutput()
+		for _, v := range buffered.values {
+			out.Put(v)
+		}
+	}
+}
+
+// PeachError is thrown by peach when &keep-going is true and some calls of
+// the function have thrown exceptions.
+type PeachError struct{ Failures []PeachFailure }
+
+// PeachFailure records an input to peach and the exception thrown when calling
+// the function with it.
+type PeachFailure struct {
+	Input     interface{}
+	Exception Exception
+}
+
+func (PeachFailure) IsStructMap() {}
+
+type indexedPeachFailure struct {
+	index int
+	PeachFailure
+}
+
+// Error returns a plain text representation of the failures.
+func (e PeachError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d inputs failed:", len(e.Failures))
+	for _, f := range e.Failures {
+		fmt.Fprintf(&sb, " %s: %s;", vals.Repr(f.Input, vals.NoPretty), f.Exception.Error())
+	}
+	return strings.TrimSuffix(sb.String(), ";")
+}
+
+// Fields returns a structmap for accessing fields from Elvish.
+func (e PeachError) Fields() vals.StructMap { return peachFields{e} }
+
+type peachFields struct{ e PeachError }
+
+func (peachFields) IsStructMap() {}
+
+func (f peachFields) Type() string { return "peach" }
+
+func (f peachFields) Failures() vals.List {
+	li := vals.EmptyList
+	for _, failure := range f.e.Failures {
+		li = li.Cons(failure)
+	}
+	return li
+}
+
 // FailError is an error returned by the "fail" command.
 type FailError struct{ Content interface{} }
 
@@ -374,3 +594,149 @@ func breakFn() error {
 func continueFn() error {
 	return Continue
 }
+
+//elvdoc:fn defer
+//
+// ```elvish
+// defer $callable
+// ```
+//
+// Schedules `$callable` to be called with no arguments when the innermost
+// enclosing function or lambda returns, whether normally or by throwing an
+// exception. Callables deferred in the same function are called in the reverse
+// order they were deferred. Example:
+//
+// ```elvish-transcript
+// ~> fn f { defer { echo cleanup 1 }; defer { echo cleanup 2 }; echo body }
+// ~> f
+// body
+// cleanup 2
+// cleanup 1
+// ```
+//
+// All the deferred callables are called even if some of them throw
+// exceptions. If they do, the exceptions, together with the exception thrown
+// by the function body if any, are combined into a composite exception like
+// the one thrown by [`run-parallel`](#run-parallel).
+//
+// Note that the bodies of control structures like `if` and `for` are lambdas
+// too, so callables deferred inside them are called when the body finishes.
+//
+// It is an error to call `defer` outside of any function or lambda.
+
+// ErrDeferOutsideClosure is thrown when defer is called outside of closures.
+var ErrDeferOutsideClosure = errors.New("defer must be called from within a closure")
+
+func deferFn(fm *Frame, fn Callable) error {
+	if fm.defers == nil {
+		return ErrDeferOutsideClosure
+	}
+	traceback := fm.traceback
+	fm.defers.push(func(fm *Frame) Exception {
+		newFm := fm.fork("[deferred]")
+		newFm.traceback = traceback
+		err := fn.Call(newFm, NoArgs, NoOpts)
+		if err == nil {
+			return nil
+		}
+		if exc, ok := err.(Exception); ok {
+			return exc
+		}
+		return &exception{err, traceback}
+	})
+	return nil
+}
+
+//elvdoc:fn with-timeout
+//
+// ```elvish
+// with-timeout $duration $callable
+// ```
+//
+// Calls `$callable` with no arguments, and cancels it if it has not finished
+// after `$duration`, which is written like the argument to [`sleep`](#sleep).
+//
+// Canceling works like pressing <kbd>Ctrl-C</kbd>, but only affects the code
+// run by `$callable`: Elvish code is interrupted at the next pipeline, and
+// commands like `sleep` return early. In addition, external commands started
+// by `$callable` are killed. If `$callable` throws an exception after being
+// canceled, `with-timeout` throws a timeout exception in its place, whose
+// reason has a `type` field of `timeout` and a `duration` field with the
+// duration in seconds:
+//
+// ```elvish-transcript
+// ~> with-timeout 1s { while $true { } }
+// Exception: timed out after 1s
+// [tty 1], line 1: with-timeout 1s { while $true { } }
+// ~> var e = ?(with-timeout 0.1 { sleep 1 })
+// ~> put $e[reason][type] $e[reason][duration]
+// ▶ timeout
+// ▶ (num 0.1)
+// ~> with-timeout 1s { put done }
+// ▶ done
+// ```
+//
+// Calls of `with-timeout` can be nested. Canceling the outer call also cancels
+// the inner call; whichever call timed out throws the timeout exception.
+//
+// @cf sleep
+
+// ErrNegativeTimeout is thrown when the duration passed to with-timeout or
+// chan:select is negative.
+var ErrNegativeTimeout = errors.New("timeout must be >= zero")
+
+// ErrInvalidTimeout is thrown when the duration passed to with-timeout or
+// chan:select is neither a number nor a duration string.
+var ErrInvalidTimeout = errors.New("invalid timeout")
+
+// TimeoutError is thrown by with-timeout when its callable is canceled.
+type TimeoutError struct{ Duration time.Duration }
+
+// Error returns a message containing the duration.
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %v", e.Duration)
+}
+
+// Fields returns a structmap for accessing fields from Elvish.
+func (e TimeoutError) Fields() vals.StructMap { return timeoutFields{e} }
+
+type timeoutFields struct{ e TimeoutError }
+
+func (timeoutFields) IsStructMap() {}
+
+func (f timeoutFields) Type() string      { return "timeout" }
+func (f timeoutFields) Duration() float64 { return f.e.Duration.Seconds() }
+
+func withTimeout(fm *Frame, duration interface{}, fn Callable) error {
+	d, ok := ScanDuration(duration)
+	if !ok {
+		return ErrInvalidTimeout
+	}
+	if d < 0 {
+		return ErrNegativeTimeout
+	}
+
+	timer := TimeAfter(fm, d)
+	timedOut := make(chan struct{})
+	stopTimer := make(chan struct{})
+	defer close(stopTimer)
+	go func() {
+		select {
+		case <-timer:
+			close(timedOut)
+		case <-stopTimer:
+		}
+	}()
+
+	newFm, cleanup := fm.withCancel(timedOut)
+	err := fn.Call(newFm, NoArgs, NoOpts)
+	cleanup()
+	if err != nil {
+		select {
+		case <-timedOut:
+			return TimeoutError{d}
+		default:
+		}
+	}
+	return err
+}
@@ -1,9 +1,12 @@
 package eval_test
 
 import (
+	"sync/atomic"
 	"testing"
+	"time"
 
 	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
 
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/eval/vals"
@@ -66,7 +69,44 @@ func TestPeach(t *testing.T) {
 				peach [x]{ if (== 50 $x) { break } else { put $x } } |
 				< (+ (all)) (+ (range 1 101))
 		`).Puts(true),
+
+		That("peach &num-workers=-1 [x]{ } [a]").Throws(errs.OutOfRange{
+			What: "num-workers", ValidLow: "0", ValidHigh: "inf", Actual: "-1"}),
+		// &ordered preserves the order of inputs.
+		That(`range 20 | peach &ordered [x]{ sleep (* (rand) 0.01); put $x }`).
+			Puts(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19),
+		That(`range 3 | peach &ordered &num-workers=2 [x]{ echo $x; put $x }`).
+			Puts(0, 1, 2).Prints("0\n1\n2\n"),
+		// &keep-going collects all the failures.
+		That(`
+			var e = ?(range 6 | peach &keep-going [x]{ if (> $x 2) { fail bad-$x } })
+			put $e[reason][type]
+			each [f]{ put $f[input] $f[exception][reason][content] } $e[reason][failures]
+		`).Puts("peach", 3, "bad-3", 4, "bad-4", 5, "bad-5"),
+	)
+}
+
+func TestPeach_NumWorkers(t *testing.T) {
+	var running, max int32
+	setup := func(ev *Evaler) {
+		ev.AddGlobal(NsBuilder{}.AddGoFn("", "work", func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("range 20 | peach &num-workers=3 [x]{ work }").DoesNothing(),
 	)
+	if max > 3 {
+		t.Errorf("got %d concurrent calls, want at most 3", max)
+	}
 }
 
 func TestFail(t *testing.T) {
@@ -81,6 +121,56 @@ func TestFail(t *testing.T) {
 	)
 }
 
+func TestDefer(t *testing.T) {
+	Test(t,
+		That("fn f { defer { echo 1 }; defer { echo 2 }; echo body }", "f").
+			Prints("body\n2\n1\n"),
+		// Deferred callables run after exceptions and flow commands.
+		That("fn f { defer { echo deferred }; fail body }", "f").
+			Prints("deferred\n").Throws(FailError{"body"}),
+		That("fn f { defer { echo deferred }; return; echo bad }", "f").
+			Prints("deferred\n"),
+		// Callables are deferred to the innermost lambda.
+		That("{ if $true { defer { echo deferred } }; echo after }").
+			Prints("deferred\nafter\n"),
+		That("{ put (defer { echo deferred }; put inner); echo after }").
+			Puts("inner").Prints("after\ndeferred\n"),
+		// Exceptions are aggregated.
+		That("fn f { defer { fail d1 } }", "f").Throws(FailError{"d1"}),
+		That("fn f { defer { fail d1 }; return }", "f").Throws(FailError{"d1"}),
+		That("fn f { defer { fail d1 }; defer { fail d2 }; fail body }",
+			"put ?(f)[reason][exceptions][(num 0) (num 1) (num 2)][reason][content]").
+			Puts("body", "d2", "d1"),
+		That("defer { }").Throws(ErrDeferOutsideClosure),
+	)
+}
+
+func TestWithTimeout(t *testing.T) {
+	Test(t,
+		That("with-timeout 1s { put done }").Puts("done"),
+		// Pure Elvish code is interrupted.
+		That("with-timeout 10ms { while $true { } }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+		That("with-timeout 0.01 { sleep 1 }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+		That("put ?(with-timeout 10ms { sleep 1 })[reason][type duration]").
+			Puts("timeout", 0.01),
+		// Exceptions not caused by the timeout are passed through.
+		That("with-timeout 1s { fail foo }").Throws(FailError{"foo"}),
+		// Nested scopes.
+		That("with-timeout 1s { with-timeout 10ms { sleep 1 } }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+		That("with-timeout 10ms { with-timeout 1s { sleep 1 } }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+		// Outputs before the timeout are kept.
+		That("with-timeout 10ms { put before; sleep 1 }").
+			Puts("before").Throws(TimeoutError{10 * time.Millisecond}),
+		// Bad durations.
+		That("with-timeout -1 { }").Throws(ErrNegativeTimeout),
+		That("with-timeout foo { }").Throws(ErrInvalidTimeout),
+	)
+}
+
 func TestReturn(t *testing.T) {
 	Test(t,
 		That("return").Throws(Return),
@@ -0,0 +1,20 @@
+// +build !windows,!plan9,!js
+
+package eval_test
+
+import (
+	"testing"
+	"time"
+
+	. "src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/eval/evaltest"
+)
+
+func TestWithTimeout_KillsExternalCommands(t *testing.T) {
+	Test(t,
+		That("with-timeout 10ms { e:sleep 5 }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+		That("with-timeout 10ms { with-timeout 1s { e:sleep 5 } }").
+			Throws(TimeoutError{10 * time.Millisecond}),
+	)
+}
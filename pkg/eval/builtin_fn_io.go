@@ -487,7 +487,7 @@ func repr(fm *Frame, args ...interface{}) error {
 //elvdoc:fn show
 //
 // ```elvish
-// show $e
+// show &full=$false $e
 // ```
 //
 // Shows the value to the output, which is assumed to be a VT-100-compatible
@@ -504,10 +504,39 @@ func repr(fm *Frame, args ...interface{}) error {
 // Exception: lorem-ipsum
 // [tty 3], line 1: e = ?(fail lorem-ipsum)
 // ```
+//
+// If `&full` is true, all the entries of the traceback of an exception are
+// shown, each followed by the name of the function it is in:
+//
+// ```elvish-transcript
+// ~> fn f { fail bad }
+// ~> show &full ?(f)
+// Exception: bad
+// Traceback (innermost first):
+//   [tty 4], line 1:
+//     fn f { fail bad }
+//     in f
+//   [tty 5], line 1:
+//     show &full ?(f)
+//     in top level
+// ```
+//
+// The entries of the traceback are also available as the `stack-trace` field
+// of the exception.
+
+type showOpts struct{ Full bool }
 
-func show(fm *Frame, v diag.Shower) error {
+func (*showOpts) SetDefaultOptions() {}
+
+func show(fm *Frame, opts showOpts, v diag.Shower) error {
 	out := fm.ByteOutput()
-	_, err := out.WriteString(v.Show(""))
+	var s string
+	if exc, ok := v.(*exception); ok {
+		s = exc.show("", opts.Full)
+	} else {
+		s = v.Show("")
+	}
+	_, err := out.WriteString(s)
 	if err != nil {
 		return err
 	}
@@ -76,6 +76,10 @@ func TestShow(t *testing.T) {
 	Test(t,
 		// A sanity test that show writes something.
 		That(`show ?(fail foo) | !=s (slurp) ''`).Puts(true),
+		That("fn f { fail foo }", "show &full ?(f) | each [l]{ put $l } | drop 2").
+			Puts("  [test], line 1:", "    fn f { \033[1;4mfail foo \033[m}", "    in f",
+				"  [test], line 2:", "    show &full ?(\033[1;4mf\033[m) | each [l]{ put $l } | drop 2",
+				"    in top level"),
 		thatOutputErrorIsBubbled("repr ?(fail foo)"),
 	)
 }
@@ -405,24 +405,10 @@ var TimeAfter = func(fm *Frame, d time.Duration) <-chan time.Time {
 // ```
 
 func sleep(fm *Frame, duration interface{}) error {
-	var f float64
-	var d time.Duration
-
-	if err := vals.ScanToGo(duration, &f); err == nil {
-		d = time.Duration(f * float64(time.Second))
-	} else {
-		// See if it is a duration string rather than a simple number.
-		switch duration := duration.(type) {
-		case string:
-			d, err = time.ParseDuration(duration)
-			if err != nil {
-				return ErrInvalidSleepDuration
-			}
-		default:
-			return ErrInvalidSleepDuration
-		}
+	d, ok := ScanDuration(duration)
+	if !ok {
+		return ErrInvalidSleepDuration
 	}
-
 	if d < 0 {
 		return ErrNegativeSleepDuration
 	}
@@ -435,6 +421,20 @@ func sleep(fm *Frame, duration interface{}) error {
 	}
 }
 
+// ScanDuration converts a number of seconds or a duration string, as accepted
+// by sleep, to a time.Duration. The duration may be negative.
+func ScanDuration(v interface{}) (time.Duration, bool) {
+	var f float64
+	if err := vals.ScanToGo(v, &f); err == nil {
+		return time.Duration(f * float64(time.Second)), true
+	}
+	if s, ok := v.(string); ok {
+		d, err := time.ParseDuration(s)
+		return d, err == nil
+	}
+	return 0, false
+}
+
 //elvdoc:fn time
 //
 // ```elvish
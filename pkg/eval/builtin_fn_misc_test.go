@@ -103,6 +103,9 @@ func timeAfterMock(fm *Frame, d time.Duration) <-chan time.Time {
 }
 
 func TestSleep(t *testing.T) {
+	defer func(saved func(*Frame, time.Duration) <-chan time.Time) {
+		TimeAfter = saved
+	}(TimeAfter)
 	TimeAfter = timeAfterMock
 	Test(t,
 		That(`sleep 0`).Puts(0*time.Second),
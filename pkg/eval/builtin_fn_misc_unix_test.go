@@ -29,6 +29,9 @@ func interruptedTimeAfterMock(fm *Frame, d time.Duration) <-chan time.Time {
 }
 
 func TestInterruptedSleep(t *testing.T) {
+	defer func(saved func(*Frame, time.Duration) <-chan time.Time) {
+		TimeAfter = saved
+	}(TimeAfter)
 	TimeAfter = interruptedTimeAfterMock
 	Test(t,
 		// Special-case that should result in the sleep being interrupted. See
@@ -58,6 +58,7 @@ func init() {
 		"while": compileWhile,
 		"for":   compileFor,
 		"try":   compileTry,
+		"match": compileMatch,
 	}
 	for name := range builtinSpecials {
 		IsBuiltinSpecial[name] = true
@@ -355,13 +356,42 @@ func use(fm *Frame, spec string, r diag.Ranger) (*Ns, error) {
 		return evalModule(fm, spec,
 			parse.Source{Name: "[bundled " + spec + "]", Code: code}, r)
 	}
-	libDir := fm.Evaler.getLibDir()
+	libDir := fm.Evaler.LibDir()
 	if libDir == "" {
 		return nil, noSuchModule{spec}
 	}
 	return useFromFile(fm, spec, libDir+"/"+spec, r)
 }
 
+// HasModule returns whether a module can be imported with "use" from code that
+// is not in a file, like code typed interactively. The module is not loaded,
+// so errors in it are not found.
+func (ev *Evaler) HasModule(spec string) bool {
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		return moduleFileExists(spec)
+	}
+	ev.mu.RLock()
+	_, ok := ev.modules[spec]
+	ev.mu.RUnlock()
+	if ok {
+		return true
+	}
+	if _, ok := bundledModules[spec]; ok {
+		return true
+	}
+	libDir := ev.LibDir()
+	return libDir != "" && moduleFileExists(libDir+"/"+spec)
+}
+
+func moduleFileExists(path string) bool {
+	for _, ext := range []string{".elv", ".so"} {
+		if _, err := os.Stat(path + ext); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // TODO: Make access to fm.Evaler.modules concurrency-safe.
 func useFromFile(fm *Frame, spec, path string, r diag.Ranger) (*Ns, error) {
 	if ns, ok := fm.Evaler.modules[path]; ok {
@@ -734,6 +764,148 @@ func (op *tryOp) exec(fm *Frame) Exception {
 	return fm.errorp(op, err)
 }
 
+// MatchForm = 'match' Compound { Pattern [ 'kind' Compound ] [ 'if' Compound ] Lambda } [ 'else' Lambda ]
+func compileMatch(cp *compiler, fn *parse.Form) effectOp {
+	args := cp.walkArgs(fn)
+	valueOp := cp.compoundOp(args.next())
+	var arms []matchArm
+	var elseNode *parse.Primary
+	// Whether an earlier arm matches all values.
+	exhausted := false
+	// Literals in earlier arms without guards.
+	literals := make(map[string]bool)
+	for args.more() {
+		if args.nextIs("else") {
+			if exhausted {
+				cp.errorpf(fn.Args[args.idx-1], "unreachable else body")
+			}
+			elseNode = args.nextMustLambda("else body")
+			break
+		}
+		patternNode := args.next()
+		pattern, names := cp.pattern(patternNode)
+		var kind string
+		if args.nextIs("kind") {
+			kindNode := args.next()
+			kind = stringLiteralOrError(cp, kindNode, "kind")
+			if kind == "" {
+				cp.errorpf(kindNode, "kind must not be empty")
+			}
+		}
+		var predNode *parse.Compound
+		if args.nextIs("if") {
+			predNode = args.next()
+		}
+		bodyNode := args.nextMustLambda("match arm body")
+		if len(bodyNode.Elements) > 0 || len(bodyNode.MapPairs) > 0 {
+			cp.errorpf(bodyNode, "match arm body must not have arguments or options")
+		}
+
+		if exhausted {
+			cp.errorpf(patternNode, "unreachable pattern")
+		}
+		if kind == "" && predNode == nil {
+			if lit, ok := pattern.(literalPattern); ok {
+				if literals[lit.value] {
+					cp.errorpf(patternNode, "unreachable pattern")
+				}
+				literals[lit.value] = true
+			}
+			exhausted = irrefutable(pattern)
+		}
+
+		// The predicate and the body are compiled as closures taking the bound
+		// variables as arguments, so that the variables are only visible in
+		// the arm.
+		var predOp valuesOp
+		if predNode != nil {
+			predOp = cp.closureOp(predNode, &signature{argNames: names, restArg: -1},
+				func() effectOp { return outputValuesOp{cp.compoundOp(predNode)} })
+		}
+		bodyOp := cp.closureOp(bodyNode, &signature{argNames: names, restArg: -1},
+			func() effectOp { return cp.chunkOp(bodyNode.Chunk) })
+		arms = append(arms, matchArm{pattern, kind, predOp, bodyOp})
+	}
+	args.mustEnd()
+	if len(arms) == 0 {
+		cp.errorpf(fn, "need at least one pattern")
+	}
+
+	var elseOp valuesOp
+	if elseNode != nil {
+		elseOp = cp.primaryOp(elseNode)
+	}
+	return &matchOp{fn.Range(), valueOp, arms, elseOp}
+}
+
+type matchOp struct {
+	diag.Ranging
+	valueOp valuesOp
+	arms    []matchArm
+	elseOp  valuesOp
+}
+
+type matchArm struct {
+	pattern pattern
+	// If not empty, only values of this kind match.
+	kind string
+	// Ops for the closures of the predicate and the body. The predicate may be
+	// nil.
+	predOp, bodyOp valuesOp
+}
+
+func (op *matchOp) exec(fm *Frame) Exception {
+	v, exc := evalForValue(fm, op.valueOp, "value being matched")
+	if exc != nil {
+		return exc
+	}
+	for _, arm := range op.arms {
+		if arm.kind != "" && vals.Kind(v) != arm.kind {
+			continue
+		}
+		bound, ok := arm.pattern.match(v, nil)
+		if !ok {
+			continue
+		}
+		if arm.predOp != nil {
+			pred := execLambdaOp(fm, arm.predOp)
+			predValues, err := fm.CaptureOutput(func(fm *Frame) error {
+				return pred.Call(fm, bound, NoOpts)
+			})
+			if err != nil {
+				return fm.errorp(op, err)
+			}
+			if !allTrue(predValues) {
+				continue
+			}
+		}
+		body := execLambdaOp(fm, arm.bodyOp)
+		return fm.errorp(op, body.Call(fm.fork("match body"), bound, NoOpts))
+	}
+	if op.elseOp != nil {
+		elseFn := execLambdaOp(fm, op.elseOp)
+		return fm.errorp(op, elseFn.Call(fm.fork("match else"), NoArgs, NoOpts))
+	}
+	return fm.errorpf(op, "no pattern matches %s", vals.Repr(v, vals.NoPretty))
+}
+
+// An effectOp that outputs the values of a valuesOp.
+type outputValuesOp struct{ valuesOp }
+
+func (op outputValuesOp) exec(fm *Frame) Exception {
+	values, exc := op.valuesOp.exec(fm)
+	if exc != nil {
+		return exc
+	}
+	out := fm.ValueOutput()
+	for _, v := range values {
+		if err := out.Put(v); err != nil {
+			return fm.errorp(op.valuesOp, err)
+		}
+	}
+	return nil
+}
+
 func (cp *compiler) compileOneLValue(n *parse.Compound) lvalue {
 	if len(n.Indexings) != 1 {
 		cp.errorpf(n, "must be valid lvalue")
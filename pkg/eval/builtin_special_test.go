@@ -201,6 +201,53 @@ func TestTry(t *testing.T) {
 	)
 }
 
+func TestMatch(t *testing.T) {
+	Test(t,
+		// Literals and wildcards.
+		That("match b a { put A } b { put B } _ { put other }").Puts("B"),
+		That("match c a { put A } b { put B } _ { put other }").Puts("other"),
+		That("match c a { put A } else { put else }").Puts("else"),
+		// Variables are bound in the scope of the arm.
+		That("match foo $x { put $x }").Puts("foo"),
+		That("match foo $x { }; put $x").DoesNotCompile(),
+		// List patterns.
+		That("match [1 [2 3]] [$a [$b @_]] { put $a $b }").Puts("1", "2"),
+		That("match [a b c] [$x @rest] { put $x $rest }").
+			Puts("a", vals.MakeList("b", "c")),
+		That("match [a b c] [$x $y] { put two } [$x $y $z] { put three }").
+			Puts("three"),
+		That("match [a] [$x @rest] { put $rest }").Puts(vals.EmptyList),
+		That("match foo [$x] { put list } $x { put other }").Puts("other"),
+		// Map patterns.
+		That("match [&a=1 &b=2] [&a=$x &b] { put $x }").Puts("1"),
+		That("match [&a=1] [&b] { put bad } [&a=2] { put bad } [&a=1] { put good }").
+			Puts("good"),
+		That("var p~ = (record-type point [&x=1])",
+			"match (p) [&x=$x] kind point { put $x }").Puts("1"),
+		// Kind guards and predicates.
+		That("match [&] $x kind list { put list } $x kind map { put map }").
+			Puts("map"),
+		That("match (num 5) $x if (< $x 3) { put small } $x { put big }").
+			Puts("big"),
+		That("match (num 1) $x if (< $x 3) { put small } $x { put big }").
+			Puts("small"),
+		// No match.
+		That("match y x { }").Throws(ErrorWithMessage("no pattern matches y")),
+
+		// Malformed and unreachable patterns.
+		That("match x").DoesNotCompile(),
+		That("match x $y { } z { }").DoesNotCompile(),
+		That("match x a { } a { }").DoesNotCompile(),
+		That("match x _ { } else { }").DoesNotCompile(),
+		That("match x [@a $b] { }").DoesNotCompile(),
+		That("match x [$a $a] { }").DoesNotCompile(),
+		That("match x [&(put a)=$b] { }").DoesNotCompile(),
+		That("match x $ns:a { }").DoesNotCompile(),
+		That("match x $a [a]{ }").DoesNotCompile(),
+		That("match x { a } { put A }").DoesNotCompile(),
+	)
+}
+
 func TestWhile(t *testing.T) {
 	Test(t,
 		That("var x = (num 0)", "while (< $x 4) { put $x; set x = (+ $x 1) }").
@@ -376,6 +423,33 @@ func TestUse(t *testing.T) {
 	)
 }
 
+func TestHasModule(t *testing.T) {
+	libdir, cleanup := InTestDir()
+	defer cleanup()
+	MustMkdirAll(filepath.Join("a", "b"))
+	MustWriteFile(filepath.Join("a", "b", "c.elv"), []byte(""), 0600)
+
+	ev := NewEvaler()
+	ev.SetLibDir(libdir)
+	ev.AddModule("internal", &Ns{})
+
+	for spec, want := range map[string]bool{
+		"builtin":   true,
+		"internal":  true,
+		"epm":       true,
+		"a/b/c":     true,
+		"./a/b/c":   true,
+		"a/b":       false,
+		"./a/b/d":   false,
+		"../a/b/c":  false,
+		"no-module": false,
+	} {
+		if got := ev.HasModule(spec); got != want {
+			t.Errorf("HasModule(%q) = %v, want %v", spec, got, want)
+		}
+	}
+}
+
 // Regression test for #1072
 func TestUse_WarnsAboutDeprecatedFeatures(t *testing.T) {
 	restore := prog.SetDeprecationLevel(16)
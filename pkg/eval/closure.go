@@ -18,9 +18,15 @@ import (
 // A user-defined function in Elvish code. Each closure has its unique identity.
 type closure struct {
 	ArgNames []string
+	// Kinds of the arguments, as declared; empty for arguments that accept any
+	// value. May be nil if no argument has a kind.
+	ArgKinds []string
 	// The index of the rest argument. -1 if there is no rest argument.
-	RestArg     int
+	RestArg int
+	// Default values of the trailing optional arguments.
+	ArgDefaults []interface{}
 	OptNames    []string
+	OptKinds    []string
 	OptDefaults []interface{}
 	Op          effectOp
 	NewLocal    []string
@@ -54,15 +60,16 @@ func (c *closure) Repr(int) string {
 // Call calls a closure.
 func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{}) error {
 	// Check number of arguments.
+	nRequired := len(c.ArgNames) - len(c.ArgDefaults)
 	if c.RestArg != -1 {
-		if len(args) < len(c.ArgNames)-1 {
+		if len(args) < nRequired-1 {
 			return errs.ArityMismatch{What: "arguments",
-				ValidLow: len(c.ArgNames) - 1, ValidHigh: -1, Actual: len(args)}
+				ValidLow: nRequired - 1, ValidHigh: -1, Actual: len(args)}
 		}
 	} else {
-		if len(args) != len(c.ArgNames) {
+		if len(args) < nRequired || len(args) > len(c.ArgNames) {
 			return errs.ArityMismatch{What: "arguments",
-				ValidLow: len(c.ArgNames), ValidHigh: len(c.ArgNames), Actual: len(args)}
+				ValidLow: nRequired, ValidHigh: len(c.ArgNames), Actual: len(args)}
 		}
 	}
 	// Check whether all supplied options are supported. This map contains the
@@ -104,17 +111,36 @@ func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{
 	}
 	if c.RestArg == -1 {
 		for i := range c.ArgNames {
-			local.slots[i] = vars.FromInit(args[i])
+			v, err := checkKind(c.argKind(i), c.argOrDefault(args, i), "argument $"+c.ArgNames[i])
+			if err != nil {
+				return err
+			}
+			local.slots[i] = vars.FromInit(v)
 		}
 	} else {
 		for i := 0; i < c.RestArg; i++ {
-			local.slots[i] = vars.FromInit(args[i])
+			v, err := checkKind(c.argKind(i), args[i], "argument $"+c.ArgNames[i])
+			if err != nil {
+				return err
+			}
+			local.slots[i] = vars.FromInit(v)
 		}
 		restOff := len(args) - len(c.ArgNames)
-		local.slots[c.RestArg] = vars.FromInit(
-			vals.MakeList(args[c.RestArg : c.RestArg+restOff+1]...))
+		restArgs := make([]interface{}, restOff+1)
+		for i, v := range args[c.RestArg : c.RestArg+restOff+1] {
+			v, err := checkKind(c.argKind(c.RestArg), v, "element of argument $"+c.ArgNames[c.RestArg])
+			if err != nil {
+				return err
+			}
+			restArgs[i] = v
+		}
+		local.slots[c.RestArg] = vars.FromInit(vals.MakeList(restArgs...))
 		for i := c.RestArg + 1; i < len(c.ArgNames); i++ {
-			local.slots[i] = vars.FromInit(args[i+restOff])
+			v, err := checkKind(c.argKind(i), args[i+restOff], "argument $"+c.ArgNames[i])
+			if err != nil {
+				return err
+			}
+			local.slots[i] = vars.FromInit(v)
 		}
 	}
 
@@ -123,6 +149,12 @@ func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{
 		v, ok := opts[name]
 		if !ok {
 			v = c.OptDefaults[i]
+		} else {
+			var err error
+			v, err = checkKind(c.OptKinds[i], v, "option &"+name)
+			if err != nil {
+				return err
+			}
 		}
 		local.names[offset+i] = name
 		local.slots[offset+i] = vars.FromInit(v)
@@ -136,7 +168,46 @@ func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{
 
 	fm.local = local
 	fm.srcMeta = c.SrcMeta
-	return c.Op.exec(fm)
+	fm.defers = &deferStack{}
+	return fm.runDefers(c.Op.exec(fm))
+}
+
+// Returns the i-th argument, or its default value if it is an optional argument
+// that is not supplied. Only used when there is no rest argument.
+func (c *closure) argOrDefault(args []interface{}, i int) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	return c.ArgDefaults[i-(len(c.ArgNames)-len(c.ArgDefaults))]
+}
+
+func (c *closure) argKind(i int) string {
+	if c.ArgKinds == nil {
+		return ""
+	}
+	return c.ArgKinds[i]
+}
+
+// Checks that v is of the given kind, which is empty if any value is allowed,
+// and returns the value to bind. Like the arguments of builtin functions, a num
+// accepts anything that can be converted to a number, and is bound to the
+// converted number.
+func checkKind(kind string, v interface{}, what string) (interface{}, error) {
+	switch kind {
+	case "":
+		return v, nil
+	case "num":
+		var n vals.Num
+		if vals.ScanToGo(v, &n) == nil {
+			return n, nil
+		}
+		return nil, errs.BadValue{What: what, Valid: kind, Actual: vals.Repr(v, vals.NoPretty)}
+	default:
+		if vals.Kind(v) == kind {
+			return v, nil
+		}
+		return nil, errs.BadValue{What: what, Valid: kind, Actual: vals.Kind(v)}
+	}
 }
 
 // MakeVarFromName creates a Var with a suitable type constraint inferred from
@@ -71,11 +71,24 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		return fm.errorp(op, ErrInterrupted)
 	}
 
+	var j *job
+	var closeJobPorts func()
 	if op.bg {
 		fm = fm.fork("background job" + op.source)
 		fm.intCh = nil
-		fm.background = true
-		fm.Evaler.addNumBgJobs(1)
+		fm.cancelCh = nil
+		// The job may outlive the closure that starts it, so it can't defer
+		// callables to the closure.
+		fm.defers = nil
+		j = newJob(op.source, true)
+		fm.job = j
+		fm.Evaler.jobs.add(j)
+		closeJobPorts = ownFilePorts(fm)
+	} else if fm.jobControl && fm.job == nil {
+		fm = fm.fork("job " + op.source)
+		j = newJob(op.source, false)
+		fm.job = j
+		closeJobPorts = ownFilePorts(fm)
 	}
 
 	nforms := len(op.subops)
@@ -134,24 +147,32 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		}()
 	}
 
-	if op.bg {
-		// Background job, wait for form termination asynchronously.
-		go func() {
-			wg.Wait()
-			fm.Evaler.addNumBgJobs(-1)
+	if j == nil {
+		wg.Wait()
+		return fm.errorp(op, MakePipelineError(excs))
+	}
+
+	// Wait for form termination asynchronously, since the job may be stopped
+	// before that or run in the background.
+	go func() {
+		wg.Wait()
+		closeJobPorts()
+		err := MakePipelineError(excs)
+		if j.finish(err) {
+			fm.Evaler.jobs.remove(j)
 			msg := "job " + op.source + " finished"
-			err := MakePipelineError(excs)
 			if err != nil {
 				msg += ", errors = " + err.Error()
 			}
 			if fm.Evaler.getNotifyBgJobSuccess() || err != nil {
 				fm.ErrorFile().WriteString(msg + "\n")
 			}
-		}()
+		}
+	}()
+	if op.bg {
 		return nil
 	}
-	wg.Wait()
-	return fm.errorp(op, MakePipelineError(excs))
+	return fm.errorp(op, fm.waitForegroundJob(j))
 }
 
 func isReaderGone(exc Exception) bool {
@@ -374,6 +395,11 @@ func (op *formOp) exec(fm *Frame) (errRet Exception) {
 	}
 
 	fm.traceback = fm.addTraceback(op)
+	headRange := cmd.headOp.Range()
+	fm.traceback.Callee = fm.srcMeta.Code[headRange.From:headRange.To]
+	if fm.Evaler.Trace() {
+		fm.trace(op, fm.traceback.Callee, args, convertedOpts)
+	}
 	err = headFn.Call(fm, args, convertedOpts)
 	if exc, ok := err.(Exception); ok {
 		return exc
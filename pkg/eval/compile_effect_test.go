@@ -1,12 +1,17 @@
 package eval_test
 
 import (
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	. "src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
 )
 
@@ -62,6 +67,43 @@ func TestPipeline_ReaderGone(t *testing.T) {
 	)
 }
 
+func TestPipeline_BackgroundDefer(t *testing.T) {
+	// Background jobs don't belong to the closure that starts them, so defer
+	// can't be called directly from them. The exception is written to stderr
+	// when the job finishes.
+	outFile, err := ioutil.TempFile("", "elvish-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(outFile.Name())
+	defer outFile.Close()
+
+	ev := NewEvaler()
+	ports, cleanup := PortsFromFiles([3]*os.File{DevNull, outFile, outFile}, "")
+	err = ev.Eval(parse.Source{Name: "[test]", Code: "fn f { defer { echo deferred } & }; f"},
+		EvalCfg{Ports: ports})
+	cleanup()
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	deadline := time.Now().Add(testutil.ScaledMs(5000))
+	for time.Now().Before(deadline) {
+		out, err := ioutil.ReadFile(outFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(out), ErrDeferOutsideClosure.Error()) {
+			if strings.HasPrefix(string(out), "deferred\n") {
+				t.Errorf("deferred callable was called, output: %q", out)
+			}
+			return
+		}
+		time.Sleep(testutil.ScaledMs(10))
+	}
+	t.Fatal("timed out waiting for the background job to fail")
+}
+
 func TestCommand(t *testing.T) {
 	Test(t,
 		That("put foo").Puts("foo"),
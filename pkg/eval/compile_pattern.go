@@ -0,0 +1,210 @@
+package eval
+
+// Compilation and matching of the patterns of the "match" special form.
+
+import (
+	"strings"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+)
+
+// A compiled pattern.
+type pattern interface {
+	// Matches a value against the pattern. If it matches, the values of the
+	// variables bound by the pattern are appended to bound, in the order the
+	// variables appear in the pattern.
+	match(v interface{}, bound []interface{}) ([]interface{}, bool)
+}
+
+// Matches any value; written as _.
+type wildcardPattern struct{}
+
+// Matches any value and binds it; written as $name.
+type bindPattern struct{}
+
+// Matches values equal to a string; written as a string literal.
+type literalPattern struct{ value string }
+
+// Matches lists; written as [p1 p2 @rest].
+type listPattern struct {
+	elems []pattern
+	// Whether the pattern ends in a rest variable, which allows more elements.
+	rest bool
+	// Whether the rest of the elements are bound; false for @_.
+	bindRest bool
+}
+
+// Matches maps, struct maps and records with the given keys; written as
+// [&k1=p1 &k2].
+// Other keys are allowed.
+type mapPattern struct {
+	keys   []string
+	values []pattern
+}
+
+func (wildcardPattern) match(v interface{}, bound []interface{}) ([]interface{}, bool) {
+	return bound, true
+}
+
+func (bindPattern) match(v interface{}, bound []interface{}) ([]interface{}, bool) {
+	return append(bound, v), true
+}
+
+func (p literalPattern) match(v interface{}, bound []interface{}) ([]interface{}, bool) {
+	return bound, vals.Equal(v, p.value)
+}
+
+func (p listPattern) match(v interface{}, bound []interface{}) ([]interface{}, bool) {
+	list, ok := v.(vals.List)
+	if !ok || list.Len() < len(p.elems) || (!p.rest && list.Len() > len(p.elems)) {
+		return bound, false
+	}
+	for i, elem := range p.elems {
+		v, _ := list.Index(i)
+		if bound, ok = elem.match(v, bound); !ok {
+			return bound, false
+		}
+	}
+	if p.bindRest {
+		bound = append(bound, list.SubVector(len(p.elems), list.Len()))
+	}
+	return bound, true
+}
+
+func (p mapPattern) match(v interface{}, bound []interface{}) ([]interface{}, bool) {
+	switch v.(type) {
+	case vals.Map, vals.StructMap, vals.Record:
+	default:
+		return bound, false
+	}
+	for i, key := range p.keys {
+		value, err := vals.Index(v, key)
+		if err != nil {
+			return bound, false
+		}
+		var ok bool
+		if bound, ok = p.values[i].match(value, bound); !ok {
+			return bound, false
+		}
+	}
+	return bound, true
+}
+
+// Returns whether a pattern matches all values.
+func irrefutable(p pattern) bool {
+	switch p.(type) {
+	case wildcardPattern, bindPattern:
+		return true
+	}
+	return false
+}
+
+// Compiles a pattern, and returns the names of the variables it binds.
+func (cp *compiler) pattern(n *parse.Compound) (pattern, []string) {
+	pc := &patternCompiler{cp: cp}
+	return pc.compile(n), pc.names
+}
+
+type patternCompiler struct {
+	cp    *compiler
+	names []string
+}
+
+func (pc *patternCompiler) compile(n *parse.Compound) pattern {
+	pn, ok := cmpd.Primary(n)
+	if !ok {
+		pc.cp.errorpf(n, "pattern must be a variable, string literal, list or map, found %s", cmpd.Shape(n))
+		return nil
+	}
+	if _, ok := restName(pn); ok {
+		pc.cp.errorpf(n, "rest variable only allowed at the end of list patterns")
+	}
+	switch pn.Type {
+	case parse.Bareword:
+		if pn.Value == "_" {
+			return wildcardPattern{}
+		}
+		return literalPattern{pn.Value}
+	case parse.SingleQuoted, parse.DoubleQuoted:
+		return literalPattern{pn.Value}
+	case parse.Variable:
+		pc.bind(n, pn.Value)
+		return bindPattern{}
+	case parse.List:
+		var p listPattern
+		for i, elem := range pn.Elements {
+			if elemPn, ok := cmpd.Primary(elem); ok {
+				if name, ok := restName(elemPn); ok {
+					if i != len(pn.Elements)-1 {
+						pc.cp.errorpf(elem, "rest variable only allowed at the end of list patterns")
+					}
+					p.rest = true
+					if name != "_" {
+						pc.bind(elem, name)
+						p.bindRest = true
+					}
+					continue
+				}
+			}
+			p.elems = append(p.elems, pc.compile(elem))
+		}
+		return p
+	case parse.Map:
+		var p mapPattern
+		for _, pair := range pn.MapPairs {
+			key, ok := cmpd.StringLiteral(pair.Key)
+			if !ok {
+				pc.cp.errorpf(pair.Key, "key in map pattern must be string literal, found %s", cmpd.Shape(pair.Key))
+			}
+			for _, seen := range p.keys {
+				if key == seen {
+					pc.cp.errorpf(pair.Key, "duplicate key %s in map pattern", parse.Quote(key))
+				}
+			}
+			p.keys = append(p.keys, key)
+			if pair.Value == nil || len(pair.Value.Indexings) == 0 {
+				// [&key] only requires the key to be present.
+				p.values = append(p.values, wildcardPattern{})
+			} else {
+				p.values = append(p.values, pc.compile(pair.Value))
+			}
+		}
+		return p
+	}
+	pc.cp.errorpf(n, "pattern must be a variable, string literal, list or map, found %s", cmpd.Shape(n))
+	return nil
+}
+
+// Records a variable bound by the pattern.
+func (pc *patternCompiler) bind(n *parse.Compound, name string) {
+	sigil, qname := SplitSigil(name)
+	name, rest := SplitQName(qname)
+	switch {
+	case sigil != "":
+		pc.cp.errorpf(n, "rest variable only allowed at the end of list patterns")
+	case rest != "":
+		pc.cp.errorpf(n, "variable in pattern must be unqualified")
+	case name == "":
+		pc.cp.errorpf(n, "variable name must not be empty")
+	}
+	for _, bound := range pc.names {
+		if name == bound {
+			pc.cp.errorpf(n, "variable $%s bound more than once in pattern", name)
+		}
+	}
+	pc.names = append(pc.names, name)
+}
+
+// Returns the name of the rest variable and true if pn is written like @name
+// or $@name.
+func restName(pn *parse.Primary) (string, bool) {
+	switch pn.Type {
+	case parse.Bareword, parse.Variable:
+		if strings.HasPrefix(pn.Value, "@") {
+			return pn.Value[1:], true
+		}
+	}
+	return "", false
+}
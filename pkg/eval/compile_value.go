@@ -259,7 +259,11 @@ func (cp *compiler) primaryOp(n *parse.Primary) valuesOp {
 		sigil, qname := SplitSigil(n.Value)
 		ref := resolveVarRef(cp, qname, n)
 		if ref == nil {
-			cp.errorpf(n, "variable $%s not found", qname)
+			if cp.undefinedVars == nil {
+				cp.errorpf(n, "variable $%s not found", qname)
+			}
+			*cp.undefinedVars = append(*cp.undefinedVars,
+				cp.newError(n, "variable $%s not found", qname))
 		}
 		return &variableOp{n.Range(), sigil != "", qname, ref}
 	case parse.Wildcard:
@@ -368,39 +372,42 @@ func (op outputCaptureOp) exec(fm *Frame) ([]interface{}, Exception) {
 
 func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 	// Parse signature.
-	var (
-		argNames      []string
-		restArg       int = -1
-		optNames      []string
-		optDefaultOps []valuesOp
-	)
+	sig := &signature{restArg: -1}
 	if len(n.Elements) > 0 {
 		// Argument list.
-		argNames = make([]string, len(n.Elements))
+		sig.argNames = make([]string, len(n.Elements))
+		sig.argKinds = make([]string, len(n.Elements))
 		for i, arg := range n.Elements {
-			ref := stringLiteralOrError(cp, arg, "argument name")
-			sigil, qname := SplitSigil(ref)
-			name, rest := SplitQName(qname)
-			if rest != "" {
-				cp.errorpf(arg, "argument name must be unqualified")
-			}
-			if name == "" {
-				cp.errorpf(arg, "argument name must not be empty")
-			}
-			if sigil == "@" {
-				if restArg != -1 {
+			name, kind, rest, defaultOp := cp.argSpec(arg)
+			if rest {
+				if sig.restArg != -1 {
 					cp.errorpf(arg, "only one argument may have @")
 				}
-				restArg = i
+				if defaultOp != nil {
+					cp.errorpf(arg, "rest argument may not have default value")
+				}
+				if len(sig.argDefaultOps) > 0 {
+					cp.errorpf(arg, "rest argument may not follow optional arguments")
+				}
+				sig.restArg = i
+			} else if defaultOp != nil {
+				if sig.restArg != -1 {
+					cp.errorpf(arg, "optional argument may not follow rest argument")
+				}
+				sig.argDefaultOps = append(sig.argDefaultOps, defaultOp)
+			} else if len(sig.argDefaultOps) > 0 {
+				cp.errorpf(arg, "required argument may not follow optional arguments")
 			}
-			argNames[i] = name
+			sig.argNames[i] = name
+			sig.argKinds[i] = kind
 		}
 	}
 	if len(n.MapPairs) > 0 {
-		optNames = make([]string, len(n.MapPairs))
-		optDefaultOps = make([]valuesOp, len(n.MapPairs))
+		sig.optNames = make([]string, len(n.MapPairs))
+		sig.optKinds = make([]string, len(n.MapPairs))
+		sig.optDefaultOps = make([]valuesOp, len(n.MapPairs))
 		for i, opt := range n.MapPairs {
-			qname := stringLiteralOrError(cp, opt.Key, "option name")
+			qname, kind := splitKind(cp, opt.Key, stringLiteralOrError(cp, opt.Key, "option name"))
 			name, rest := SplitQName(qname)
 			if rest != "" {
 				cp.errorpf(opt.Key, "option name must be unqualified")
@@ -408,40 +415,126 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 			if name == "" {
 				cp.errorpf(opt.Key, "option name must not be empty")
 			}
-			optNames[i] = name
+			sig.optNames[i] = name
+			sig.optKinds[i] = kind
 			if opt.Value == nil {
 				cp.errorpf(opt.Key, "option must have default value")
 			} else {
-				optDefaultOps[i] = cp.compoundOp(opt.Value)
+				sig.optDefaultOps[i] = cp.compoundOp(opt.Value)
 			}
 		}
 	}
 
+	return cp.closureOp(n, sig, func() effectOp { return cp.chunkOp(n.Chunk) })
+}
+
+// Signature of a closure.
+type signature struct {
+	argNames []string
+	// Kinds of the arguments, as returned by vals.Kind; empty for arguments
+	// that accept any value.
+	argKinds []string
+	// The index of the rest argument. -1 if there is no rest argument.
+	restArg int
+	// Ops for the default values of the trailing optional arguments.
+	argDefaultOps []valuesOp
+	optNames      []string
+	optKinds      []string
+	optDefaultOps []valuesOp
+}
+
+// Kinds that arguments and options may be declared with. Except for num, they
+// are the kinds of values they accept, as returned by vals.Kind.
+var argKinds = map[string]bool{
+	"num": true, "string": true, "list": true, "map": true, "fn": true,
+}
+
+// Compiles an element of the argument list of a lambda, written as
+// [@]name[:kind][=default]. It returns the name and kind of the argument,
+// whether it is the rest argument, and the op for the default value, or nil if
+// the argument is not optional.
+//
+// The default value is the rest of the compound after "=", so it can be any
+// expression except a list or map, which would be parsed as an index of
+// "name=".
+func (cp *compiler) argSpec(n *parse.Compound) (string, string, bool, valuesOp) {
+	var spec string
+	var defaultOp valuesOp
+	if len(n.Indexings) > 0 && n.Indexings[0].Head.Type == parse.Bareword &&
+		strings.Contains(n.Indexings[0].Head.Value, "=") {
+		head := n.Indexings[0]
+		if len(head.Indicies) > 0 {
+			cp.errorpf(head, "default value of argument may not be a list or map literal; use an output capture like (put [...]) instead")
+		}
+		i := strings.IndexByte(head.Head.Value, '=')
+		spec = head.Head.Value[:i]
+		defaultRange := diag.Ranging{From: head.Range().From + i + 1, To: n.Range().To}
+		var subops []valuesOp
+		if value := head.Head.Value[i+1:]; value != "" {
+			subops = append(subops, literalValues(defaultRange, value))
+		}
+		subops = append(subops, cp.indexingOps(n.Indexings[1:])...)
+		if len(subops) == 0 {
+			defaultOp = literalValues(defaultRange, "")
+		} else {
+			defaultOp = compoundOp{defaultRange, false, subops}
+		}
+	} else {
+		spec = stringLiteralOrError(cp, n, "argument name")
+	}
+
+	sigil, qname := SplitSigil(spec)
+	qname, kind := splitKind(cp, n, qname)
+	name, rest := SplitQName(qname)
+	if rest != "" {
+		cp.errorpf(n, "argument name must be unqualified")
+	}
+	if name == "" {
+		cp.errorpf(n, "argument name must not be empty")
+	}
+	return name, kind, sigil == "@", defaultOp
+}
+
+// Splits a name written as name:kind, and returns the name and the kind of
+// values it accepts. A trailing colon is part of the name, as in the names of
+// namespace variables.
+func splitKind(cp *compiler, r diag.Ranger, s string) (string, string) {
+	i := strings.IndexByte(s, ':')
+	if i == -1 || i == len(s)-1 {
+		return s, ""
+	}
+	kind := s[i+1:]
+	if !argKinds[kind] {
+		cp.errorpf(r, "unknown kind %s, must be one of num, string, list, map and fn", parse.Quote(kind))
+	}
+	return s[:i], kind
+}
+
+// Compiles an op that creates a closure with the given signature. The body is
+// compiled by calling compileBody in the scope of the closure.
+func (cp *compiler) closureOp(r diag.Ranger, sig *signature, compileBody func() effectOp) valuesOp {
 	local, capture := cp.pushScope()
-	for _, argName := range argNames {
+	for _, argName := range sig.argNames {
 		local.add(argName)
 	}
-	for _, optName := range optNames {
+	for _, optName := range sig.optNames {
 		local.add(optName)
 	}
 	scopeSizeInit := len(local.names)
-	chunkOp := cp.chunkOp(n.Chunk)
+	bodyOp := compileBody()
 	newLocal := local.names[scopeSizeInit:]
 	cp.popScope()
 
-	return &lambdaOp{n.Range(), argNames, restArg, optNames, optDefaultOps, newLocal, capture, chunkOp, cp.srcMeta}
+	return &lambdaOp{r.Range(), sig, newLocal, capture, bodyOp, cp.srcMeta}
 }
 
 type lambdaOp struct {
 	diag.Ranging
-	argNames      []string
-	restArg       int
-	optNames      []string
-	optDefaultOps []valuesOp
-	newLocal      []string
-	capture       *staticUpNs
-	subop         effectOp
-	srcMeta       parse.Source
+	sig      *signature
+	newLocal []string
+	capture  *staticUpNs
+	subop    effectOp
+	srcMeta  parse.Source
 }
 
 func (op *lambdaOp) exec(fm *Frame) ([]interface{}, Exception) {
@@ -456,15 +549,33 @@ func (op *lambdaOp) exec(fm *Frame) ([]interface{}, Exception) {
 			capture.slots[i] = fm.up.slots[op.capture.index[i]]
 		}
 	}
-	optDefaults := make([]interface{}, len(op.optDefaultOps))
-	for i, op := range op.optDefaultOps {
+	sig := op.sig
+	nRequired := len(sig.argNames) - len(sig.argDefaultOps)
+	argDefaults := make([]interface{}, len(sig.argDefaultOps))
+	for i, op := range sig.argDefaultOps {
+		defaultValue, err := evalForValue(fm, op, "argument default value")
+		if err != nil {
+			return nil, err
+		}
+		v, errKind := checkKind(sig.argKinds[nRequired+i], defaultValue, "default value of $"+sig.argNames[nRequired+i])
+		if errKind != nil {
+			return nil, fm.errorp(op, errKind)
+		}
+		argDefaults[i] = v
+	}
+	optDefaults := make([]interface{}, len(sig.optDefaultOps))
+	for i, op := range sig.optDefaultOps {
 		defaultValue, err := evalForValue(fm, op, "option default value")
 		if err != nil {
 			return nil, err
 		}
-		optDefaults[i] = defaultValue
+		v, errKind := checkKind(sig.optKinds[i], defaultValue, "default value of &"+sig.optNames[i])
+		if errKind != nil {
+			return nil, fm.errorp(op, errKind)
+		}
+		optDefaults[i] = v
 	}
-	return []interface{}{&closure{op.argNames, op.restArg, op.optNames, optDefaults, op.subop, op.newLocal, capture, op.srcMeta, op.Range()}}, nil
+	return []interface{}{&closure{sig.argNames, sig.argKinds, sig.restArg, argDefaults, sig.optNames, sig.optKinds, optDefaults, op.subop, op.newLocal, capture, op.srcMeta, op.Range()}}, nil
 }
 
 type mapOp struct {
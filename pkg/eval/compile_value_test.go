@@ -233,5 +233,49 @@ func TestClosure(t *testing.T) {
 		That("[&''=b]{ }").DoesNotCompile(),
 		// Should not have multiple rest arguments.
 		That("[@a @b]{ }").DoesNotCompile(),
+
+		// Optional arguments.
+		That("[a b=(num 2) c=foo]{ put $a $b $c } x").Puts("x", 2, "foo"),
+		That("[a b=(num 2) c=foo]{ put $a $b $c } x y").Puts("x", "y", "foo"),
+		That("[a b=(num 2) c=foo]{ put $a $b $c } x y z").Puts("x", "y", "z"),
+		That("[a b=]{ put $b } x").Puts(""),
+		That("var d = foo", "[a=$d]{ put $a }").Puts("foo"),
+		That("[a b=1]{ } ").Throws(
+			errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: 2, Actual: 0},
+			"[a b=1]{ } "),
+		That("[a b=1]{ } x y z").Throws(
+			errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: 2, Actual: 3}),
+		// Optional arguments must come after required ones, and may not be
+		// used together with rest arguments.
+		That("[a=1 b]{ }").DoesNotCompile(),
+		That("[a=1 @b]{ }").DoesNotCompile(),
+		That("[@a b=1]{ }").DoesNotCompile(),
+		That("[@a=1]{ }").DoesNotCompile(),
+		// Default value can't be a list literal, since it is parsed as an index.
+		That("[a=[x]]{ }").DoesNotCompile(),
+
+		// Kinds of arguments and options.
+		That("[a:num b:string c:list d:map e:fn]{ put $a } (num 1) s [] [&] $nop~").
+			Puts(1),
+		That("[a:num]{ put $a } 1").Puts(1),
+		That("[a:num]{ put $a } 1.5").Puts(1.5),
+		That("[a:num]{ } foo").Throws(
+			errs.BadValue{What: "argument $a", Valid: "num", Actual: "foo"},
+			"[a:num]{ } foo"),
+		That("[@a:num]{ put $a } 1 (num 2)").Puts(vals.MakeList(1, 2)),
+		That("[&a:num=(num 0)]{ put $a } &a=3").Puts(3),
+		That("[@a:string]{ put $a } x y").Puts(vals.MakeList("x", "y")),
+		That("[@a:string]{ } x []").Throws(
+			errs.BadValue{What: "element of argument $a", Valid: "string", Actual: "list"}),
+		That("[&a:list=[]]{ put $a } &a=[x]").Puts(vals.MakeList("x")),
+		That("[&a:list=[]]{ } &a=x").Throws(
+			errs.BadValue{What: "option &a", Valid: "list", Actual: "string"}),
+		That("[a:num=(num 1)]{ put $a }").Puts(1),
+		That("[a:num=1]{ put $a }").Puts(1),
+		That("[a:num=x]{ }").Throws(
+			errs.BadValue{What: "default value of $a", Valid: "num", Actual: "x"}, "x"),
+		// Names ending in a colon are still namespace names.
+		That("[a:]{ put $a:x } (ns [&x=foo])").Puts("foo"),
+		That("[a:bad]{ }").DoesNotCompile(),
 	)
 }
@@ -26,13 +26,22 @@ type compiler struct {
 	deprecations deprecationRegistry
 	// Information about the source.
 	srcMeta parse.Source
+	// If not nil, references to variables that are not found are recorded
+	// here instead of being compilation errors.
+	undefinedVars *[]*diag.Error
 }
 
 func compile(b, g *staticNs, tree parse.Tree, w io.Writer) (op nsOp, err error) {
+	return compileRecordingUndefinedVars(b, g, tree, w, nil)
+}
+
+// Like compile, but if undefinedVars is not nil, references to variables that
+// are not found are appended to it instead of stopping the compilation.
+func compileRecordingUndefinedVars(b, g *staticNs, tree parse.Tree, w io.Writer, undefinedVars *[]*diag.Error) (op nsOp, err error) {
 	g = g.clone()
 	cp := &compiler{
 		b, []*staticNs{g}, []*staticUpNs{new(staticUpNs)},
-		w, newDeprecationRegistry(), tree.Source}
+		w, newDeprecationRegistry(), tree.Source, undefinedVars}
 	defer func() {
 		r := recover()
 		if r == nil {
@@ -77,10 +86,14 @@ const compilationErrorType = "compilation error"
 
 func (cp *compiler) errorpf(r diag.Ranger, format string, args ...interface{}) {
 	// The panic is caught by the recover in compile above.
-	panic(&diag.Error{
+	panic(cp.newError(r, format, args...))
+}
+
+func (cp *compiler) newError(r diag.Ranger, format string, args ...interface{}) *diag.Error {
+	return &diag.Error{
 		Type:    compilationErrorType,
 		Message: fmt.Sprintf(format, args...),
-		Context: *diag.NewContext(cp.srcMeta.Name, cp.srcMeta.Code, r)})
+		Context: *diag.NewContext(cp.srcMeta.Name, cp.srcMeta.Code, r)}
 }
 
 // GetCompilationError returns a *diag.Error if the given value is a compilation
@@ -70,12 +70,19 @@ type Evaler struct {
 	// Whether to notify the success of background jobs, exposed as
 	// $notify-bg-job-sucess.
 	notifyBgJobSuccess bool
-	// The current number of background jobs, exposed as $num-bg-jobs.
-	numBgJobs int
+	// Jobs, exposed via the jobs, bg and fg commands. The number of jobs is
+	// exposed as $num-bg-jobs.
+	jobs jobTable
 	// Command-line arguments, exposed as $args.
 	args vals.List
 	// Chdir hooks, exposed indirectly as $before-chdir and $after-chdir.
 	beforeChdir, afterChdir []func(string)
+	// Whether tracing is enabled, accessed atomically; and the file to write
+	// traces to, nil for the standard error of the traced code. Exposed by the
+	// debug: module.
+	trace      int32
+	traceFile  *os.File
+	traceMutex sync.Mutex
 
 	// Dependencies.
 	//
@@ -118,7 +125,9 @@ type Editor interface {
 
 //elvdoc:var num-bg-jobs
 //
-// Number of background jobs.
+// Number of background jobs, including stopped jobs.
+//
+// @cf jobs
 
 //elvdoc:var notify-bg-job-success
 //
@@ -158,7 +167,6 @@ func NewEvaler() *Evaler {
 
 		valuePrefix:        defaultValuePrefix,
 		notifyBgJobSuccess: defaultNotifyBgJobSuccess,
-		numBgJobs:          0,
 		args:               vals.EmptyList,
 	}
 
@@ -176,7 +184,7 @@ func NewEvaler() *Evaler {
 		Add("notify-bg-job-success", vars.FromPtrWithMutex(
 			&ev.notifyBgJobSuccess, &ev.mu)).
 		Add("num-bg-jobs", vars.FromGet(func() interface{} {
-			return strconv.Itoa(ev.getNumBgJobs())
+			return strconv.Itoa(ev.jobs.count())
 		})).
 		Add("args", vars.FromGet(func() interface{} {
 			return ev.getArgs()
@@ -246,8 +254,8 @@ func (ev *Evaler) registerDeprecation(d deprecation) bool {
 	return ev.deprecations.register(d)
 }
 
-// Returns libdir.
-func (ev *Evaler) getLibDir() string {
+// LibDir returns the library directory for finding external modules.
+func (ev *Evaler) LibDir() string {
 	ev.mu.RLock()
 	defer ev.mu.RUnlock()
 	return ev.libDir
@@ -282,18 +290,6 @@ func (ev *Evaler) getNotifyBgJobSuccess() bool {
 	return ev.notifyBgJobSuccess
 }
 
-func (ev *Evaler) getNumBgJobs() int {
-	ev.mu.RLock()
-	defer ev.mu.RUnlock()
-	return ev.numBgJobs
-}
-
-func (ev *Evaler) addNumBgJobs(delta int) {
-	ev.mu.Lock()
-	defer ev.mu.Unlock()
-	ev.numBgJobs += delta
-}
-
 func (ev *Evaler) getArgs() vals.List {
 	ev.mu.RLock()
 	defer ev.mu.RUnlock()
@@ -389,6 +385,10 @@ type EvalCfg struct {
 	// Whether the Eval method should try to put the Elvish in the foreground
 	// after the code is executed.
 	PutInFg bool
+	// Whether pipelines should be run as foreground jobs under job control.
+	// This should only be set when Elvish is running interactively in the
+	// foreground of a terminal.
+	JobControl bool
 	// If not nil, used the given global namespace, instead of Evaler's own.
 	Global *Ns
 }
@@ -490,7 +490,7 @@ func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
 		intCh, intChCleanup = cfg.Interrupt()
 	}
 
-	fm := &Frame{ev, src, cfg.Global, new(Ns), intCh, cfg.Ports, nil, false}
+	fm := &Frame{ev, src, cfg.Global, new(Ns), intCh, nil, cfg.Ports, nil, nil, cfg.JobControl, nil}
 	return fm, func() {
 		if intChCleanup != nil {
 			intChCleanup()
@@ -521,6 +521,17 @@ func (ev *Evaler) CheckTree(tree parse.Tree, w io.Writer) *diag.Error {
 	return GetCompilationError(compileErr)
 }
 
+// FindUndefinedVars returns compilation errors for all the references to
+// variables that are not found in the given parsed source tree. Unlike
+// CheckTree, it does not stop at the first such reference, but it does stop at
+// other compilation errors.
+func (ev *Evaler) FindUndefinedVars(tree parse.Tree) []*diag.Error {
+	var undefinedVars []*diag.Error
+	compileRecordingUndefinedVars(ev.Builtin().static(), ev.Global().static(),
+		tree, nil, &undefinedVars)
+	return undefinedVars
+}
+
 // Compiles a parsed tree.
 func (ev *Evaler) compile(tree parse.Tree, g *Ns, w io.Writer) (nsOp, error) {
 	return compile(ev.Builtin().static(), g.static(), tree, w)
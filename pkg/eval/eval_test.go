@@ -1,6 +1,7 @@
 package eval_test
 
 import (
+	"reflect"
 	"strconv"
 	"sync"
 	"syscall"
@@ -163,3 +164,34 @@ func TestCheck(t *testing.T) {
 		})
 	}
 }
+
+var findUndefinedVarsTests = []struct {
+	name string
+	code string
+	want []string
+}{
+	{name: "no undefined variables", code: "var x; echo $x $true", want: nil},
+	{name: "all undefined variables are found",
+		code: "echo $x; fn f { echo $y }", want: []string{"x", "y"}},
+	{name: "stops at other compilation errors",
+		code: "echo $x; set a:b = c; echo $y", want: []string{"x"}},
+}
+
+func TestFindUndefinedVars(t *testing.T) {
+	ev := NewEvaler()
+	for _, test := range findUndefinedVarsTests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := parse.Parse(parse.Source{Code: test.code}, parse.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, err := range ev.FindUndefinedVars(tree) {
+				names = append(names, err.Context.RelevantString()[1:])
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("got %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,10 @@ package eval
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"syscall"
 	"unsafe"
 
@@ -45,6 +47,9 @@ type exception struct {
 type StackTrace struct {
 	Head *diag.Context
 	Next *StackTrace
+	// The command called at Head, as written in the code; empty if Head is not
+	// a command call.
+	Callee string
 }
 
 // Reason returns the Reason field if err is an Exception. Otherwise it returns
@@ -71,6 +76,12 @@ func (exc *exception) Error() string { return exc.reason.Error() }
 
 // Show shows the exception.
 func (exc *exception) Show(indent string) string {
+	return exc.show(indent, false)
+}
+
+// Shows the exception. If full is true, all the entries of the stack trace are
+// shown along with the functions they are in, even if there is only one entry.
+func (exc *exception) show(indent string, full bool) string {
 	buf := new(bytes.Buffer)
 
 	var causeDescription string
@@ -85,7 +96,14 @@ func (exc *exception) Show(indent string) string {
 
 	if exc.stackTrace != nil {
 		buf.WriteString("\n")
-		if exc.stackTrace.Next == nil {
+		if full {
+			buf.WriteString(indent + "Traceback (innermost first):")
+			for tb := exc.stackTrace; tb != nil; tb = tb.Next {
+				buf.WriteString("\n" + indent + "  ")
+				buf.WriteString(tb.Head.Show(indent + "    "))
+				buf.WriteString("\n" + indent + "    in " + fnName(tb.Next))
+			}
+		} else if exc.stackTrace.Next == nil {
 			buf.WriteString(exc.stackTrace.Head.ShowCompact(indent))
 		} else {
 			buf.WriteString(indent + "Traceback:")
@@ -102,7 +120,12 @@ func (exc *exception) Show(indent string) string {
 			if e == OK {
 				continue
 			}
-			buf.WriteString("\n" + indent + "  " + e.Show(indent+"  "))
+			buf.WriteString("\n" + indent + "  ")
+			if e, ok := e.(*exception); ok {
+				buf.WriteString(e.show(indent+"  ", full))
+			} else {
+				buf.WriteString(e.Show(indent + "  "))
+			}
 		}
 	}
 
@@ -145,6 +168,97 @@ type excFields struct{ e *exception }
 func (excFields) IsStructMap()    {}
 func (f excFields) Reason() error { return f.e.reason }
 
+func (f excFields) StackTrace() vals.List {
+	li := vals.EmptyList
+	for _, frame := range f.e.frames() {
+		li = li.Cons(frame)
+	}
+	return li
+}
+
+// An entry of the stack trace of an exception, as seen from Elvish code.
+type stackFrame struct {
+	SrcName string `json:"src-name"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Code    string `json:"code"`
+	// The function that the code is in; empty for top-level code.
+	Fn string `json:"fn"`
+}
+
+func (stackFrame) IsStructMap() {}
+
+// Returns the entries of the stack trace, innermost first.
+func (exc *exception) frames() []stackFrame {
+	frames := []stackFrame{}
+	for tb := exc.stackTrace; tb != nil; tb = tb.Next {
+		line, col := tb.Head.Position()
+		var fn string
+		if tb.Next != nil {
+			fn = tb.Next.Callee
+		}
+		frames = append(frames, stackFrame{
+			tb.Head.Name, line, col,
+			strings.TrimRight(tb.Head.RelevantString(), " \t\n"), fn})
+	}
+	return frames
+}
+
+// Returns a description of the function containing the code at the head of
+// the stack trace whose next entry is tb.
+func fnName(tb *StackTrace) string {
+	switch {
+	case tb == nil:
+		return "top level"
+	case tb.Callee == "":
+		return "<unknown>"
+	default:
+		return tb.Callee
+	}
+}
+
+// MarshalJSON encodes the exception as a JSON object with a "reason" field,
+// which contains the fields of the reason and its error message, and a
+// "stack-trace" field, which contains the entries of the stack trace.
+func (exc *exception) MarshalJSON() ([]byte, error) {
+	var reason map[string]interface{}
+	if exc.reason != nil {
+		reason = map[string]interface{}{}
+		if fields, ok := exc.reason.(vals.PseudoStructMap); ok {
+			reason = jsonValue(fields).(map[string]interface{})
+		}
+		reason["message"] = exc.reason.Error()
+	}
+	return json.Marshal(map[string]interface{}{
+		"reason": reason, "stack-trace": exc.frames()})
+}
+
+// Converts struct maps, including those nested in lists, to Go maps that can
+// be encoded as JSON objects.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *exception:
+		// Encoded with MarshalJSON.
+		return v
+	case vals.StructMap, vals.PseudoStructMap:
+		m := map[string]interface{}{}
+		vals.IterateKeys(v, func(k interface{}) bool {
+			value, _ := vals.Index(v, k)
+			m[k.(string)] = jsonValue(value)
+			return true
+		})
+		return m
+	case vals.List:
+		elems := []interface{}{}
+		for it := v.Iterator(); it.HasElem(); it.Next() {
+			elems = append(elems, jsonValue(it.Elem()))
+		}
+		return elems
+	default:
+		return v
+	}
+}
+
 // PipelineError represents the errors of pipelines, in which multiple commands
 // may error.
 type PipelineError struct {
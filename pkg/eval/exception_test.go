@@ -33,7 +33,7 @@ func TestException(t *testing.T) {
 		Hash(hash.Pointer(unsafe.Pointer(reflect.ValueOf(exc).Pointer()))).
 		Equal(exc).
 		NotEqual(makeException(errors.New("error"))).
-		AllKeys("reason").
+		AllKeys("reason", "stack-trace").
 		Index("reason", err).
 		IndexError("stack", vals.NoSuchKey("stack")).
 		Repr("[&reason=[&content=error &type=fail]]")
@@ -84,6 +84,30 @@ func TestPipelineError_Fields(t *testing.T) {
 	)
 }
 
+func TestException_StackTrace(t *testing.T) {
+	Test(t,
+		That("fn f { fail bad }", "var e = ?(f)",
+			"each [fr]{ put $fr[line] $fr[column] $fr[code] $fr[fn] } $e[stack-trace]").
+			Puts(1, 8, "fail bad", "f", 2, 11, "f", ""),
+		That("put ?(fail bad)[stack-trace][0][src-name]").Puts("[test]"),
+		That("count $ok[stack-trace]").Puts(0),
+	)
+}
+
+func TestException_JSON(t *testing.T) {
+	Test(t,
+		That("put ?(fail bad) | to-json").Prints(
+			`{"reason":{"content":"bad","message":"bad","type":"fail"},`+
+				`"stack-trace":[{"src-name":"[test]","line":1,"column":7,`+
+				`"code":"fail bad","fn":""}]}`+"\n"),
+		That("put $ok | to-json").Prints(`{"reason":null,"stack-trace":[]}`+"\n"),
+		// Nested exceptions and lists.
+		That("put ?(fail 1 | fail 2) | to-json | from-json |",
+			"each [e]{ put $e[reason][exceptions][1][reason][content] }").
+			Puts("2"),
+	)
+}
+
 func TestErrorMethods(t *testing.T) {
 	tt.Test(t, tt.Fn("Error", error.Error), tt.Table{
 		tt.Args(makeException(errors.New("err"))).Rets("err"),
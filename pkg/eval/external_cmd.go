@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/exec"
 	"sync/atomic"
-	"syscall"
 
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
@@ -90,13 +89,24 @@ func (e externalCmd) Call(fm *Frame, argVals []interface{}, opts map[string]inte
 
 	args[0] = path
 
-	sys := makeSysProcAttr(fm.background)
-	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	proc, err := startProcess(fm.job, path, args, files)
 	if err != nil {
 		return err
 	}
+	pid := proc.Pid
+	if fm.cancelCh != nil {
+		waited := make(chan struct{})
+		defer close(waited)
+		go func() {
+			select {
+			case <-fm.cancelCh:
+				proc.Kill()
+			case <-waited:
+			}
+		}()
+	}
 
-	state, err := proc.Wait()
+	ws, err := waitProcess(fm.job, proc)
 	if err != nil {
 		// This should be a can't happen situation. Nonetheless, treat it as a
 		// soft error rather than panicking since the Go documentation is not
@@ -104,12 +114,11 @@ func (e externalCmd) Call(fm *Frame, argVals []interface{}, opts map[string]inte
 		// calling `Wait` twice on a particular process object.
 		return err
 	}
-	ws := state.Sys().(syscall.WaitStatus)
 	if ws.Signaled() && isSIGPIPE(ws.Signal()) {
 		readerGone := fm.ports[1].readerGone
 		if readerGone != nil && atomic.LoadInt32(readerGone) == 1 {
 			return errs.ReaderGone{}
 		}
 	}
-	return NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), proc.Pid)
+	return NewExternalCmdExit(e.Name, ws, pid)
 }
@@ -25,11 +25,24 @@ type Frame struct {
 	local, up *Ns
 
 	intCh <-chan struct{}
-	ports []*Port
+	// Closed when the innermost enclosing cancellation scope is canceled, nil
+	// if the frame is not in any cancellation scope. External commands are
+	// killed when it is closed.
+	cancelCh <-chan struct{}
+	ports    []*Port
 
 	traceback *StackTrace
 
-	background bool
+	// The job that this frame belongs to, nil if the frame is not part of a
+	// job.
+	job *job
+	// Whether pipelines run in this frame are foreground jobs under job
+	// control.
+	jobControl bool
+
+	// Callables deferred in the closure that this frame belongs to, nil if the
+	// frame does not belong to a closure.
+	defers *deferStack
 }
 
 // PrepareEval prepares a piece of code for evaluation in a copy of the current
@@ -55,7 +68,8 @@ func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func
 		traceback = fm.addTraceback(r)
 	}
 	newFm := &Frame{
-		fm.Evaler, src, local, new(Ns), fm.intCh, fm.ports, traceback, fm.background}
+		fm.Evaler, src, local, new(Ns), fm.intCh, fm.cancelCh, fm.ports, traceback,
+		fm.job, fm.jobControl, nil}
 	op, err := compile(newFm.Evaler.Builtin().static(), local.static(), tree, fm.ErrorFile())
 	if err != nil {
 		return nil, nil, err
@@ -165,8 +179,8 @@ func (fm *Frame) fork(name string) *Frame {
 	return &Frame{
 		fm.Evaler, fm.srcMeta,
 		fm.local, fm.up,
-		fm.intCh, newPorts,
-		fm.traceback, fm.background,
+		fm.intCh, fm.cancelCh, newPorts,
+		fm.traceback, fm.job, fm.jobControl, fm.defers,
 	}
 }
 
@@ -205,6 +219,52 @@ func (fm *Frame) addTraceback(r diag.Ranger) *StackTrace {
 	}
 }
 
+// Callables deferred with the defer builtin. It is shared by all the frames
+// forked from the frame of a closure call, some of which may run concurrently.
+type deferStack struct {
+	mutex sync.Mutex
+	fns   []func(*Frame) Exception
+}
+
+func (ds *deferStack) push(f func(*Frame) Exception) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.fns = append(ds.fns, f)
+}
+
+// Runs the deferred callables in the reverse order they were deferred, and
+// combines their exceptions with exc, the exception from the closure body.
+func (fm *Frame) runDefers(exc Exception) error {
+	ds := fm.defers
+	ds.mutex.Lock()
+	fns := ds.fns
+	ds.fns = nil
+	ds.mutex.Unlock()
+	if len(fns) == 0 {
+		if exc == nil {
+			return nil
+		}
+		return exc
+	}
+
+	excs := []Exception{exc}
+	failed := false
+	for i := len(fns) - 1; i >= 0; i-- {
+		deferExc := fns[i](fm)
+		if deferExc != nil {
+			failed = true
+		}
+		excs = append(excs, deferExc)
+	}
+	if exc != nil && failed {
+		if _, ok := exc.Reason().(Flow); ok {
+			// A failing deferred callable overrides flow commands like return.
+			excs[0] = nil
+		}
+	}
+	return MakePipelineError(excs)
+}
+
 // Returns an Exception with specified range and cause.
 func (fm *Frame) errorp(r diag.Ranger, e error) Exception {
 	switch e := e.(type) {
@@ -62,3 +62,35 @@ func ListenInterrupts() (<-chan struct{}, func()) {
 		<-stopped
 	}
 }
+
+// Returns a copy of fm in a cancellation scope that is canceled when cancel is
+// closed. Canceling the scope interrupts the code running in the new frame and
+// kills the external commands it has started; the scope is also canceled
+// when an enclosing scope is. It also returns a function that should be called
+// when the scope is no longer needed.
+func (fm *Frame) withCancel(cancel <-chan struct{}) (*Frame, func()) {
+	stop := make(chan struct{})
+	newFm := fm.fork("cancellation scope")
+	newFm.intCh = closeOnEither(fm.intCh, cancel, stop)
+	newFm.cancelCh = closeOnEither(fm.cancelCh, cancel, stop)
+	return newFm, func() {
+		close(stop)
+		newFm.Close()
+	}
+}
+
+// Returns a channel that is closed when either a or b is closed, unless stop is
+// closed first.
+func closeOnEither(a, b, stop <-chan struct{}) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		select {
+		case <-a:
+		case <-b:
+		case <-stop:
+			return
+		}
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,324 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/parse"
+)
+
+// Job control.
+//
+// A job is a pipeline that is tracked by the Evaler. All the external commands
+// started by a job are put into the same process group, so that they can be
+// stopped and continued as a unit. Elvish code in a job keeps running even when
+// all of the external commands of the job are stopped, since there is no way to
+// suspend goroutines.
+//
+// Background jobs are always tracked. Foreground jobs are only created when
+// job control is enabled (see EvalCfg.JobControl); they only enter the job
+// table when they get stopped.
+
+// ErrNoSuchJob is thrown when a job specification does not refer to any job.
+var ErrNoSuchJob = errors.New("no such job")
+
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobStopped
+	jobDone
+)
+
+var jobStateNames = [...]string{"running", "stopped", "done"}
+
+func (s jobState) String() string { return jobStateNames[s] }
+
+type job struct {
+	source string
+	// Closed when all the forms of the pipeline have terminated.
+	done chan struct{}
+	// Receives a value when all the running processes of the job have
+	// stopped.
+	stopped chan struct{}
+	// Serializes the starting of processes.
+	startMu sync.Mutex
+
+	mu sync.Mutex
+	// Job number; 0 if the job has not been added to the job table.
+	num int
+	// Whether the job is in the background. New processes of a foreground job
+	// are put in the foreground of the terminal.
+	bg bool
+	// Whether some code is waiting for the job to finish. When a job that is
+	// not being waited finishes, a notification is written.
+	waited bool
+	// Process group ID; 0 if the job has not started any process yet.
+	pgid int
+
+	nRunning, nStopped int
+	state              jobState
+	err                error
+}
+
+func newJob(source string, bg bool) *job {
+	return &job{
+		source: source, done: make(chan struct{}), stopped: make(chan struct{}, 1),
+		bg: bg, waited: !bg}
+}
+
+// Called after a process of the job has been started in the given process
+// group.
+func (j *job) processStarted(pgid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pgid = pgid
+	j.nRunning++
+}
+
+// Called when a process of the job has been stopped.
+func (j *job) processStopped() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nRunning--
+	j.nStopped++
+	j.checkStopped()
+}
+
+// Called when a process of the job has exited.
+func (j *job) processExited() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nRunning--
+	j.checkStopped()
+}
+
+// Must be called with j.mu held.
+func (j *job) checkStopped() {
+	if j.nRunning == 0 && j.nStopped > 0 && j.state == jobRunning {
+		j.state = jobStopped
+		select {
+		case j.stopped <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Marks all the stopped processes as running again, returning the process
+// group ID. The caller is responsible for actually continuing the processes.
+func (j *job) continued() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == jobStopped {
+		j.state = jobRunning
+	}
+	j.nRunning += j.nStopped
+	j.nStopped = 0
+	return j.pgid
+}
+
+// Moves the job to the foreground, and starts waiting for it.
+func (j *job) toForeground() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.bg = false
+	j.waited = true
+	// Drain any stale notification.
+	select {
+	case <-j.stopped:
+	default:
+	}
+}
+
+// Moves the job to the background.
+func (j *job) toBackground() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.bg = true
+	j.waited = false
+}
+
+// Stops waiting for the job. It returns false if the job has already finished.
+func (j *job) detach() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == jobDone {
+		return false
+	}
+	j.waited = false
+	return true
+}
+
+// Records that the job has finished. It returns whether the caller is
+// responsible for notifying the completion of the job.
+func (j *job) finish(err error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = jobDone
+	j.err = err
+	close(j.done)
+	return !j.waited
+}
+
+func (j *job) getPgid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pgid
+}
+
+func (j *job) isBackground() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.bg
+}
+
+func (j *job) result() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+type jobInfo struct {
+	Num   int
+	State string
+	Pgid  int
+	Cmd   string
+}
+
+func (jobInfo) IsStructMap() {}
+
+func (j *job) info() jobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return jobInfo{j.num, j.state.String(), j.pgid, j.source}
+}
+
+// A table of jobs, indexed by job numbers. The zero value is an empty table
+// ready to use.
+type jobTable struct {
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// Adds a job to the table if it is not already there, and returns its number.
+// Job numbers are allocated in increasing order, starting from 1.
+func (t *jobTable) add(j *job) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.num != 0 {
+		return j.num
+	}
+	j.num = 1
+	if len(t.jobs) > 0 {
+		j.num = t.jobs[len(t.jobs)-1].num + 1
+	}
+	t.jobs = append(t.jobs, j)
+	return j.num
+}
+
+// Removes a job from the table. It is a no-op if the job is not in the table.
+func (t *jobTable) remove(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, j2 := range t.jobs {
+		if j2 == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *jobTable) list() []*job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*job(nil), t.jobs...)
+}
+
+func (t *jobTable) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.jobs)
+}
+
+// Finds the job referred to by a job specification. The specification may be
+// the job number, optionally prefixed with "%", or one of "%%" and "%+", which
+// refer to the current job (the one with the largest job number). If specs is
+// empty, it also refers to the current job.
+func (t *jobTable) find(specs []string) (*job, error) {
+	if len(specs) > 1 {
+		return nil, errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 1, Actual: len(specs)}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(specs) == 0 || specs[0] == "%%" || specs[0] == "%+" {
+		if len(t.jobs) == 0 {
+			return nil, ErrNoSuchJob
+		}
+		return t.jobs[len(t.jobs)-1], nil
+	}
+	num, err := strconv.Atoi(strings.TrimPrefix(specs[0], "%"))
+	if err != nil || num <= 0 {
+		return nil, errs.BadValue{
+			What: "job specification", Valid: "job number, %%, or %+", Actual: parse.Quote(specs[0])}
+	}
+	i := sort.Search(len(t.jobs), func(i int) bool { return t.jobs[i].num >= num })
+	if i == len(t.jobs) || t.jobs[i].num != num {
+		return nil, ErrNoSuchJob
+	}
+	return t.jobs[i], nil
+}
+
+// Replaces the ports of a job's frame that are created by FilePort with ports
+// owned by the job. The job can outlive the code that started it, which closes
+// its ports when it finishes, since the job may be stopped or in the background.
+// It returns a function to close the new ports, which should be called when the
+// job has finished.
+func ownFilePorts(fm *Frame) func() {
+	var cleanups []func()
+	for i, p := range fm.ports {
+		if p != nil && p.filePortPrefix != nil {
+			var cleanup func()
+			fm.ports[i], cleanup = FilePort(p.File, *p.filePortPrefix)
+			cleanups = append(cleanups, cleanup)
+		}
+	}
+	return func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}
+}
+
+// Waits for a foreground job to either finish or stop. In the latter case, the
+// job is added to the job table and a nil error is returned.
+func (fm *Frame) waitForegroundJob(j *job) error {
+	defer func() {
+		if j.getPgid() == 0 {
+			// No process was started, so the terminal was never given away.
+			return
+		}
+		err := putSelfInFg()
+		if err != nil {
+			fmt.Fprintln(fm.ErrorFile(), "failed to put myself in foreground:", err)
+		}
+	}()
+	select {
+	case <-j.done:
+	case <-j.stopped:
+		if j.detach() {
+			num := fm.Evaler.jobs.add(j)
+			fmt.Fprintf(fm.ErrorFile(), "job %d stopped: %s\n", num, j.source)
+			return nil
+		}
+		<-j.done
+	}
+	fm.Evaler.jobs.remove(j)
+	return j.result()
+}
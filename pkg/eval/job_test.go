@@ -0,0 +1,27 @@
+package eval_test
+
+import (
+	"testing"
+
+	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+
+	. "src.elv.sh/pkg/eval/evaltest"
+)
+
+func TestJobs(t *testing.T) {
+	Test(t,
+		That("jobs").DoesNothing(),
+		That("put $num-bg-jobs").Puts("0"),
+	)
+}
+
+func TestJobSpecs(t *testing.T) {
+	Test(t,
+		That("bg").Throws(OneOfErrors(ErrNoSuchJob, ErrorWithMessage("not supported on Windows"))),
+		That("fg %1").Throws(OneOfErrors(ErrNoSuchJob, ErrorWithMessage("not supported on Windows"))),
+		That("fg %1 %2").Throws(OneOfErrors(
+			errs.ArityMismatch{What: "arguments", ValidLow: 0, ValidHigh: 1, Actual: 2},
+			ErrorWithMessage("not supported on Windows"))),
+	)
+}
@@ -0,0 +1,116 @@
+// +build !windows,!plan9,!js
+
+package eval_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestJobs_StopAndContinue(t *testing.T) {
+	jt := newJobTester(t)
+	defer jt.cleanup()
+
+	// The background job stops itself, gets continued by fg and then exits.
+	jt.eval("sh -c 'kill -STOP $$; exit 3' &")
+	jt.waitForStoppedJob()
+	if _, err := jt.eval("fg %1"); err == nil {
+		t.Errorf("fg %%1 => no error, want error")
+	}
+
+	jt.eval("sh -c 'kill -STOP $$' &")
+	jt.waitForStoppedJob()
+	out, err := jt.eval(
+		"put (jobs | each [j]{ put $j[num] $j[cmd] })", "fg", "put $num-bg-jobs")
+	want := "▶ (num 1)\n▶ 'sh -c ''kill -STOP $$'' &'\n▶ 0\n"
+	if out != want || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", out, err, want)
+	}
+}
+
+func TestJobs_StopAndResumeForegroundPipeline(t *testing.T) {
+	jt := newJobTester(t)
+	defer jt.cleanup()
+
+	// The foreground pipeline is stopped when sh stops itself. The Elvish code
+	// in it keeps writing to the pipeline's ports after fg resumes it, even
+	// though the ports of the code that started the pipeline have been closed.
+	out1, err := jt.eval("{ put a; sh -c 'kill -STOP $$'; put b } | each [x]{ put $x$x }")
+	if err != nil {
+		t.Errorf("got error %v when stopping pipeline", err)
+	}
+	out2, err := jt.eval("fg", "put $num-bg-jobs")
+	// The Elvish code is not stopped, so "aa" may be written after the first
+	// evaluation returns.
+	want := "▶ aa\n▶ bb\n▶ 0\n"
+	if out := out1 + out2; out != want || err != nil {
+		t.Errorf("got (%q, %v), want (%q, nil)", out, err, want)
+	}
+}
+
+// Evaluates code with job control, like the interactive shell does: each call
+// to eval uses new ports created from the same files, and closes them
+// afterwards.
+type jobTester struct {
+	t       *testing.T
+	ev      *Evaler
+	outFile *os.File
+	// How much of outFile has been read.
+	read int
+}
+
+func newJobTester(t *testing.T) *jobTester {
+	outFile, err := ioutil.TempFile("", "elvish-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &jobTester{t: t, ev: NewEvaler(), outFile: outFile}
+}
+
+func (jt *jobTester) cleanup() {
+	jt.outFile.Close()
+	os.Remove(jt.outFile.Name())
+}
+
+// Evaluates the lines, stopping at the first error, and returns what has been
+// written to the output since the last call.
+func (jt *jobTester) eval(lines ...string) (string, error) {
+	jt.t.Helper()
+	ports, cleanup := PortsFromFiles([3]*os.File{DevNull, jt.outFile, DevNull}, "▶ ")
+	var errEval error
+	for _, line := range lines {
+		errEval = jt.ev.Eval(parse.Source{Name: "[test]", Code: line},
+			EvalCfg{Ports: ports, JobControl: true})
+		if errEval != nil {
+			break
+		}
+	}
+	cleanup()
+	out, err := ioutil.ReadFile(jt.outFile.Name())
+	if err != nil {
+		jt.t.Fatal(err)
+	}
+	newOut := out[jt.read:]
+	jt.read = len(out)
+	return string(newOut), errEval
+}
+
+// Waits until the job with the largest number is stopped.
+func (jt *jobTester) waitForStoppedJob() {
+	jt.t.Helper()
+	deadline := time.Now().Add(testutil.ScaledMs(5000))
+	for time.Now().Before(deadline) {
+		out, _ := jt.eval("put [(jobs | each [j]{ put $j[state] })][-1]")
+		if out == "▶ stopped\n" {
+			return
+		}
+		time.Sleep(testutil.ScaledMs(10))
+	}
+	jt.t.Fatal("timed out waiting for the job to stop")
+}
@@ -0,0 +1,270 @@
+// Package chanmod exposes channels, which Elvish code can use to communicate
+// between concurrently running functions, as an Elvish module.
+package chanmod
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// Ns is the namespace for the chan: module.
+var Ns = eval.NsBuilder{}.AddGoFns("chan:", fns).Ns()
+
+var fns = map[string]interface{}{
+	"make":   makeChan,
+	"send":   send,
+	"recv":   recv,
+	"close":  closeChan,
+	"select": selectFn,
+}
+
+var (
+	// ErrClosed is thrown when receiving from a channel that is closed and has
+	// no more values.
+	ErrClosed = errors.New("channel is closed")
+	// ErrSendOnClosed is thrown when sending to a closed channel.
+	ErrSendOnClosed = errors.New("send on closed channel")
+	// ErrCloseClosed is thrown when closing a channel that is already closed.
+	ErrCloseClosed = errors.New("channel is already closed")
+)
+
+// Chan is a channel of Elvish values.
+type Chan struct {
+	ch chan interface{}
+	// Protects closed, which is needed to close the channel only once.
+	mutex  sync.Mutex
+	closed bool
+}
+
+// Kind returns "chan".
+func (*Chan) Kind() string { return "chan" }
+
+// Equal compares by address.
+func (c *Chan) Equal(rhs interface{}) bool { return c == rhs }
+
+// Hash returns the hash of the address.
+func (c *Chan) Hash() uint32 { return hash.Pointer(unsafe.Pointer(c)) }
+
+// Repr returns an opaque representation "<chan 0x23333333>".
+func (c *Chan) Repr(int) string { return fmt.Sprintf("<chan %p>", c) }
+
+//elvdoc:fn make
+//
+// ```elvish
+// chan:make &size=(num 0)
+// ```
+//
+// Outputs a new channel that can buffer `&size` values. Sending to a channel
+// blocks until the value is buffered or received by another function; when
+// `&size` is 0, the channel is unbuffered, and sending always blocks until the
+// value is received.
+//
+// Channels are usually used to communicate between functions running
+// concurrently, for instance with [`run-parallel`](builtin.html#run-parallel)
+// or [`peach`](builtin.html#peach). Here is an example of distributing work to
+// several workers, and collecting their results:
+//
+// ```elvish-transcript
+// ~> use chan
+// ~> var jobs = (chan:make &size=(num 10))
+// ~> var results = (chan:make &size=(num 10))
+// ~> fn worker {
+//      while $true {
+//        var r = (chan:select $jobs)
+//        if (not $r[ok]) { return }
+//        chan:send $results (* $r[value] 2)
+//      }
+//    }
+// ~> run-parallel $worker~ $worker~ {
+//      range 5 | each [x]{ chan:send $jobs $x }
+//      chan:close $jobs
+//    }
+// ~> chan:close $results
+// ~> while $true {
+//      var r = (chan:select $results)
+//      if (not $r[ok]) { break }
+//      put $r[value]
+//    } | order
+// ▶ (num 0)
+// ▶ (num 2)
+// ▶ (num 4)
+// ▶ (num 6)
+// ▶ (num 8)
+// ```
+//
+// @cf chan:send chan:recv chan:close chan:select
+
+type makeOpts struct{ Size int }
+
+func (*makeOpts) SetDefaultOptions() {}
+
+func makeChan(opts makeOpts) (*Chan, error) {
+	if opts.Size < 0 {
+		return nil, errs.OutOfRange{What: "size",
+			ValidLow: "0", ValidHigh: "inf", Actual: fmt.Sprint(opts.Size)}
+	}
+	return &Chan{ch: make(chan interface{}, opts.Size)}, nil
+}
+
+//elvdoc:fn send
+//
+// ```elvish
+// chan:send $chan $value
+// ```
+//
+// Sends a value to a channel, blocking until it is buffered or received. It
+// throws an exception if the channel is closed.
+//
+// @cf chan:recv
+
+func send(fm *eval.Frame, c *Chan, v interface{}) (err error) {
+	defer func() {
+		// Sending to a closed channel panics. Closing is rare, so it is
+		// cheaper to recover than to synchronize all the sends with close.
+		if recover() != nil {
+			err = ErrSendOnClosed
+		}
+	}()
+	select {
+	case c.ch <- v:
+		return nil
+	case <-fm.Interrupts():
+		return eval.ErrInterrupted
+	}
+}
+
+//elvdoc:fn recv
+//
+// ```elvish
+// chan:recv $chan
+// ```
+//
+// Receives a value from a channel and outputs it, blocking until there is one.
+// It throws an exception if the channel is closed and all the values sent to it
+// have been received.
+//
+// @cf chan:send chan:select
+
+func recv(fm *eval.Frame, c *Chan) (interface{}, error) {
+	select {
+	case v, ok := <-c.ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return v, nil
+	case <-fm.Interrupts():
+		return nil, eval.ErrInterrupted
+	}
+}
+
+//elvdoc:fn close
+//
+// ```elvish
+// chan:close $chan
+// ```
+//
+// Closes a channel. Values already sent to the channel can still be received,
+// but no more values can be sent. It is an error to close a channel twice.
+
+func closeChan(c *Chan) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return ErrCloseClosed
+	}
+	c.closed = true
+	close(c.ch)
+	return nil
+}
+
+//elvdoc:fn select
+//
+// ```elvish
+// chan:select &timeout=$nil $chan...
+// ```
+//
+// Waits until a value can be received from one of the channels, and outputs a
+// map with the following fields:
+//
+// -   `index` is the index of the channel among the arguments, starting from 0.
+//
+// -   `value` is the received value.
+//
+// -   `ok` is `$false` if the channel was closed and had no more values, in
+//     which case `value` is `$nil`.
+//
+// -   `timed-out` is `$true` if no value was received before the timeout.
+//
+// If more than one channel has a value, one of them is chosen randomly.
+//
+// If `&timeout` is given, `chan:select` waits at most that long, and outputs a
+// map with `timed-out` set to `$true` and `index` set to -1 if no value is
+// received. The timeout is written like the argument to
+// [`sleep`](builtin.html#sleep), either as a number of seconds or a duration
+// string like `1.5s`. Example:
+//
+// ```elvish-transcript
+// ~> use chan
+// ~> var a b = (chan:make &size=(num 1)) (chan:make)
+// ~> chan:send $a foo
+// ~> chan:select $a $b
+// ▶ [&index=(num 0) &value=foo &ok=$true &timed-out=$false]
+// ~> chan:select &timeout=0.1 $a $b
+// ▶ [&index=(num -1) &value=$nil &ok=$false &timed-out=$true]
+// ```
+//
+// @cf chan:recv
+
+type selectOpts struct{ Timeout interface{} }
+
+func (*selectOpts) SetDefaultOptions() {}
+
+type selectResult struct {
+	Index    int
+	Value    interface{}
+	Ok       bool
+	TimedOut bool
+}
+
+func (selectResult) IsStructMap() {}
+
+func selectFn(fm *eval.Frame, opts selectOpts, chans ...*Chan) (selectResult, error) {
+	cases := make([]reflect.SelectCase, len(chans), len(chans)+2)
+	for i, c := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)}
+	}
+	cases = append(cases, reflect.SelectCase{
+		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(fm.Interrupts())})
+	if opts.Timeout != nil {
+		d, ok := eval.ScanDuration(opts.Timeout)
+		if !ok {
+			return selectResult{}, eval.ErrInvalidTimeout
+		}
+		if d < 0 {
+			return selectResult{}, eval.ErrNegativeTimeout
+		}
+		cases = append(cases, reflect.SelectCase{
+			Dir: reflect.SelectRecv, Chan: reflect.ValueOf(eval.TimeAfter(fm, d))})
+	}
+
+	i, v, ok := reflect.Select(cases)
+	switch {
+	case i < len(chans):
+		var value interface{}
+		if ok {
+			value = v.Interface()
+		}
+		return selectResult{Index: i, Value: value, Ok: ok}, nil
+	case i == len(chans):
+		return selectResult{}, eval.ErrInterrupted
+	default:
+		return selectResult{Index: -1, TimedOut: true}, nil
+	}
+}
@@ -0,0 +1,93 @@
+package chanmod
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+)
+
+func TestChan(t *testing.T) {
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("chan", Ns).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("kind-of (chan:make)").Puts("chan"),
+		That("var c = (chan:make); eq $c $c").Puts(true),
+		That("eq (chan:make) (chan:make)").Puts(false),
+		That("chan:make &size=(num -1)").Throws(errs.OutOfRange{
+			What: "size", ValidLow: "0", ValidHigh: "inf", Actual: "-1"}),
+
+		// Buffered channels.
+		That(`
+			var c = (chan:make &size=(num 2))
+			chan:send $c foo; chan:send $c bar
+			chan:recv $c; chan:recv $c
+		`).Puts("foo", "bar"),
+		// Unbuffered channels, with the sender running concurrently.
+		That(`
+			var c = (chan:make)
+			run-parallel {
+				range 3 | each [x]{ chan:send $c $x }
+				chan:close $c
+			} {
+				while $true {
+					var r = (chan:select $c)
+					if (not $r[ok]) { break }
+					put $r[value]
+				}
+			}
+		`).Puts(0, 1, 2),
+
+		// Closing.
+		That(`
+			var c = (chan:make &size=(num 1))
+			chan:send $c foo; chan:close $c
+			chan:recv $c; chan:recv $c
+		`).Puts("foo").Throws(ErrClosed),
+		That("var c = (chan:make &size=(num 1)); chan:close $c; chan:send $c foo").
+			Throws(ErrSendOnClosed),
+		That("var c = (chan:make); chan:close $c; chan:close $c").
+			Throws(ErrCloseClosed),
+
+		// Selecting.
+		That(`
+			var a b = (chan:make &size=(num 1)) (chan:make &size=(num 1))
+			chan:send $b foo
+			chan:select $a $b
+		`).Puts(selectResult{Index: 1, Value: "foo", Ok: true}),
+		That("var c = (chan:make); chan:close $c; chan:select (chan:make) $c").
+			Puts(selectResult{Index: 1, Ok: false}),
+		That("chan:select &timeout=0 (chan:make)").
+			Puts(selectResult{Index: -1, TimedOut: true}),
+		That("chan:select &timeout=-1 (chan:make)").Throws(eval.ErrNegativeTimeout),
+		That("chan:select &timeout=foo (chan:make)").Throws(eval.ErrInvalidTimeout),
+	)
+}
+
+func TestChan_SelectTimeoutDuration(t *testing.T) {
+	var durations []time.Duration
+	defer func(saved func(*eval.Frame, time.Duration) <-chan time.Time) {
+		eval.TimeAfter = saved
+	}(eval.TimeAfter)
+	eval.TimeAfter = func(_ *eval.Frame, d time.Duration) <-chan time.Time {
+		durations = append(durations, d)
+		return time.After(0)
+	}
+
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("chan", Ns).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("chan:select &timeout=1.5 (chan:make)").
+			Puts(selectResult{Index: -1, TimedOut: true}),
+		That("chan:select &timeout=2s (chan:make)").
+			Puts(selectResult{Index: -1, TimedOut: true}),
+	)
+	want := []time.Duration{1500 * time.Millisecond, 2 * time.Second}
+	if len(durations) != len(want) || durations[0] != want[0] || durations[1] != want[1] {
+		t.Errorf("got timeouts %v, want %v", durations, want)
+	}
+}
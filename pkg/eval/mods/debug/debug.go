@@ -0,0 +1,112 @@
+// Package debug implements the debug: module.
+package debug
+
+import (
+	"os"
+	"sync"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+)
+
+//elvdoc:var trace
+//
+// Whether to trace the execution of commands, defaulting to `$false` unless
+// Elvish was started with the `-trace` flag. When tracing is enabled, each
+// command is written to the trace file before it is called, after its
+// arguments have been evaluated. Each line of the trace starts with one `+` for
+// each level of function calls, followed by the source location and the
+// command with its arguments and options written as by
+// [`repr`](builtin.html#repr). Example:
+//
+// ```elvish-transcript
+// ~> use debug
+// ~> fn f [x]{ echo $x }
+// ~> set debug:trace = $true
+// ~> f [a b]
+// + [tty 4]:1:1: f [a b]
+// ++ [tty 2]:1:11: echo [a b]
+// [a b]
+// ```
+//
+// Both functions defined in Elvish and external commands are traced; special
+// commands like `if` and `set` are not.
+//
+// @cf trace-file
+
+//elvdoc:var trace-file
+//
+// The file that traces are written to. Defaults to `$nil`, in which case traces
+// are written to the standard error of the command being traced.
+//
+// It can be set to a file object, or a string, which is the path of a file to
+// append the traces to. In the latter case, the file is created if it doesn't
+// exist, and closed when `$debug:trace-file` is set again. Example:
+//
+// ```elvish
+// use debug
+// set debug:trace-file = trace.log
+// set debug:trace = $true
+// ```
+//
+// @cf trace
+
+// Ns makes the debug: namespace for the given Evaler.
+func Ns(ev *eval.Evaler) *eval.Ns {
+	// The trace file opened when $debug:trace-file is set to a path.
+	var opened *os.File
+	var openedMutex sync.Mutex
+	setTraceFile := func(f *os.File, owned bool) {
+		openedMutex.Lock()
+		defer openedMutex.Unlock()
+		ev.SetTraceFile(f)
+		if opened != nil {
+			opened.Close()
+			opened = nil
+		}
+		if owned {
+			opened = f
+		}
+	}
+
+	return eval.NsBuilder{
+		"trace": vars.FromSetGet(
+			func(v interface{}) error {
+				b, ok := v.(bool)
+				if !ok {
+					return errs.BadValue{
+						What: "debug:trace", Valid: "bool", Actual: vals.Kind(v)}
+				}
+				ev.SetTrace(b)
+				return nil
+			},
+			func() interface{} { return ev.Trace() }),
+		"trace-file": vars.FromSetGet(
+			func(v interface{}) error {
+				switch v := v.(type) {
+				case nil:
+					setTraceFile(nil, false)
+				case *os.File:
+					setTraceFile(v, false)
+				case string:
+					f, err := os.OpenFile(v, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+					if err != nil {
+						return err
+					}
+					setTraceFile(f, true)
+				default:
+					return errs.BadValue{What: "debug:trace-file",
+						Valid: "file, string or $nil", Actual: vals.Kind(v)}
+				}
+				return nil
+			},
+			func() interface{} {
+				if f := ev.TraceFile(); f != nil {
+					return f
+				}
+				return nil
+			}),
+	}.Ns()
+}
@@ -0,0 +1,56 @@
+package debug
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestDebug(t *testing.T) {
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("debug", Ns(ev)).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("put $debug:trace").Puts(false),
+		That("set debug:trace = $true; put $debug:trace").Puts(true),
+		That("set debug:trace = foo").Throws(errs.BadValue{
+			What: "debug:trace", Valid: "bool", Actual: "string"}),
+		// Traces are written to stderr by default.
+		That("fn f [x]{ echo $x }", "set debug:trace = $true; f [a 'b c']").
+			Prints("[a 'b c']\n").
+			PrintsStderrWith("+ [test]:2:26: f [a 'b c']\n++ [test]:1:11: echo [a 'b c']\n"),
+		That("set debug:trace = $true; nop &k=v a").
+			PrintsStderrWith("+ [test]:1:26: nop a &k=v\n"),
+		That("put $debug:trace-file").Puts(nil),
+		That("set debug:trace-file = [foo]").Throws(errs.BadValue{
+			What: "debug:trace-file", Valid: "file, string or $nil", Actual: "list"}),
+	)
+}
+
+func TestDebug_TraceFile(t *testing.T) {
+	_, cleanup := testutil.InTestDir()
+	defer cleanup()
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("debug", Ns(ev)).Ns())
+	}
+	TestWithSetup(t, setup,
+		That(`
+			set debug:trace-file = trace.log
+			set debug:trace = $true
+			nop a
+			set debug:trace-file = $nil
+			nop b
+		`).PrintsStderrWith("+ [test]:6:4: nop b\n"),
+	)
+	content, err := ioutil.ReadFile("trace.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "+ [test]:4:4: nop a\n"; string(content) != want {
+		t.Errorf("got trace file %q, want %q", content, want)
+	}
+}
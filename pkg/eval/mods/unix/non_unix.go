@@ -6,6 +6,8 @@
 package unix
 
 import (
+	"os"
+
 	"src.elv.sh/pkg/eval"
 )
 
@@ -16,3 +18,9 @@ const ExposeUnixNs = false
 // Ns is an Elvish namespace that contains variables and functions that deal
 // with features unique to UNIX-like operating systems. On
 var Ns = &eval.Ns{}
+
+// SetSignalStderr does nothing on non-UNIX operating systems.
+func SetSignalStderr(*os.File) {}
+
+// HasSignalHandler always returns false on non-UNIX operating systems.
+func HasSignalHandler(os.Signal) bool { return false }
@@ -0,0 +1,168 @@
+// +build !windows,!plan9,!js
+
+package unix
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+//elvdoc:fn on-signal
+//
+// ```elvish
+// unix:on-signal $signal $handler
+// ```
+//
+// Sets how the Elvish process reacts to `$signal`, which is a signal name with
+// or without the `SIG` prefix, such as `TERM` or `SIGTERM`, or a signal number.
+//
+// If `$handler` is a function, it is called with no arguments each time the
+// signal is received. Handlers run one at a time, separately from the code that
+// is running when the signal arrives. Their value and byte outputs are written
+// to the standard error of the shell, where exceptions thrown by them are also
+// shown.
+//
+// If `$handler` is the string `default`, the default action of the signal is
+// restored; for most signals, including `TERM` and `HUP`, this terminates
+// Elvish. If `$handler` is the string `ignore`, the signal is ignored.
+//
+// Elvish handles some signals itself, such as `HUP` and `USR1`; a handler
+// set with `unix:on-signal` replaces this behavior. The `KILL` and `STOP`
+// signals cannot be handled or ignored. Example:
+//
+// ```elvish
+// use unix
+// var tmp = (mktemp -d)
+// unix:on-signal TERM { rm -r $tmp; exit 1 }
+// ```
+
+// ErrBadSignalHandler is thrown when the handler passed to unix:on-signal is
+// neither a function nor one of the strings "default" and "ignore".
+var ErrBadSignalHandler = errors.New(`signal handler must be a function, "default" or "ignore"`)
+
+var (
+	signalMutex sync.Mutex
+	// Elvish handlers for signals.
+	signalHandlers = map[syscall.Signal]signalHandler{}
+	// Channel on which signals with Elvish handlers are received; created
+	// when the first handler is set.
+	signalCh chan os.Signal
+	// Where the outputs of handlers are written.
+	signalStderr = os.Stderr
+)
+
+type signalHandler struct {
+	ev *eval.Evaler
+	fn eval.Callable
+}
+
+// SetSignalStderr sets the file that the outputs of signal handlers set with
+// unix:on-signal are written to. It defaults to os.Stderr.
+func SetSignalStderr(f *os.File) {
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+	signalStderr = f
+}
+
+// HasSignalHandler returns whether an Elvish handler is set for the signal.
+// Code that listens to signals itself should skip those signals.
+func HasSignalHandler(sig os.Signal) bool {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return false
+	}
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+	_, ok = signalHandlers[s]
+	return ok
+}
+
+func onSignal(fm *eval.Frame, sigArg, handler interface{}) error {
+	sig, err := parseSignal(sigArg)
+	if err != nil {
+		return err
+	}
+	if sig == syscall.SIGKILL || sig == syscall.SIGSTOP {
+		return errs.BadValue{What: "signal",
+			Valid: "signal other than KILL and STOP", Actual: unix.SignalName(sig)}
+	}
+
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+	switch handler := handler.(type) {
+	case eval.Callable:
+		if signalCh == nil {
+			signalCh = make(chan os.Signal, 16)
+			go relaySignals(signalCh)
+		}
+		signalHandlers[sig] = signalHandler{fm.Evaler, handler}
+		signal.Notify(signalCh, sig)
+	case string:
+		switch handler {
+		case "default":
+			delete(signalHandlers, sig)
+			signal.Reset(sig)
+		case "ignore":
+			delete(signalHandlers, sig)
+			signal.Ignore(sig)
+		default:
+			return ErrBadSignalHandler
+		}
+	default:
+		return ErrBadSignalHandler
+	}
+	return nil
+}
+
+// Calls the handlers of signals received on the channel, one at a time.
+func relaySignals(sigCh <-chan os.Signal) {
+	for sig := range sigCh {
+		signalMutex.Lock()
+		h, ok := signalHandlers[sig.(syscall.Signal)]
+		stderr := signalStderr
+		signalMutex.Unlock()
+		if !ok {
+			continue
+		}
+		ports, cleanup := eval.PortsFromFiles(
+			[3]*os.File{eval.DevNull, stderr, stderr}, h.ev.ValuePrefix())
+		err := h.ev.Call(h.fn,
+			eval.CallCfg{From: "[signal handler " + unix.SignalName(sig.(syscall.Signal)) + "]"},
+			eval.EvalCfg{Ports: ports})
+		cleanup()
+		if err != nil {
+			diag.ShowError(stderr, err)
+		}
+	}
+}
+
+// Parses a signal name such as "TERM" or "SIGTERM", or a signal number.
+func parseSignal(v interface{}) (syscall.Signal, error) {
+	s := vals.ToString(v)
+	if n, err := strconv.Atoi(s); err == nil {
+		if unix.SignalName(syscall.Signal(n)) != "" {
+			return syscall.Signal(n), nil
+		}
+	} else {
+		name := strings.ToUpper(s)
+		if !strings.HasPrefix(name, "SIG") {
+			name = "SIG" + name
+		}
+		if sig := unix.SignalNum(name); sig != 0 {
+			return sig, nil
+		}
+	}
+	return 0, errs.BadValue{What: "signal", Valid: "signal name or number", Actual: s}
+}
@@ -0,0 +1,46 @@
+// +build !windows,!plan9,!js
+
+package unix
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+)
+
+func TestOnSignal(t *testing.T) {
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("unix", Ns).
+			AddGoFn("", "raise", func(name string) error {
+				sig, err := parseSignal(name)
+				if err != nil {
+					return err
+				}
+				return syscall.Kill(os.Getpid(), sig)
+			}).Ns())
+	}
+	TestWithSetup(t, setup,
+		// Handlers are called when the signal is received.
+		That(`
+			var got = $false
+			unix:on-signal USR2 { set got = $true }
+			raise USR2
+			while (not $got) { sleep 1ms }
+			put $got
+		`).Puts(true),
+		That(`unix:on-signal 15 { }; unix:on-signal TERM default`).DoesNothing(),
+		// Signals can be ignored.
+		That(`unix:on-signal sigusr2 ignore; raise USR2; sleep 10ms`).DoesNothing(),
+
+		That(`unix:on-signal FOO ignore`).Throws(errs.BadValue{
+			What: "signal", Valid: "signal name or number", Actual: "FOO"}),
+		That(`unix:on-signal KILL ignore`).Throws(errs.BadValue{
+			What: "signal", Valid: "signal other than KILL and STOP", Actual: "SIGKILL"}),
+		That(`unix:on-signal USR2 foo`).Throws(ErrBadSignalHandler),
+		That(`unix:on-signal USR2 [foo]`).Throws(ErrBadSignalHandler),
+	)
+}
@@ -17,4 +17,6 @@ const ExposeUnixNs = true
 // with features unique to UNIX-like operating systems. On
 var Ns = eval.NsBuilder{
 	"umask": UmaskVariable{},
-}.Ns()
+}.AddGoFns("unix:", map[string]interface{}{
+	"on-signal": onSignal,
+}).Ns()
@@ -33,6 +33,11 @@ type Port struct {
 	// is used to check if an external command killed by SIGPIPE is caused by
 	// the termination of the reader of the pipe.
 	readerGone *int32
+
+	// Only populated in ports created by FilePort, where values are written to
+	// File with this prefix. This is used to create ports with the same
+	// behavior for jobs; see ownFilePorts.
+	filePortPrefix *string
 }
 
 // ErrNoValueOutput is thrown when writing to a pipe without a value output
@@ -47,7 +52,7 @@ func init() { close(closedSendStop) }
 
 // Returns a copy of the Port with the Close* flags unset.
 func (p *Port) fork() *Port {
-	return &Port{p.File, p.Chan, false, false, p.sendStop, p.sendError, p.readerGone}
+	return &Port{p.File, p.Chan, false, false, p.sendStop, p.sendError, p.readerGone, p.filePortPrefix}
 }
 
 // Closes a Port.
@@ -234,7 +239,7 @@ func FilePort(f *os.File, valuePrefix string) (*Port, func()) {
 		}
 		close(relayDone)
 	}()
-	return &Port{File: f, Chan: ch}, func() {
+	return &Port{File: f, Chan: ch, filePortPrefix: &valuePrefix}, func() {
 		close(ch)
 		<-relayDone
 	}
@@ -3,6 +3,7 @@
 package eval
 
 import (
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
@@ -24,6 +25,114 @@ func putSelfInFg() error {
 	return sys.Tcsetpgrp(0, syscall.Getpgrp())
 }
 
-func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{Setpgid: bg}
+// Starts a process. If j is not nil, the process is put in the process group
+// of the job, and the process group is put in the foreground of the terminal if
+// the job is in the foreground. The terminal is given back to Elvish when no
+// process of the job is running; see waitProcess.
+func startProcess(j *job, name string, argv []string, files []*os.File) (*os.Process, error) {
+	if j == nil {
+		return os.StartProcess(name, argv, &os.ProcAttr{Files: files})
+	}
+	// Processes of the same job are started one by one, so that they all join
+	// the process group of the first one.
+	j.startMu.Lock()
+	defer j.startMu.Unlock()
+	j.mu.Lock()
+	pgid, fg := j.pgid, !j.bg && sys.IsATTY(os.Stdin)
+	j.mu.Unlock()
+
+	attr := &syscall.SysProcAttr{Setpgid: true, Pgid: pgid, Foreground: fg, Ctty: 0}
+	proc, err := os.StartProcess(name, argv, &os.ProcAttr{Files: files, Sys: attr})
+	if err != nil && pgid != 0 && errors.Is(err, syscall.EPERM) {
+		// The process group no longer exists, because all the existing
+		// processes of the job have exited. Start a new process group.
+		attr.Pgid = 0
+		proc, err = os.StartProcess(name, argv, &os.ProcAttr{Files: files, Sys: attr})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if attr.Pgid == 0 {
+		j.processStarted(proc.Pid)
+	} else {
+		j.processStarted(attr.Pgid)
+	}
+	return proc, nil
+}
+
+// Waits for a process to exit. If j is not nil, the job is notified when the
+// process is stopped or has exited.
+//
+// When a process of a foreground job exits or is stopped and no other process
+// of the job is running, the terminal is given back to Elvish, so that Elvish
+// receives the signals generated by the terminal while it runs code between
+// external commands. Since a process in the foreground receives such signals
+// in place of Elvish, when the process is killed by SIGINT or SIGQUIT, the
+// signal is also sent to Elvish itself.
+func waitProcess(j *job, proc *os.Process) (syscall.WaitStatus, error) {
+	if j == nil {
+		state, err := proc.Wait()
+		if err != nil {
+			return 0, err
+		}
+		return state.Sys().(syscall.WaitStatus), nil
+	}
+	defer proc.Release()
+	for {
+		var ws syscall.WaitStatus
+		_, err := syscall.Wait4(proc.Pid, &ws, syscall.WUNTRACED, nil)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			j.processExited()
+			reclaimTerminal(j)
+			return 0, err
+		}
+		if ws.Stopped() {
+			j.processStopped()
+			reclaimTerminal(j)
+			continue
+		}
+		j.processExited()
+		reclaimTerminal(j)
+		if ws.Signaled() && !j.isBackground() {
+			if sig := ws.Signal(); sig == syscall.SIGINT || sig == syscall.SIGQUIT {
+				syscall.Kill(syscall.Getpid(), sig)
+			}
+		}
+		return ws, nil
+	}
+}
+
+// Puts Elvish back in the foreground of the terminal if j is a foreground job
+// with no running processes.
+func reclaimTerminal(j *job) {
+	// Hold startMu, so that no process of the job can be put in the foreground
+	// concurrently.
+	j.startMu.Lock()
+	defer j.startMu.Unlock()
+	j.mu.Lock()
+	idle := !j.bg && j.pgid != 0 && j.nRunning == 0
+	j.mu.Unlock()
+	if idle {
+		putSelfInFg()
+	}
+}
+
+// Sends SIGCONT to all the processes in a job.
+func continueJob(j *job) error {
+	pgid := j.continued()
+	if pgid == 0 {
+		return nil
+	}
+	return syscall.Kill(-pgid, syscall.SIGCONT)
+}
+
+// Puts a job in the foreground of the terminal.
+func putJobInFg(j *job) error {
+	pgid := j.getPgid()
+	if pgid == 0 || !sys.IsATTY(os.Stdin) {
+		return nil
+	}
+	return sys.Tcsetpgrp(0, pgid)
 }
@@ -1,6 +1,9 @@
 package eval
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 // Nop on Windows.
 func putSelfInFg() error { return nil }
@@ -8,10 +11,21 @@ func putSelfInFg() error { return nil }
 // The bitmask for CreationFlags in SysProcAttr to start a process in background.
 const detachedProcess = 0x00000008
 
-func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
+// Starts a process. Jobs are not supported on Windows; the only effect of j is
+// that processes of background jobs are detached.
+func startProcess(j *job, name string, argv []string, files []*os.File) (*os.Process, error) {
 	flags := uint32(0)
-	if bg {
+	if j != nil && j.isBackground() {
 		flags |= detachedProcess
 	}
-	return &syscall.SysProcAttr{CreationFlags: flags}
+	sys := &syscall.SysProcAttr{CreationFlags: flags}
+	return os.StartProcess(name, argv, &os.ProcAttr{Files: files, Sys: sys})
+}
+
+func waitProcess(j *job, proc *os.Process) (syscall.WaitStatus, error) {
+	state, err := proc.Wait()
+	if err != nil {
+		return syscall.WaitStatus{}, err
+	}
+	return state.Sys().(syscall.WaitStatus), nil
 }
@@ -0,0 +1,209 @@
+package eval
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse/parseutil"
+	"src.elv.sh/pkg/strutil"
+)
+
+// Signature describes the arguments and options of a function, for showing
+// help when calling it.
+type Signature struct {
+	// The arguments, written like in the signature of a lambda, such as "x",
+	// "x:num", "x=default" and "@rest". Functions implemented in Go don't have
+	// argument names, so their arguments are written as their kinds; an
+	// optional argument for inputs is written as "inputs?".
+	Args []string
+	// The index of the rest argument, or -1 if there is none.
+	RestArg int
+	// The options.
+	Opts []Option
+	// The first sentence of the comment before the definition of the
+	// function, or an empty string if there is no such comment. Always empty
+	// for functions implemented in Go.
+	Summary string
+}
+
+// Option describes an option of a function.
+type Option struct {
+	Name string
+	// The kind the option is declared with, like "num", or an empty string if
+	// it accepts values of any kind. Always empty for functions implemented in
+	// Go.
+	Kind string
+	// The default value, in its non-pretty-printed repr.
+	Default string
+}
+
+// String returns the option written like in the signature of a lambda, such as
+// "&name=default" and "&name:kind=default".
+func (o Option) String() string {
+	s := "&" + o.Name
+	if o.Kind != "" {
+		s += ":" + o.Kind
+	}
+	return s + "=" + o.Default
+}
+
+// SignatureOf returns the signature of a function defined in Elvish code or
+// implemented in Go, and false for other callables.
+func SignatureOf(fn Callable) (Signature, bool) {
+	switch fn := fn.(type) {
+	case *closure:
+		return closureSignature(fn), true
+	case *goFn:
+		return goFnSignature(fn), true
+	}
+	return Signature{}, false
+}
+
+// ArgIndex returns the index of the argument that the i-th of n arguments in a
+// call is passed to, or -1 if there is no such argument.
+func (s Signature) ArgIndex(i, n int) int {
+	if s.RestArg == -1 {
+		if i < len(s.Args) {
+			return i
+		}
+		return -1
+	}
+	nAfterRest := len(s.Args) - 1 - s.RestArg
+	switch {
+	case i < s.RestArg:
+		return i
+	case i >= n-nAfterRest && i >= s.RestArg:
+		// Arguments after the rest argument are matched from the end.
+		if j := s.RestArg + 1 + i - (n - nAfterRest); j < len(s.Args) {
+			return j
+		}
+		return -1
+	default:
+		return s.RestArg
+	}
+}
+
+func closureSignature(c *closure) Signature {
+	s := Signature{RestArg: c.RestArg}
+	nRequired := len(c.ArgNames) - len(c.ArgDefaults)
+	for i, name := range c.ArgNames {
+		arg := name
+		if i == c.RestArg {
+			arg = "@" + arg
+		}
+		if kind := c.argKind(i); kind != "" {
+			arg += ":" + kind
+		}
+		if i >= nRequired && c.RestArg == -1 {
+			arg += "=" + vals.Repr(c.ArgDefaults[i-nRequired], vals.NoPretty)
+		}
+		s.Args = append(s.Args, arg)
+	}
+	for i, name := range c.OptNames {
+		opt := Option{Name: name, Default: vals.Repr(c.OptDefaults[i], vals.NoPretty)}
+		if i < len(c.OptKinds) {
+			opt.Kind = c.OptKinds[i]
+		}
+		s.Opts = append(s.Opts, opt)
+	}
+	code := c.SrcMeta.Code
+	if c.DefRange.From <= len(code) {
+		s.Summary = firstSentence(parseutil.CommentBefore(code, c.DefRange.From))
+	}
+	return s
+}
+
+func goFnSignature(b *goFn) Signature {
+	s := Signature{RestArg: -1}
+	for _, t := range b.normalArgs {
+		s.Args = append(s.Args, kindOfType(t))
+	}
+	if b.variadicArg != nil {
+		s.RestArg = len(s.Args)
+		s.Args = append(s.Args, "@"+kindOfType(b.variadicArg))
+	} else if b.inputs {
+		s.Args = append(s.Args, "inputs?")
+	}
+	if b.options != nil {
+		ptr := reflect.New(b.options)
+		ptr.Interface().(optionsPtr).SetDefaultOptions()
+		struc := ptr.Elem()
+		for i := 0; i < b.options.NumField(); i++ {
+			f := b.options.Field(i)
+			if f.PkgPath != "" {
+				continue // ignore unexported fields
+			}
+			name := f.Tag.Get("name")
+			if name == "" {
+				name = strutil.CamelToDashed(f.Name)
+			}
+			s.Opts = append(s.Opts, Option{Name: name, Default: vals.Repr(
+				vals.FromGo(struc.Field(i).Interface()), vals.NoPretty)})
+		}
+	}
+	return s
+}
+
+var (
+	callableType = reflect.TypeOf((*Callable)(nil)).Elem()
+	bigIntType   = reflect.TypeOf((*big.Int)(nil))
+	listType     = reflect.TypeOf((*vals.List)(nil)).Elem()
+	mapType      = reflect.TypeOf((*vals.Map)(nil)).Elem()
+)
+
+// Returns the kind of values that arguments of a Go function with the given
+// type accept.
+func kindOfType(t reflect.Type) string {
+	switch t {
+	case callableType:
+		return "fn"
+	case bigIntType:
+		return "number"
+	case listType:
+		return "list"
+	case mapType:
+		return "map"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return "value"
+}
+
+// Returns the first sentence of the first paragraph of a comment, skipping
+// code blocks.
+func firstSentence(comment string) string {
+	var words []string
+	inCode := false
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "```"):
+			inCode = !inCode
+			continue
+		case inCode:
+			continue
+		case line == "":
+			if len(words) > 0 {
+				return strings.Join(words, " ")
+			}
+			continue
+		}
+		for _, word := range strings.Fields(line) {
+			words = append(words, word)
+			if strings.HasSuffix(word, ".") {
+				return strings.Join(words, " ")
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
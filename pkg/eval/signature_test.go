@@ -0,0 +1,115 @@
+package eval
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/parse"
+)
+
+func TestSignatureOf_Closure(t *testing.T) {
+	ev := NewEvaler()
+	code := "# Does things.\n# Really.\n" +
+		"fn f [a b:num @rest c &opt=foo &k:string=bar &n:num=(num 1)]{ }\n" +
+		"fn g [a b=(num 1)]{ }\n"
+	err := ev.Eval(parse.Source{Name: "[test]", Code: code}, EvalCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want Signature
+	}{
+		{"f~", Signature{
+			Args:    []string{"a", "b:num", "@rest", "c"},
+			RestArg: 2,
+			Opts:    []Option{{"opt", "", "foo"}, {"k", "string", "bar"}, {"n", "num", "(num 1)"}},
+			Summary: "Does things.",
+		}},
+		{"g~", Signature{
+			Args:    []string{"a", "b=(num 1)"},
+			RestArg: -1,
+		}},
+	}
+	for _, test := range tests {
+		fn := ev.Global().IndexName(test.name).Get().(Callable)
+		sig, ok := SignatureOf(fn)
+		if !ok || !reflect.DeepEqual(sig, test.want) {
+			t.Errorf("SignatureOf($%s) -> %#v, %v, want %#v, true", test.name, sig, ok, test.want)
+		}
+	}
+}
+
+func TestSignatureOf_GoFn(t *testing.T) {
+	fn := NewGoFn("f", func(opts testOptions, s string, n int, f Callable, more ...interface{}) {})
+	want := Signature{
+		Args:    []string{"string", "number", "fn", "@value"},
+		RestArg: 3,
+		Opts:    []Option{{"foo", "", "''"}, {"bar", "", "default"}},
+	}
+	if sig, ok := SignatureOf(fn); !ok || !reflect.DeepEqual(sig, want) {
+		t.Errorf("got %#v, %v, want %#v, true", sig, ok, want)
+	}
+
+	fn = NewGoFn("g", func(x interface{}, inputs Inputs) {})
+	want = Signature{Args: []string{"value", "inputs?"}, RestArg: -1}
+	if sig, ok := SignatureOf(fn); !ok || !reflect.DeepEqual(sig, want) {
+		t.Errorf("got %#v, %v, want %#v, true", sig, ok, want)
+	}
+}
+
+func TestOption_String(t *testing.T) {
+	tests := []struct {
+		opt  Option
+		want string
+	}{
+		{Option{"foo", "", "bar"}, "&foo=bar"},
+		{Option{"foo", "string", "bar"}, "&foo:string=bar"},
+		{Option{"foo", "num", "(num 1)"}, "&foo:num=(num 1)"},
+	}
+	for _, test := range tests {
+		if got := test.opt.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.opt, got, test.want)
+		}
+	}
+}
+
+func TestSignature_ArgIndex(t *testing.T) {
+	noRest := Signature{Args: []string{"a", "b"}, RestArg: -1}
+	rest := Signature{Args: []string{"a", "@b", "c"}, RestArg: 1}
+	tests := []struct {
+		sig  Signature
+		i, n int
+		want int
+	}{
+		{noRest, 0, 2, 0},
+		{noRest, 1, 2, 1},
+		{noRest, 2, 3, -1},
+		{rest, 0, 4, 0},
+		{rest, 1, 4, 1},
+		{rest, 2, 4, 1},
+		{rest, 3, 4, 2},
+		{rest, 1, 2, 2},
+	}
+	for _, test := range tests {
+		if got := test.sig.ArgIndex(test.i, test.n); got != test.want {
+			t.Errorf("ArgIndex(%v, %v) of %v = %v, want %v",
+				test.i, test.n, test.sig.Args, got, test.want)
+		}
+	}
+}
+
+func TestFirstSentence(t *testing.T) {
+	tests := []struct{ comment, want string }{
+		{"", ""},
+		{"Does things. And more.", "Does things."},
+		{"Does\nthings\n\nMore.", "Does things"},
+		{"```elvish\nf $x\n```\n\nDoes things.", "Does things."},
+	}
+	for _, test := range tests {
+		if got := firstSentence(test.comment); got != test.want {
+			t.Errorf("firstSentence(%q) = %q, want %q", test.comment, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Tracing of commands, like "set -x" in POSIX shells.
+
+// SetTrace enables or disables tracing. When tracing is enabled, each ordinary
+// command is written to the trace file before it is called, along with its
+// source location and the depth of the call stack.
+func (ev *Evaler) SetTrace(enabled bool) {
+	var i int32
+	if enabled {
+		i = 1
+	}
+	atomic.StoreInt32(&ev.trace, i)
+}
+
+// Trace returns whether tracing is enabled.
+func (ev *Evaler) Trace() bool {
+	return atomic.LoadInt32(&ev.trace) != 0
+}
+
+// SetTraceFile sets the file that traces are written to. If f is nil, traces
+// are written to the standard error of the code being traced.
+func (ev *Evaler) SetTraceFile(f *os.File) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.traceFile = f
+}
+
+// TraceFile returns the file that traces are written to, or nil if traces are
+// written to the standard error of the code being traced.
+func (ev *Evaler) TraceFile() *os.File {
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	return ev.traceFile
+}
+
+// Writes a trace of calling a command. The head is the command as written in
+// the code, and r is the range of the form calling it.
+func (fm *Frame) trace(r diag.Ranger, head string, args []interface{}, opts map[string]interface{}) {
+	depth := 0
+	for tb := fm.traceback; tb != nil; tb = tb.Next {
+		if tb.Callee != "" {
+			depth++
+		}
+	}
+	line, col := diag.NewContext(fm.srcMeta.Name, fm.srcMeta.Code, r).Position()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s:%d:%d: %s",
+		strings.Repeat("+", depth), fm.srcMeta.Name, line, col, head)
+	for _, arg := range args {
+		sb.WriteString(" " + vals.Repr(arg, vals.NoPretty))
+	}
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(" &" + k + "=" + vals.Repr(opts[k], vals.NoPretty))
+	}
+	sb.WriteString("\n")
+
+	w := fm.Evaler.TraceFile()
+	if w == nil {
+		w = fm.ErrorFile()
+		if w == nil {
+			return
+		}
+	}
+	// Serialize the writes of traces from concurrent pipelines.
+	fm.Evaler.traceMutex.Lock()
+	defer fm.Evaler.traceMutex.Unlock()
+	w.WriteString(sb.String())
+}
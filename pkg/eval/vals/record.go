@@ -0,0 +1,142 @@
+package vals
+
+import (
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// RecordType is a named type of records, declared by Elvish code.
+type RecordType struct {
+	Name string
+	// Names of the fields, in the order they appear in the representation of
+	// records.
+	Fields []string
+	// Index of each field in Fields.
+	index map[string]int
+}
+
+// NewRecordType creates a new record type with the given name and field
+// names.
+func NewRecordType(name string, fields []string) *RecordType {
+	index := make(map[string]int, len(fields))
+	for i, field := range fields {
+		index[field] = i
+	}
+	return &RecordType{name, fields, index}
+}
+
+// HasField returns whether the record type has a field with the given name.
+func (t *RecordType) HasField(name string) bool {
+	_, ok := t.index[name]
+	return ok
+}
+
+// New creates a record of the type with the given values, which must be in the
+// same order as the fields.
+func (t *RecordType) New(values []interface{}) Record {
+	return Record{t, values}
+}
+
+// Record is a value of a RecordType. Like a struct map, it behaves like a map
+// with a fixed set of keys; unlike a struct map, its fields can be changed with
+// Assoc, which creates a new record of the same type.
+type Record struct {
+	Type   *RecordType
+	values []interface{}
+}
+
+var (
+	_ Kinder       = Record{}
+	_ Equaler      = Record{}
+	_ Hasher       = Record{}
+	_ Reprer       = Record{}
+	_ Lener        = Record{}
+	_ ErrIndexer   = Record{}
+	_ HasKeyer     = Record{}
+	_ KeysIterator = Record{}
+	_ Assocer      = Record{}
+)
+
+// Kind returns the name of the record type.
+func (r Record) Kind() string { return r.Type.Name }
+
+// Equal returns whether the other value is a record of the same type, with
+// equal values for all the fields.
+func (r Record) Equal(other interface{}) bool {
+	r2, ok := other.(Record)
+	if !ok || r.Type != r2.Type {
+		return false
+	}
+	for i, v := range r.values {
+		if !Equal(v, r2.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash computes the hash from the values of the fields.
+func (r Record) Hash() uint32 {
+	h := hash.DJBInit
+	for _, v := range r.values {
+		h = hash.DJBCombine(h, Hash(v))
+	}
+	return h
+}
+
+// Repr returns the representation of the record as a map.
+func (r Record) Repr(indent int) string {
+	builder := NewMapReprBuilder(indent)
+	for i, field := range r.Type.Fields {
+		builder.WritePair(Repr(field, indent+1), indent+2, Repr(r.values[i], indent+2))
+	}
+	return builder.String()
+}
+
+// Len returns the number of fields.
+func (r Record) Len() int { return len(r.values) }
+
+// Index returns the value of a field.
+func (r Record) Index(k interface{}) (interface{}, error) {
+	i, ok := r.fieldIndex(k)
+	if !ok {
+		return nil, NoSuchKey(k)
+	}
+	return r.values[i], nil
+}
+
+// HasKey returns whether the argument is the name of a field.
+func (r Record) HasKey(k interface{}) bool {
+	_, ok := r.fieldIndex(k)
+	return ok
+}
+
+// IterateKeys calls the function with the names of the fields.
+func (r Record) IterateKeys(f func(interface{}) bool) {
+	for _, field := range r.Type.Fields {
+		if !f(field) {
+			break
+		}
+	}
+}
+
+// Assoc returns a record of the same type with a field changed. It is an error
+// if k is not the name of a field.
+func (r Record) Assoc(k, v interface{}) (interface{}, error) {
+	i, ok := r.fieldIndex(k)
+	if !ok {
+		return nil, NoSuchKey(k)
+	}
+	values := make([]interface{}, len(r.values))
+	copy(values, r.values)
+	values[i] = v
+	return Record{r.Type, values}, nil
+}
+
+func (r Record) fieldIndex(k interface{}) (int, bool) {
+	field, ok := k.(string)
+	if !ok {
+		return 0, false
+	}
+	i, ok := r.Type.index[field]
+	return i, ok
+}
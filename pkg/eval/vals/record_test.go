@@ -0,0 +1,44 @@
+package vals
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/persistent/hash"
+	"src.elv.sh/pkg/tt"
+)
+
+func TestRecord(t *testing.T) {
+	point := NewRecordType("point", []string{"x", "y"})
+	// Structurally identical to point.
+	point2 := NewRecordType("point", []string{"x", "y"})
+
+	TestValue(t, point.New([]interface{}{"1", "2"})).
+		Kind("point").
+		Bool(true).
+		Hash(hash.DJB(Hash("1"), Hash("2"))).
+		Repr(`[&x=1 &y=2]`).
+		Len(2).
+		Equal(point.New([]interface{}{"1", "2"})).
+		NotEqual(
+			"a", MakeMap("x", "1", "y", "2"), point.New([]interface{}{"1", "3"}),
+			point2.New([]interface{}{"1", "2"})).
+		HasKey("x", "y").
+		HasNoKey("z", 1.0).
+		IndexError("z", NoSuchKey("z")).
+		AllKeys("x", "y").
+		Index("x", "1").
+		Index("y", "2")
+}
+
+func TestRecord_Assoc(t *testing.T) {
+	point := NewRecordType("point", []string{"x", "y"})
+	r := point.New([]interface{}{"1", "2"})
+
+	tt.Test(t, tt.Fn("Assoc", Assoc), tt.Table{
+		tt.Args(r, "x", "3").Rets(point.New([]interface{}{"3", "2"}), nil),
+		tt.Args(r, "z", "3").Rets(nil, NoSuchKey("z")),
+	})
+	if r.values[0] != "1" {
+		t.Errorf("Assoc modified the original record")
+	}
+}
@@ -0,0 +1,452 @@
+// Package format implements a formatter for Elvish code.
+//
+// The formatter works on the parse tree and keeps all the comments. It
+// normalizes the indentation of lambdas and output captures, the whitespace
+// between the parts of forms, pipelines and lists, and the spacing of
+// redirections; it keeps line breaks inside lists and line continuations, and
+// wraps forms that are longer than 80 columns.
+package format
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/wcwidth"
+)
+
+const (
+	// Forms whose lines are longer than this are wrapped with line
+	// continuations when possible.
+	maxWidth = 80
+	// Unit of indentation.
+	indentUnit = "  "
+)
+
+var errChangesCode = errors.New("internal error: formatting would change the code")
+
+// Source formats Elvish code. The name is used in parse errors.
+func Source(name, code string) (string, error) {
+	tree, err := parse.Parse(parse.Source{Name: name, Code: code}, parse.Config{})
+	if err != nil {
+		return "", err
+	}
+	p := &printer{}
+	p.top(tree.Root)
+	formatted := p.sb.String()
+
+	// As a safeguard, make sure that the formatted code still parses and keeps
+	// all the comments.
+	newTree, err := parse.Parse(parse.Source{Name: name, Code: formatted}, parse.Config{})
+	if err != nil || !reflect.DeepEqual(comments(tree.Root), comments(newTree.Root)) {
+		return "", errChangesCode
+	}
+	return formatted, nil
+}
+
+type printer struct {
+	sb strings.Builder
+	// Current level of indentation.
+	indent int
+	// Width of the current line written so far.
+	col int
+	// Whether the printer is at the beginning of a line. The indentation is
+	// only written before the first thing on a line, so that empty lines have
+	// no trailing whitespace.
+	bol bool
+	// Whether the printer is only measuring the width of the first line of a
+	// node; see measure.
+	measuring bool
+}
+
+// Sentinel value panicked with by a measuring printer when it has reached the
+// end of the first line or maxWidth.
+type measureDone struct{}
+
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	if p.bol {
+		p.bol = false
+		p.write(strings.Repeat(indentUnit, p.indent))
+	}
+	if p.measuring {
+		if i := strings.IndexByte(s, '\n'); i != -1 {
+			p.col += wcwidth.Of(s[:i])
+			panic(measureDone{})
+		}
+		if p.col += wcwidth.Of(s); p.col > maxWidth {
+			panic(measureDone{})
+		}
+		return
+	}
+	p.sb.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i != -1 {
+		p.col = wcwidth.Of(s[i+1:])
+	} else {
+		p.col += wcwidth.Of(s)
+	}
+}
+
+// Starts a new line, optionally preceded by a blank line. It does nothing at
+// the beginning of the output or of a line.
+func (p *printer) newline(blank bool) {
+	if p.measuring {
+		panic(measureDone{})
+	}
+	if p.bol || p.sb.Len() == 0 {
+		return
+	}
+	p.sb.WriteByte('\n')
+	if blank {
+		p.sb.WriteByte('\n')
+	}
+	p.col = 0
+	p.bol = true
+}
+
+func (p *printer) comment(text string) {
+	if !p.bol && p.sb.Len() > 0 {
+		p.write(" ")
+	}
+	p.write(text)
+}
+
+// Returns the width that the first line of n would take if it were written
+// at the current column after a space.
+func (p *printer) measure(n parse.Node) (w int) {
+	m := &printer{indent: p.indent, col: p.col + 1, measuring: true}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(measureDone); !ok {
+				panic(r)
+			}
+			w = m.col - p.col - 1
+		}
+	}()
+	m.node(n)
+	return m.col - p.col - 1
+}
+
+// A gap between two non-Sep children of a node, made up of whitespace,
+// comments, line continuations and punctuation. Only the comments and line
+// breaks are kept; the punctuation is implied by the type of the node.
+type gap struct {
+	comments []comment
+	// Number of newlines after the last comment, or in the whole gap if there
+	// are no comments.
+	newlines int
+	// Whether the gap contains a line continuation.
+	continuation bool
+}
+
+type comment struct {
+	text string
+	// Number of newlines before the comment in the gap.
+	newlines int
+}
+
+func (g *gap) scan(s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			g.newlines++
+		case '^':
+			g.continuation = true
+		case '#':
+			j := strings.IndexAny(s[i:], "\r\n")
+			if j == -1 {
+				j = len(s) - i
+			}
+			g.comments = append(g.comments,
+				comment{strings.TrimRight(s[i:i+j], " \t"), g.newlines})
+			g.newlines = 0
+			i += j - 1
+		}
+	}
+}
+
+// Splits the children of n into the non-Sep ones and the gaps around them.
+// The i-th gap is the one before the i-th item; the last gap is after all the
+// items.
+func split(n parse.Node) ([]parse.Node, []gap) {
+	var items []parse.Node
+	gaps := []gap{{}}
+	for _, ch := range parse.Children(n) {
+		if sep, ok := ch.(*parse.Sep); ok {
+			gaps[len(gaps)-1].scan(parse.SourceText(sep))
+		} else {
+			items = append(items, ch)
+			gaps = append(gaps, gap{})
+		}
+	}
+	return items, gaps
+}
+
+// Writes the comments in a gap. If the gap contains any comment or line
+// break, it also starts a new line, unless it is at the end of a construct,
+// and returns true. Blank lines at the start of a construct are dropped, and
+// runs of blank lines elsewhere are collapsed into one.
+func (p *printer) gap(g gap, start, end bool) bool {
+	for _, c := range g.comments {
+		if c.newlines > 0 {
+			p.newline(c.newlines > 1 && !start)
+		}
+		p.comment(c.text)
+		start = false
+	}
+	if len(g.comments) == 0 && g.newlines == 0 {
+		return false
+	}
+	if !end {
+		p.newline(g.newlines > 1 && !start)
+	}
+	return true
+}
+
+// Writes the top-level chunk.
+func (p *printer) top(n *parse.Chunk) {
+	pipelines, gaps := split(n)
+	p.lines(pipelines, gaps)
+	if p.sb.Len() > 0 {
+		p.newline(false)
+	}
+}
+
+// Writes the pipelines of a chunk, each starting on a new line unless they
+// are separated by semicolons in the source.
+func (p *printer) lines(pipelines []parse.Node, gaps []gap) {
+	for i, pn := range pipelines {
+		if !p.gap(gaps[i], i == 0, false) && i > 0 {
+			p.write("; ")
+		}
+		p.node(pn)
+	}
+	p.gap(gaps[len(pipelines)], len(pipelines) == 0, true)
+}
+
+// Writes a chunk between the open and close delimiters. If the chunk contains
+// line breaks or comments, the delimiters are put on their own lines and the
+// chunk is indented; otherwise the chunk is kept on the same line, with
+// padding spaces when pad is true.
+func (p *printer) body(n *parse.Chunk, open, close string, pad bool) {
+	pipelines, gaps := split(n)
+	multiline := false
+	for _, g := range gaps {
+		if len(g.comments) > 0 || (g.newlines > 0 && len(pipelines) > 0) {
+			multiline = true
+		}
+	}
+	p.write(open)
+	if multiline {
+		p.indent++
+		if len(pipelines) > 0 && len(gaps[0].comments) == 0 {
+			p.newline(false)
+		}
+		p.lines(pipelines, gaps)
+		p.indent--
+		p.newline(false)
+	} else {
+		if pad {
+			p.write(" ")
+		}
+		for i, pn := range pipelines {
+			if i > 0 {
+				p.write("; ")
+			}
+			p.node(pn)
+		}
+		if pad && len(pipelines) > 0 {
+			p.write(" ")
+		}
+	}
+	p.write(close)
+}
+
+// Writes items separated by spaces between the open and close delimiters,
+// keeping the line breaks between them. Items on lines of their own are
+// indented.
+func (p *printer) seq(items []parse.Node, gaps []gap, open, close string) {
+	p.write(open)
+	p.indent++
+	for i, item := range items {
+		if !p.gap(gaps[i], i == 0, false) && i > 0 {
+			p.write(" ")
+		}
+		p.node(item)
+	}
+	broke := p.gap(gaps[len(items)], len(items) == 0, true)
+	p.indent--
+	if broke {
+		p.newline(false)
+	}
+	p.write(close)
+}
+
+var redirSigns = map[parse.RedirMode]string{
+	parse.Read: "<", parse.Write: ">", parse.ReadWrite: "<>", parse.Append: ">>",
+}
+
+func (p *printer) node(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.Pipeline:
+		p.pipeline(n)
+	case *parse.Form:
+		p.form(n)
+	case *parse.Assignment:
+		p.node(n.Left)
+		p.write("=")
+		p.node(n.Right)
+	case *parse.Redir:
+		if n.Left != nil {
+			p.node(n.Left)
+		}
+		p.write(redirSigns[n.Mode])
+		if n.RightIsFd {
+			p.write("&")
+		} else {
+			p.write(" ")
+		}
+		p.node(n.Right)
+	case *parse.Compound:
+		for _, in := range n.Indexings {
+			p.node(in)
+		}
+	case *parse.Indexing:
+		p.node(n.Head)
+		for _, a := range n.Indicies {
+			p.write("[")
+			p.node(a)
+			p.write("]")
+		}
+	case *parse.Array:
+		items, gaps := split(n)
+		p.seq(items, gaps, "", "")
+	case *parse.MapPair:
+		p.write("&")
+		p.node(n.Key)
+		if n.Value != nil {
+			p.write("=")
+			p.node(n.Value)
+		}
+	case *parse.Primary:
+		p.primary(n)
+	}
+}
+
+func (p *printer) pipeline(n *parse.Pipeline) {
+	forms, gaps := split(n)
+	p.gap(gaps[0], true, true)
+	indented := false
+	for i, form := range forms {
+		if i > 0 {
+			p.write(" |")
+			if p.gap(gaps[i], true, false) {
+				if !indented {
+					p.indent++
+					indented = true
+				}
+			} else {
+				p.write(" ")
+			}
+		}
+		p.node(form)
+	}
+	if n.Background {
+		p.write(" &")
+	}
+	p.gap(gaps[len(forms)], false, true)
+	if indented {
+		p.indent--
+	}
+}
+
+// Writes a form, keeping its line continuations and adding new ones before
+// arguments that would go beyond maxWidth.
+func (p *printer) form(n *parse.Form) {
+	items, gaps := split(n)
+	p.gap(gaps[0], true, true)
+	indented := false
+	for i, item := range items {
+		if i > 0 {
+			// Leave room for " ^" unless this is the last item.
+			limit := maxWidth
+			if i < len(items)-1 {
+				limit -= 2
+			}
+			if gaps[i].continuation || p.col+1+p.measure(item) > limit {
+				p.write(" ^")
+				p.newline(false)
+				if !indented {
+					p.indent++
+					indented = true
+				}
+			} else {
+				p.write(" ")
+			}
+		}
+		p.node(item)
+	}
+	p.gap(gaps[len(items)], false, true)
+	if indented {
+		p.indent--
+	}
+}
+
+func (p *printer) primary(n *parse.Primary) {
+	switch n.Type {
+	case parse.OutputCapture:
+		p.body(n.Chunk, "(", ")", false)
+	case parse.ExceptionCapture:
+		p.body(n.Chunk, "?(", ")", false)
+	case parse.List:
+		items, gaps := split(n)
+		p.seq(items, gaps, "[", "]")
+	case parse.Map:
+		items, gaps := split(n)
+		if len(items) == 0 {
+			p.seq(items, gaps, "[&", "]")
+		} else {
+			p.seq(items, gaps, "[", "]")
+		}
+	case parse.Lambda:
+		if strings.HasPrefix(parse.SourceText(n), "[") {
+			// The last item is the body.
+			items, gaps := split(n)
+			k := len(items) - 1
+			p.seq(items[:k], gaps[:k+1], "[", "]")
+		}
+		p.body(n.Chunk, "{", "}", true)
+	case parse.Braced:
+		p.write("{")
+		for i, elem := range n.Braced {
+			if i > 0 {
+				p.write(",")
+			}
+			p.node(elem)
+		}
+		p.write("}")
+	default:
+		p.write(parse.SourceText(n))
+	}
+}
+
+// Returns all the comments in a parse tree.
+func comments(n parse.Node) []string {
+	if sep, ok := n.(*parse.Sep); ok {
+		var g gap
+		g.scan(parse.SourceText(sep))
+		var texts []string
+		for _, c := range g.comments {
+			texts = append(texts, c.text)
+		}
+		return texts
+	}
+	var texts []string
+	for _, ch := range parse.Children(n) {
+		texts = append(texts, comments(ch)...)
+	}
+	return texts
+}
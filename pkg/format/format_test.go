@@ -0,0 +1,95 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+var sourceTests = []struct {
+	name string
+	code string
+	want string
+}{
+	{name: "empty", code: "", want: ""},
+	{name: "blank lines only", code: "\n\n", want: ""},
+	{name: "spaces in form", code: "echo  foo   bar", want: "echo foo bar\n"},
+	{name: "semicolons",
+		code: "echo foo;echo bar ;  echo lorem", want: "echo foo; echo bar; echo lorem\n"},
+	{name: "blank lines",
+		code: "\n\necho foo\n\n\n\necho bar\n\n", want: "echo foo\n\necho bar\n"},
+	{name: "pipeline", code: "a|b  |   c &", want: "a | b | c &\n"},
+	{name: "pipeline with line breaks",
+		code: "a |\nb |\n\n   c", want: "a |\n  b |\n  c\n"},
+	{name: "redirections",
+		code: "echo >out  2>&1 <  in >>log", want: "echo > out 2>&1 < in >> log\n"},
+	{name: "temporary assignment", code: "a=foo  b=bar  echo", want: "a=foo b=bar echo\n"},
+	{name: "options and map pairs",
+		code: "f &a=b   &c=  &d [&x=y  &z]", want: "f &a=b &c= &d [&x=y &z]\n"},
+	{name: "lists and indexing",
+		code: "echo [ a  b ] [&] [] $x[ 0  1 ][2] {a,b}c ~/foo",
+		want: "echo [a b] [&] [] $x[0 1][2] {a,b}c ~/foo\n"},
+	{name: "list with line breaks",
+		code: "var l = [\na b\n      c\n\n\nd\n]",
+		want: "var l = [\n  a b\n  c\n\n  d\n]\n"},
+	{name: "captures",
+		code: "echo ( put a ) ?( fail x ) (put a;put b)",
+		want: "echo (put a) ?(fail x) (put a; put b)\n"},
+	{name: "inline lambdas",
+		code: "each [x  &y=z]{put $x} {  } []{echo;echo}",
+		want: "each [x &y=z]{ put $x } { } []{ echo; echo }\n"},
+	{name: "multi-line lambda",
+		code: "fn f [x]{\necho $x\n      put $x }",
+		want: "fn f [x]{\n  echo $x\n  put $x\n}\n"},
+	{name: "nested blocks",
+		code: "if $a {\nif $b {\necho\n}\n} elif $c {\n        echo c } else {\n  try {\n  fail\n  } except e {\n  }\n}",
+		want: "if $a {\n  if $b {\n    echo\n  }\n} elif $c {\n  echo c\n} else {\n  try {\n    fail\n  } except e { }\n}\n"},
+	{name: "multi-line output capture",
+		code: "var x = (\nput a\nput b)", want: "var x = (\n  put a\n  put b\n)\n"},
+	{name: "comments",
+		code: "   # a\n#b   \n\n\n\necho # c\nfn f []{ # d\n  # e\n\n  echo\n  # f\n}\n# g",
+		want: "# a\n#b\n\necho # c\nfn f []{ # d\n  # e\n\n  echo\n  # f\n}\n# g\n"},
+	{name: "comments in lists",
+		code: "var l = [ # a\na # b\n# c\nb]",
+		want: "var l = [ # a\n  a # b\n  # c\n  b]\n"},
+	{name: "comment after pipe",
+		code: "a | # comment\nb", want: "a | # comment\n  b\n"},
+	{name: "line continuation",
+		code: "echo a ^\nb  ^\n    c", want: "echo a ^\n  b ^\n  c\n"},
+	{name: "long form",
+		code: "echo " + strings.Repeat("foo ", 30),
+		want: "echo " + strings.Repeat("foo ", 18) + "^\n  " +
+			strings.Repeat("foo ", 11) + "foo\n"},
+	{name: "long form in block",
+		code: "{\n" + strings.Repeat("x", 75) + " y z\n}",
+		want: "{\n  " + strings.Repeat("x", 75) + " ^\n    y z\n}\n"},
+	{name: "long form with multi-line lambda",
+		code: "if " + strings.Repeat("x", 74) + " {\necho\n}",
+		want: "if " + strings.Repeat("x", 74) + " {\n  echo\n}\n"},
+	{name: "strings are kept",
+		code: "echo 'a  b\n  c' \"d\\n\"", want: "echo 'a  b\n  c' \"d\\n\"\n"},
+}
+
+func TestSource(t *testing.T) {
+	for _, test := range sourceTests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Source("[test]", test.code)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+			again, err := Source("[test]", got)
+			if err != nil || again != got {
+				t.Errorf("formatting again got %q, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestSource_ParseError(t *testing.T) {
+	_, err := Source("[test]", "echo (")
+	if err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Errorf("got error %v, want parse error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package format
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"src.elv.sh/pkg/prog"
+)
+
+// Program is the formatter subprogram.
+var Program prog.Program = program{}
+
+type program struct{}
+
+func (program) ShouldRun(f *prog.Flags) bool { return f.Fmt }
+
+func (program) Run(fds [3]*os.File, f *prog.Flags, args []string) error {
+	if f.CodeInArg {
+		return prog.BadUsage("-c cannot be used together with -fmt")
+	}
+	if f.FmtWrite && f.FmtCheck {
+		return prog.BadUsage("-w and -check cannot be used together")
+	}
+	if len(args) == 0 {
+		if f.FmtWrite {
+			return prog.BadUsage("-w requires files to format")
+		}
+		code, err := ioutil.ReadAll(fds[0])
+		if err != nil {
+			return err
+		}
+		unformatted, err := run(fds, f, "[stdin]", string(code))
+		if err != nil {
+			return err
+		}
+		if unformatted {
+			return prog.Exit(1)
+		}
+		return nil
+	}
+
+	// Keep going after errors, and exit with 2 if there are any; otherwise
+	// exit with 1 if some file is not formatted in check mode.
+	exit := 0
+	for _, name := range args {
+		code, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(fds[2], err)
+			exit = 2
+			continue
+		}
+		unformatted, err := run(fds, f, name, string(code))
+		if err != nil {
+			fmt.Fprintln(fds[2], err)
+			exit = 2
+		} else if unformatted && exit == 0 {
+			exit = 1
+		}
+	}
+	return prog.Exit(exit)
+}
+
+// Formats one file or the standard input, and returns whether it is not
+// formatted in check mode.
+func run(fds [3]*os.File, f *prog.Flags, name, code string) (bool, error) {
+	formatted, err := Source(name, code)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case f.FmtCheck:
+		if formatted != code {
+			fmt.Fprintln(fds[1], name)
+			return true, nil
+		}
+	case f.FmtWrite:
+		if formatted != code {
+			return false, ioutil.WriteFile(name, []byte(formatted), 0644)
+		}
+	default:
+		fds[1].WriteString(formatted)
+	}
+	return false, nil
+}
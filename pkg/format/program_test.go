@@ -0,0 +1,93 @@
+package format
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"src.elv.sh/pkg/prog"
+	. "src.elv.sh/pkg/prog/progtest"
+)
+
+func TestProgram_Stdin(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+	f.FeedIn("echo  foo|each [x]{put $x}")
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt"), Program)
+
+	if exit != 0 {
+		t.Errorf("got exit %v, want 0", exit)
+	}
+	f.TestOut(t, 1, "echo foo | each [x]{ put $x }\n")
+	f.TestOut(t, 2, "")
+}
+
+func TestProgram_Files(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+	MustWriteFile("a.elv", "echo  a")
+	MustWriteFile("b.elv", "echo  b")
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt", "a.elv", "b.elv"), Program)
+
+	if exit != 0 {
+		t.Errorf("got exit %v, want 0", exit)
+	}
+	f.TestOut(t, 1, "echo a\necho b\n")
+	f.TestOut(t, 2, "")
+}
+
+func TestProgram_Write(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+	MustWriteFile("a.elv", "echo  a")
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt", "-w", "a.elv"), Program)
+
+	if exit != 0 {
+		t.Errorf("got exit %v, want 0", exit)
+	}
+	f.TestOut(t, 1, "")
+	f.TestOut(t, 2, "")
+	if content, _ := ioutil.ReadFile("a.elv"); string(content) != "echo a\n" {
+		t.Errorf("got a.elv %q, want %q", content, "echo a\n")
+	}
+}
+
+func TestProgram_Check(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+	MustWriteFile("good.elv", "echo good\n")
+	MustWriteFile("bad.elv", "echo  bad")
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt", "-check", "good.elv", "bad.elv"), Program)
+
+	if exit != 1 {
+		t.Errorf("got exit %v, want 1", exit)
+	}
+	f.TestOut(t, 1, "bad.elv\n")
+	f.TestOut(t, 2, "")
+	if content, _ := ioutil.ReadFile("bad.elv"); string(content) != "echo  bad" {
+		t.Errorf("-check modified bad.elv to %q", content)
+	}
+}
+
+func TestProgram_Errors(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+	MustWriteFile("bad.elv", "echo (")
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt", "bad.elv", "nonexistent.elv"), Program)
+
+	TestError(t, f, exit, "parse error")
+	f.TestOutSnippet(t, 2, "nonexistent.elv")
+}
+
+func TestProgram_BadUsage(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+
+	exit := prog.Run(f.Fds(), Elvish("-fmt", "-w"), Program)
+
+	TestError(t, f, exit, "-w requires files to format")
+}
@@ -0,0 +1,321 @@
+package lsp
+
+// Static analysis of Elvish code, working on the parse tree.
+
+import (
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+	"src.elv.sh/pkg/parse/parseutil"
+)
+
+// A definition of a function, variable or module in a source file.
+type definition struct {
+	kind SymbolKind
+	// Name of the function, variable or module, without any sigil, the ~
+	// suffix of functions or the : suffix of modules.
+	name string
+	// Range of the name and of the whole definition.
+	nameRange, fullRange diag.Ranging
+	// The part of the source the definition is visible in.
+	scope diag.Ranging
+	// Whether the definition is a parameter of a function. Parameters are not
+	// reported as document symbols.
+	param bool
+	// For functions, the source of the definition without the body, like
+	// "fn f [a b]".
+	signature string
+	// The comment lines immediately before the definition, with the leading #
+	// and one space removed.
+	doc string
+	// For modules, the spec given to use.
+	spec string
+	// Definitions in the body of a function.
+	children []*definition
+}
+
+// Collects all the definitions in a parse tree.
+func collectDefinitions(tree parse.Tree) []*definition {
+	c := collector{tree.Source.Code}
+	return c.collect(tree.Root, tree.Root.Range())
+}
+
+type collector struct{ src string }
+
+func (c collector) collect(n parse.Node, scope diag.Ranging) []*definition {
+	if form, ok := n.(*parse.Form); ok {
+		if defs, ok := c.formDefinitions(form, scope); ok {
+			return defs
+		}
+	}
+	var defs []*definition
+	for _, ch := range parse.Children(n) {
+		defs = append(defs, c.collect(ch, scope)...)
+	}
+	return defs
+}
+
+// Returns the definitions in a form that defines something, and whether it
+// does. Legacy assignment forms like "a = foo" are treated as variable
+// definitions.
+func (c collector) formDefinitions(form *parse.Form, scope diag.Ranging) ([]*definition, bool) {
+	head, _ := cmpd.StringLiteral(form.Head)
+	switch head {
+	case "fn":
+		if len(form.Args) < 2 {
+			return nil, false
+		}
+		name, ok := cmpd.StringLiteral(form.Args[0])
+		lambda, isLambda := cmpd.Lambda(form.Args[1])
+		if !ok || !isLambda {
+			return nil, false
+		}
+		d := &definition{
+			kind: SymbolKindFunction, name: name,
+			nameRange: form.Args[0].Range(), fullRange: form.Range(), scope: scope,
+			signature: strings.TrimSpace(
+				"fn " + name + " " + lambdaSignature(c.src, lambda)),
+			doc: parseutil.CommentBefore(c.src, form.Range().From)}
+		d.children = c.lambdaDefinitions(lambda)
+		return []*definition{d}, true
+	case "var":
+		var defs []*definition
+		for i, arg := range form.Args {
+			if parse.SourceText(arg) == "=" {
+				for _, rhs := range form.Args[i+1:] {
+					defs = append(defs, c.collect(rhs, scope)...)
+				}
+				break
+			}
+			if d := c.variableDefinition(arg, form, scope); d != nil {
+				defs = append(defs, d)
+			}
+		}
+		return defs, true
+	case "use":
+		if len(form.Args) == 0 {
+			return nil, false
+		}
+		spec, ok := cmpd.StringLiteral(form.Args[0])
+		if !ok {
+			return nil, false
+		}
+		name := spec[strings.LastIndexByte(spec, '/')+1:]
+		if len(form.Args) > 1 {
+			if s, ok := cmpd.StringLiteral(form.Args[1]); ok {
+				name = s
+			}
+		}
+		return []*definition{{
+			kind: SymbolKindModule, name: name, spec: spec,
+			nameRange: form.Args[0].Range(), fullRange: form.Range(),
+			scope: scope}}, true
+	}
+	for i, arg := range form.Args {
+		if parse.SourceText(arg) == "=" {
+			var defs []*definition
+			for _, lhs := range append([]*parse.Compound{form.Head}, form.Args[:i]...) {
+				if d := c.variableDefinition(lhs, form, scope); d != nil {
+					defs = append(defs, d)
+				}
+			}
+			for _, rhs := range form.Args[i+1:] {
+				defs = append(defs, c.collect(rhs, scope)...)
+			}
+			return defs, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the definition of a variable named by n, or nil if n is not a valid
+// variable name.
+func (c collector) variableDefinition(n *parse.Compound, form *parse.Form, scope diag.Ranging) *definition {
+	if len(n.Indexings) != 1 || len(n.Indexings[0].Indicies) > 0 {
+		return nil
+	}
+	pn := n.Indexings[0].Head
+	if !parse.ValidLHSVariable(pn, true) || !eval.IsUnqualified(pn.Value) {
+		return nil
+	}
+	_, name := eval.SplitSigil(pn.Value)
+	return &definition{
+		kind: SymbolKindVariable, name: name,
+		nameRange: n.Range(), fullRange: form.Range(), scope: scope,
+		doc: parseutil.CommentBefore(c.src, form.Range().From)}
+}
+
+// Returns the parameters and options of a lambda, and the definitions in its
+// body.
+func (c collector) lambdaDefinitions(lambda *parse.Primary) []*definition {
+	scope := lambda.Range()
+	var defs []*definition
+	addParam := func(n *parse.Compound) {
+		name, ok := paramName(n)
+		if !ok {
+			return
+		}
+		defs = append(defs, &definition{
+			kind: SymbolKindVariable, name: name, param: true,
+			nameRange: n.Range(), fullRange: n.Range(), scope: scope})
+	}
+	for _, param := range lambda.Elements {
+		addParam(param)
+	}
+	for _, opt := range lambda.MapPairs {
+		addParam(opt.Key)
+	}
+	return append(defs, c.collect(lambda.Chunk, scope)...)
+}
+
+// Returns the name of a parameter or option of a lambda, written as
+// [@]name[:kind][=default].
+func paramName(n *parse.Compound) (string, bool) {
+	name, ok := cmpd.StringLiteral(n)
+	if !ok {
+		// Parameters with default values like x=(num 1) are not string
+		// literals; the name is in the leading bareword.
+		if len(n.Indexings) == 0 || n.Indexings[0].Head.Type != parse.Bareword ||
+			!strings.Contains(n.Indexings[0].Head.Value, "=") {
+			return "", false
+		}
+		name = n.Indexings[0].Head.Value
+	}
+	if i := strings.IndexByte(name, '='); i != -1 {
+		name = name[:i]
+	}
+	if i := strings.IndexByte(name, ':'); i != -1 && i < len(name)-1 {
+		name = name[:i]
+	}
+	_, name = eval.SplitSigil(name)
+	return name, true
+}
+
+// Returns the source of the signature of a lambda, which is everything before
+// the opening brace of its body.
+func lambdaSignature(src string, lambda *parse.Primary) string {
+	return src[lambda.Range().From : lambda.Chunk.Range().From-1]
+}
+
+// Calls f with all the definitions, including those nested in functions.
+func eachDefinition(defs []*definition, f func(*definition)) {
+	for _, d := range defs {
+		f(d)
+		eachDefinition(d.children, f)
+	}
+}
+
+// Finds the definition of the given kind and name that is visible at pos. The
+// last such definition before pos is preferred; if there is none, the first
+// one after pos is used.
+func findDefinition(defs []*definition, kind SymbolKind, name string, pos int) *definition {
+	var before, after *definition
+	eachDefinition(defs, func(d *definition) {
+		if d.kind != kind || d.name != name ||
+			pos < d.scope.From || pos > d.scope.To {
+			return
+		}
+		if d.nameRange.From <= pos {
+			if before == nil || d.nameRange.From > before.nameRange.From {
+				before = d
+			}
+		} else if after == nil || d.nameRange.From < after.nameRange.From {
+			after = d
+		}
+	})
+	if before != nil {
+		return before
+	}
+	return after
+}
+
+// A reference to a function, variable or module at some position.
+type reference struct {
+	kind SymbolKind
+	// The namespace and name of what is referred to. For modules, ns is empty
+	// and name is the module spec.
+	ns, name string
+	// Range of the reference.
+	r diag.Ranging
+}
+
+// Finds the reference at the given position.
+func findReference(tree parse.Tree, pos int) (reference, bool) {
+	n := findLeafNode(tree.Root, pos)
+	for n != nil {
+		if _, ok := n.(*parse.Primary); ok {
+			break
+		}
+		n = parse.Parent(n)
+	}
+	pn, ok := n.(*parse.Primary)
+	if !ok {
+		return reference{}, false
+	}
+	if pn.Type == parse.Variable {
+		_, qname := eval.SplitSigil(pn.Value)
+		ns, name := splitNs(qname)
+		kind := SymbolKindVariable
+		if strings.HasSuffix(name, eval.FnSuffix) {
+			kind, name = SymbolKindFunction, strings.TrimSuffix(name, eval.FnSuffix)
+		}
+		return reference{kind, ns, name, pn.Range()}, true
+	}
+	if pn.Type != parse.Bareword && pn.Type != parse.SingleQuoted && pn.Type != parse.DoubleQuoted {
+		return reference{}, false
+	}
+	indexing, ok := parse.Parent(pn).(*parse.Indexing)
+	if !ok || len(indexing.Indicies) > 0 {
+		return reference{}, false
+	}
+	compound, ok := parse.Parent(indexing).(*parse.Compound)
+	if !ok || len(compound.Indexings) != 1 {
+		return reference{}, false
+	}
+	form, ok := parse.Parent(compound).(*parse.Form)
+	if !ok {
+		return reference{}, false
+	}
+	if form.Head == compound {
+		ns, name := splitNs(pn.Value)
+		return reference{SymbolKindFunction, ns, name, pn.Range()}, true
+	}
+	if head, _ := cmpd.StringLiteral(form.Head); head == "use" && form.Args[0] == compound {
+		return reference{SymbolKindModule, "", pn.Value, pn.Range()}, true
+	}
+	return reference{}, false
+}
+
+// Splits a qualified name into the namespace, including the trailing colon,
+// and the name.
+func splitNs(qname string) (string, string) {
+	i := strings.LastIndexByte(qname, ':')
+	return qname[:i+1], qname[i+1:]
+}
+
+// Like parseutil.FindLeafNode, but when pos is at the boundary of two nodes,
+// finds the node after pos instead of the one before it, since that is what
+// the cursor is on in an editor.
+func findLeafNode(n parse.Node, pos int) parse.Node {
+descend:
+	for len(parse.Children(n)) > 0 {
+		var fallback parse.Node
+		for _, ch := range parse.Children(n) {
+			r := ch.Range()
+			if r.From <= pos && pos < r.To {
+				n = ch
+				continue descend
+			} else if r.From <= pos && pos == r.To && fallback == nil {
+				fallback = ch
+			}
+		}
+		if fallback == nil {
+			return nil
+		}
+		n = fallback
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package lsp
+
+// A minimal implementation of JSON-RPC 2.0 over a stream, using the base
+// protocol of LSP: each message is preceded by a header with a Content-Length
+// field and terminated by an empty line.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error codes defined by JSON-RPC and LSP.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeInvalidRequest = -32600
+)
+
+// A message is either a request, which has an ID, a notification, which
+// doesn't, or a response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+var errMissingContentLength = errors.New("missing Content-Length header")
+
+// Reads and writes messages. Writing is safe for concurrent use.
+type conn struct {
+	r *bufio.Reader
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+// Reads the content of the next message. It returns io.EOF when there are no
+// more messages.
+func (c *conn) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length == -1 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		i := strings.IndexByte(line, ':')
+		if i == -1 {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		if strings.EqualFold(line[:i], "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %v", err)
+			}
+		}
+	}
+	if length == -1 {
+		return nil, errMissingContentLength
+	}
+	content := make([]byte, length)
+	_, err := io.ReadFull(c.r, content)
+	return content, err
+}
+
+// Writes a message.
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	return err
+}
+
+// Writes a notification.
+func (c *conn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
+
+// Writes the response to a request. A nil result is written as null, as
+// required for successful responses.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	msg := &message{ID: id}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{codeInternalError, err.Error()}
+		}
+		msg.Error = rpcErr
+	} else if result == nil {
+		msg.Result = json.RawMessage("null")
+	} else {
+		msg.Result = result
+	}
+	return c.write(msg)
+}
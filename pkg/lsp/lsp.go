@@ -0,0 +1,34 @@
+// Package lsp implements a language server for Elvish, speaking the Language
+// Server Protocol over stdio.
+//
+// The server publishes diagnostics for parse and compilation errors, and
+// supports completion, hover, go-to-definition, document symbols and
+// formatting.
+package lsp
+
+import (
+	"os"
+
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/shell"
+)
+
+// Program is the LSP subprogram.
+var Program prog.Program = program{}
+
+type program struct{}
+
+func (program) ShouldRun(f *prog.Flags) bool { return f.LSP }
+
+func (program) Run(fds [3]*os.File, f *prog.Flags, args []string) error {
+	if len(args) > 0 {
+		return prog.BadUsage("arguments are not allowed with -lsp")
+	}
+	if f.CodeInArg {
+		return prog.BadUsage("-c cannot be used together with -lsp")
+	}
+	p := shell.MakePaths(fds[2], shell.Paths{Sock: f.Sock, Db: f.DB})
+	ev := shell.InitRuntime(fds[2], p, nil)
+	defer shell.CleanupRuntime(fds[2], ev)
+	return NewServer(ev).Serve(fds[0], fds[1])
+}
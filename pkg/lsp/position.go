@@ -0,0 +1,57 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Positions in LSP consist of a 0-based line number and a 0-based offset in
+// UTF-16 code units within the line, while Elvish uses byte offsets into the
+// source. The following functions convert between them.
+
+// Converts a position to a byte offset in text. Positions beyond the end of a
+// line or the text are clamped.
+func positionToOffset(text string, pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i == -1 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	for units := 0; offset < len(text) && units < pos.Character; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// Converts a byte offset in text to a position.
+func offsetToPosition(text string, offset int) Position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	before := text[:offset]
+	line := strings.Count(before, "\n")
+	character := 0
+	for _, r := range before[strings.LastIndexByte(before, '\n')+1:] {
+		character += utf16Len(r)
+	}
+	return Position{line, character}
+}
+
+func rangeOf(text string, from, to int) Range {
+	return Range{offsetToPosition(text, from), offsetToPosition(text, to)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
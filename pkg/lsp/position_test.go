@@ -0,0 +1,42 @@
+package lsp
+
+import "testing"
+
+var positionTests = []struct {
+	text   string
+	offset int
+	pos    Position
+}{
+	{"", 0, Position{0, 0}},
+	{"foo\nbar", 2, Position{0, 2}},
+	{"foo\nbar", 4, Position{1, 0}},
+	{"foo\nbar", 7, Position{1, 3}},
+	// Non-ASCII characters take one UTF-16 code unit each, except for those
+	// outside the BMP, which take two.
+	{"你好\nx", 6, Position{0, 2}},
+	{"😀x", 4, Position{0, 2}},
+	{"😀x", 5, Position{0, 3}},
+}
+
+func TestPositionConversion(t *testing.T) {
+	for _, test := range positionTests {
+		if got := offsetToPosition(test.text, test.offset); got != test.pos {
+			t.Errorf("offsetToPosition(%q, %d) -> %v, want %v",
+				test.text, test.offset, got, test.pos)
+		}
+		if got := positionToOffset(test.text, test.pos); got != test.offset {
+			t.Errorf("positionToOffset(%q, %v) -> %d, want %d",
+				test.text, test.pos, got, test.offset)
+		}
+	}
+}
+
+func TestPositionToOffset_Clamps(t *testing.T) {
+	text := "foo\nbar"
+	if got := positionToOffset(text, Position{0, 10}); got != 3 {
+		t.Errorf("got %d for a position beyond the end of a line, want 3", got)
+	}
+	if got := positionToOffset(text, Position{5, 0}); got != len(text) {
+		t.Errorf("got %d for a position beyond the last line, want %d", got, len(text))
+	}
+}
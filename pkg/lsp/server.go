@@ -0,0 +1,506 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/format"
+	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/parse"
+)
+
+// Server is a language server for Elvish.
+type Server struct {
+	ev   *eval.Evaler
+	conn *conn
+	docs map[string]*document
+	// Whether a shutdown request has been received.
+	shutdown bool
+}
+
+// A text document opened by the client.
+type document struct {
+	uri string
+	// Path of the file, or an empty string if the URI is not a file URI.
+	path string
+	text string
+	tree parse.Tree
+	defs []*definition
+}
+
+// NewServer creates a new Server that uses the given Evaler to check code and
+// find modules.
+func NewServer(ev *eval.Evaler) *Server {
+	return &Server{ev: ev, docs: make(map[string]*document)}
+}
+
+// Serve reads requests and notifications from r and writes responses and
+// notifications to w, until the exit notification is received or there is no
+// more input. It returns nil if the exit notification is received after a
+// shutdown request.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	for {
+		content, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var msg message
+		err = json.Unmarshal(content, &msg)
+		if err != nil {
+			s.conn.reply(nil, nil, &rpcError{codeParseError, err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+		result, err := s.handle(msg.Method, msg.Params)
+		if msg.ID != nil {
+			s.conn.reply(msg.ID, result, err)
+		}
+	}
+}
+
+var errExitWithoutShutdown = fmt.Errorf("exit notification received without shutdown request")
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, error) {
+	if s.shutdown && method != "exit" {
+		return nil, &rpcError{codeInvalidRequest, "server is shutting down"}
+	}
+	switch method {
+	case "initialize":
+		return s.initialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/completion":
+		return withPosition(s, params, s.completion)
+	case "textDocument/hover":
+		return withPosition(s, params, s.hover)
+	case "textDocument/definition":
+		return withPosition(s, params, s.definition)
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return documentSymbols(doc.text, doc.defs), nil
+	case "textDocument/formatting":
+		var p DocumentFormattingParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return formatting(doc.text), nil
+	}
+	if strings.HasPrefix(method, "$/") {
+		// Notifications and requests starting with $/ are optional.
+		return nil, nil
+	}
+	return nil, &rpcError{codeMethodNotFound, "unknown method " + method}
+}
+
+// Decodes TextDocumentPositionParams and calls f with the document and the
+// byte offset of the position.
+func withPosition(s *Server, params json.RawMessage, f func(*document, int) (interface{}, error)) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return f(doc, positionToOffset(doc.text, p.Position))
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{codeInvalidParams, "unknown document " + uri}
+	}
+	return doc, nil
+}
+
+func (s *Server) initialize() (interface{}, error) {
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       TextDocumentSyncKindFull,
+			CompletionProvider:     &CompletionOptions{TriggerCharacters: []string{"$", ":"}},
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			DocumentSymbolProvider: true,
+
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: "elvish"},
+	}, nil
+}
+
+// Updates the content of a document, and publishes its diagnostics.
+func (s *Server) update(uri, text string) {
+	path := uriToPath(uri)
+	name := path
+	if name == "" {
+		name = uri
+	}
+	src := parse.Source{Name: name, Code: text, IsFile: path != ""}
+	tree, _ := parse.Parse(src, parse.Config{})
+	doc := &document{uri, path, text, tree, collectDefinitions(tree)}
+	s.docs[uri] = doc
+	s.conn.notify("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(src)})
+}
+
+// Returns the parse and compilation errors in the source.
+func (s *Server) diagnostics(src parse.Source) []Diagnostic {
+	diags := []Diagnostic{}
+	add := func(err *diag.Error) {
+		diags = append(diags, Diagnostic{
+			Range:    rangeOf(src.Code, err.Context.From, err.Context.To),
+			Severity: SeverityError,
+			Source:   "elvish",
+			Message:  err.Message,
+		})
+	}
+	parseErr, compileErr := s.ev.Check(src, ioutil.Discard)
+	if parseErr != nil {
+		for _, err := range parseErr.Entries {
+			add(err)
+		}
+	}
+	if compileErr != nil {
+		add(compileErr)
+	}
+	return diags
+}
+
+func (s *Server) completion(doc *document, pos int) (interface{}, error) {
+	result, err := complete.Complete(
+		complete.CodeBuffer{Content: doc.text, Dot: pos},
+		complete.Config{PureEvaler: pureEvaler{s, doc}})
+	if err != nil {
+		// No completion is available here.
+		return CompletionList{Items: []CompletionItem{}}, nil
+	}
+	r := rangeOf(doc.text, result.Replace.From, result.Replace.To)
+	items := make([]CompletionItem, len(result.Items))
+	for i, item := range result.Items {
+		items[i] = CompletionItem{
+			Label:    item.ToShow,
+			TextEdit: &TextEdit{Range: r, NewText: item.ToInsert}}
+	}
+	return CompletionList{Items: items}, nil
+}
+
+func (s *Server) hover(doc *document, pos int) (interface{}, error) {
+	ref, ok := findReference(doc.tree, pos)
+	if !ok {
+		return nil, nil
+	}
+	var value string
+	if d, _ := s.resolve(doc, ref, pos); d != nil {
+		value = hoverText(d)
+	} else if ref.kind == SymbolKindFunction && ref.ns == "" &&
+		s.ev.Builtin().HasName(ref.name+eval.FnSuffix) {
+		value = "```elvish\n" + ref.name + "\n```\n\nBuiltin function."
+	} else if ref.kind == SymbolKindFunction && ref.ns == "" && eval.IsBuiltinSpecial[ref.name] {
+		value = "```elvish\n" + ref.name + "\n```\n\nSpecial command."
+	} else {
+		return nil, nil
+	}
+	r := rangeOf(doc.text, ref.r.From, ref.r.To)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}, nil
+}
+
+func hoverText(d *definition) string {
+	var header string
+	switch d.kind {
+	case SymbolKindFunction:
+		header = d.signature
+	case SymbolKindVariable:
+		header = "var " + d.name
+	case SymbolKindModule:
+		header = "use " + d.spec
+	}
+	text := "```elvish\n" + header + "\n```"
+	if d.doc != "" {
+		text += "\n\n" + d.doc
+	}
+	return text
+}
+
+func (s *Server) definition(doc *document, pos int) (interface{}, error) {
+	ref, ok := findReference(doc.tree, pos)
+	if !ok {
+		return nil, nil
+	}
+	d, defDoc := s.resolve(doc, ref, pos)
+	if d == nil {
+		if ref.kind != SymbolKindModule {
+			return nil, nil
+		}
+		// The module spec in a use form.
+		path := s.modulePath(doc, ref.name)
+		if path == "" {
+			return nil, nil
+		}
+		return []Location{{URI: pathToURI(path)}}, nil
+	}
+	return []Location{{
+		URI:   defDoc.uri,
+		Range: rangeOf(defDoc.text, d.nameRange.From, d.nameRange.To)}}, nil
+}
+
+// Resolves a reference at pos to its definition, and returns the definition
+// and the document containing it. References to functions and variables in
+// modules imported with use are resolved by reading the module file.
+func (s *Server) resolve(doc *document, ref reference, pos int) (*definition, *document) {
+	if ref.kind == SymbolKindModule {
+		return nil, nil
+	}
+	if ref.ns == "" {
+		return findDefinition(doc.defs, ref.kind, ref.name, pos), doc
+	}
+	mod := findDefinition(doc.defs, SymbolKindModule, strings.TrimSuffix(ref.ns, ":"), pos)
+	if mod == nil {
+		return nil, nil
+	}
+	path := s.modulePath(doc, mod.spec)
+	if path == "" {
+		return nil, nil
+	}
+	modDoc := s.loadModule(path)
+	if modDoc == nil {
+		return nil, nil
+	}
+	for _, d := range modDoc.defs {
+		if d.kind == ref.kind && d.name == ref.name {
+			return d, modDoc
+		}
+	}
+	return nil, nil
+}
+
+// Returns the path of the file for a module spec used in doc, or an empty
+// string if it can't be found.
+func (s *Server) modulePath(doc *document, spec string) string {
+	var path string
+	if strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../") {
+		dir := "."
+		if doc.path != "" {
+			dir = filepath.Dir(doc.path)
+		}
+		path = filepath.Join(dir, spec) + ".elv"
+	} else if libDir := s.ev.LibDir(); libDir != "" {
+		path = filepath.Join(libDir, spec) + ".elv"
+	} else {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Loads a module file, preferring the content of the document if it is open.
+func (s *Server) loadModule(path string) *document {
+	uri := pathToURI(path)
+	if doc, ok := s.docs[uri]; ok {
+		return doc
+	}
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	tree, _ := parse.Parse(parse.Source{Name: path, Code: string(code), IsFile: true}, parse.Config{})
+	return &document{uri, path, string(code), tree, collectDefinitions(tree)}
+}
+
+// Converts definitions into document symbols. Parameters are omitted.
+func documentSymbols(text string, defs []*definition) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, d := range defs {
+		if d.param {
+			continue
+		}
+		symbol := DocumentSymbol{
+			Name:           d.name,
+			Kind:           d.kind,
+			Range:          rangeOf(text, d.fullRange.From, d.fullRange.To),
+			SelectionRange: rangeOf(text, d.nameRange.From, d.nameRange.To),
+		}
+		switch d.kind {
+		case SymbolKindFunction:
+			symbol.Detail = d.signature
+		case SymbolKindModule:
+			symbol.Detail = d.spec
+		}
+		if children := documentSymbols(text, d.children); len(children) > 0 {
+			symbol.Children = children
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// Returns the edits that format a document. Documents with parse errors are
+// left alone.
+func formatting(text string) []TextEdit {
+	formatted, err := format.Source("", text)
+	if err != nil || formatted == text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{rangeOf(text, 0, len(text)), formatted}}
+}
+
+// Implements complete.PureEvaler, using names from the builtin namespace and
+// the definitions in the document.
+type pureEvaler struct {
+	s   *Server
+	doc *document
+}
+
+func (pureEvaler) EachExternal(f func(string)) { fsutil.EachExternal(f) }
+
+func (pureEvaler) EachSpecial(f func(string)) {
+	for name := range eval.IsBuiltinSpecial {
+		f(name)
+	}
+}
+
+func (pe pureEvaler) EachNs(f func(string)) {
+	f("e:")
+	f("E:")
+	pe.s.ev.Builtin().IterateNames(func(name string) {
+		if strings.HasSuffix(name, eval.NsSuffix) {
+			f(name)
+		}
+	})
+	for _, d := range pe.doc.defs {
+		if d.kind == SymbolKindModule {
+			f(d.name + eval.NsSuffix)
+		}
+	}
+}
+
+func (pe pureEvaler) EachVariableInNs(ns string, f func(string)) {
+	switch ns {
+	case "", ":":
+		pe.s.ev.Builtin().IterateNames(f)
+		eachDefinedName(pe.doc.defs, f)
+	case "e:":
+		fsutil.EachExternal(func(cmd string) { f(cmd + eval.FnSuffix) })
+	case "E:":
+		for _, s := range os.Environ() {
+			if i := strings.IndexByte(s, '='); i > 0 {
+				f(s[:i])
+			}
+		}
+	default:
+		if v := pe.s.ev.Builtin().IndexName(strings.TrimSuffix(ns, ":") + eval.NsSuffix); v != nil {
+			if mod, ok := v.Get().(*eval.Ns); ok {
+				mod.IterateNames(f)
+			}
+			return
+		}
+		mod := findDefinition(pe.doc.defs, SymbolKindModule, strings.TrimSuffix(ns, ":"), len(pe.doc.text))
+		if mod == nil {
+			return
+		}
+		if path := pe.s.modulePath(pe.doc, mod.spec); path != "" {
+			if modDoc := pe.s.loadModule(path); modDoc != nil {
+				for _, d := range modDoc.defs {
+					eachDefinedName([]*definition{d}, f)
+				}
+			}
+		}
+	}
+}
+
+// Calls f with the variable names of the functions and variables among the
+// definitions, without descending into functions.
+func eachDefinedName(defs []*definition, f func(string)) {
+	for _, d := range defs {
+		switch d.kind {
+		case SymbolKindFunction:
+			f(d.name + eval.FnSuffix)
+		case SymbolKindVariable:
+			f(d.name)
+		}
+	}
+}
+
+func (pe pureEvaler) PurelyEvalPrimary(pn *parse.Primary) interface{} {
+	return pe.s.ev.PurelyEvalPrimary(pn)
+}
+
+func (pe pureEvaler) PurelyEvalCompound(cn *parse.Compound) (string, bool) {
+	return pe.s.ev.PurelyEvalCompound(cn)
+}
+
+func (pe pureEvaler) PurelyEvalPartialCompound(cn *parse.Compound, upto int) (string, bool) {
+	return pe.s.ev.PurelyEvalPartialCompound(cn, upto)
+}
+
+// Returns the path of a file URI, or an empty string if uri is not a file URI.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,402 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestInitialize(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	var result InitializeResult
+	c.request("initialize", map[string]interface{}{}, &result)
+	caps := result.Capabilities
+	if caps.TextDocumentSync != TextDocumentSyncKindFull || caps.CompletionProvider == nil ||
+		!caps.HoverProvider || !caps.DefinitionProvider || !caps.DocumentSymbolProvider ||
+		!caps.DocumentFormattingProvider {
+		t.Errorf("got capabilities %+v", caps)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	c.open("file:///a.elv", "echo (")
+	diags := c.diagnostics("file:///a.elv")
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "')'") ||
+		diags[0].Range.Start != (Position{0, 6}) {
+		t.Errorf("got diagnostics %+v, want one parse error at 0:6", diags)
+	}
+
+	c.change("file:///a.elv", "echo foo\necho $x")
+	diags = c.diagnostics("file:///a.elv")
+	want := []Diagnostic{{
+		Range:    Range{Position{1, 5}, Position{1, 7}},
+		Severity: SeverityError, Source: "elvish",
+		Message: "variable $x not found"}}
+	if !reflect.DeepEqual(diags, want) {
+		t.Errorf("got diagnostics %+v, want %+v", diags, want)
+	}
+
+	c.change("file:///a.elv", "var x = foo; echo $x")
+	if diags := c.diagnostics("file:///a.elv"); len(diags) != 0 {
+		t.Errorf("got diagnostics %+v, want none", diags)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	c.open("file:///a.elv", "fn greet [name]{ echo $name }\nvar greeting = hi\ngre\necho $gre")
+	c.diagnostics("file:///a.elv")
+
+	labels := func(pos Position) []string {
+		var list CompletionList
+		c.request("textDocument/completion", positionParams("file:///a.elv", pos), &list)
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	if got := labels(Position{2, 3}); !contains(got, "greet") {
+		t.Errorf("got command completions %q, want greet among them", got)
+	}
+	if got := labels(Position{3, 9}); !contains(got, "greeting") {
+		t.Errorf("got variable completions %q, want greeting among them", got)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	c.open("file:///a.elv", "# Greets someone.\n# Really.\nfn greet [name &loud=$false]{ echo $name }\ngreet world\nput foo")
+	c.diagnostics("file:///a.elv")
+
+	var hover Hover
+	c.request("textDocument/hover", positionParams("file:///a.elv", Position{3, 2}), &hover)
+	want := "```elvish\nfn greet [name &loud=$false]\n```\n\nGreets someone.\nReally."
+	if hover.Contents.Value != want {
+		t.Errorf("got hover %q, want %q", hover.Contents.Value, want)
+	}
+	if *hover.Range != (Range{Position{3, 0}, Position{3, 5}}) {
+		t.Errorf("got hover range %v", *hover.Range)
+	}
+
+	c.request("textDocument/hover", positionParams("file:///a.elv", Position{4, 1}), &hover)
+	if !strings.Contains(hover.Contents.Value, "Builtin function") {
+		t.Errorf("got hover %q for builtin", hover.Contents.Value)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	dir, cleanup := testutil.InTestDir()
+	defer cleanup()
+	testutil.ApplyDir(testutil.Dir{
+		"mod.elv": "fn helper []{ }\n",
+	})
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	uri := pathToURI(filepath.Join(dir, "a.elv"))
+	modURI := pathToURI(filepath.Join(dir, "mod.elv"))
+	c.open(uri, "use ./mod\nfn f [x]{ echo $x }\nf foo\nmod:helper\nvar v = 1; echo $v\n"+
+		"fn g [y:num=(num 1)]{ echo $y }")
+	c.diagnostics(uri)
+
+	tests := []struct {
+		pos  Position
+		want []Location
+	}{
+		// Function defined in the document.
+		{Position{2, 0}, []Location{{uri, Range{Position{1, 3}, Position{1, 4}}}}},
+		// Parameter.
+		{Position{1, 16}, []Location{{uri, Range{Position{1, 6}, Position{1, 7}}}}},
+		// Function in a module.
+		{Position{3, 6}, []Location{{modURI, Range{Position{0, 3}, Position{0, 9}}}}},
+		// Module spec.
+		{Position{0, 6}, []Location{{URI: modURI}}},
+		// Variable.
+		{Position{4, 17}, []Location{{uri, Range{Position{4, 4}, Position{4, 5}}}}},
+		// Parameter with a kind and a default value.
+		{Position{5, 28}, []Location{{uri, Range{Position{5, 6}, Position{5, 19}}}}},
+	}
+	for _, test := range tests {
+		var locs []Location
+		c.request("textDocument/definition", positionParams(uri, test.pos), &locs)
+		if !reflect.DeepEqual(locs, test.want) {
+			t.Errorf("definition at %v: got %v, want %v", test.pos, locs, test.want)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	c.open("file:///a.elv", "use str\nx = 1\nfn f [a]{\n  var y = 2\n}")
+	c.diagnostics("file:///a.elv")
+
+	var symbols []DocumentSymbol
+	c.request("textDocument/documentSymbol",
+		DocumentSymbolParams{TextDocumentIdentifier{"file:///a.elv"}}, &symbols)
+	want := []DocumentSymbol{
+		{Name: "str", Detail: "str", Kind: SymbolKindModule,
+			Range:          Range{Position{0, 0}, Position{0, 7}},
+			SelectionRange: Range{Position{0, 4}, Position{0, 7}}},
+		{Name: "x", Kind: SymbolKindVariable,
+			Range:          Range{Position{1, 0}, Position{1, 5}},
+			SelectionRange: Range{Position{1, 0}, Position{1, 1}}},
+		{Name: "f", Detail: "fn f [a]", Kind: SymbolKindFunction,
+			Range:          Range{Position{2, 0}, Position{4, 1}},
+			SelectionRange: Range{Position{2, 3}, Position{2, 4}},
+			Children: []DocumentSymbol{
+				{Name: "y", Kind: SymbolKindVariable,
+					Range:          Range{Position{3, 2}, Position{3, 11}},
+					SelectionRange: Range{Position{3, 6}, Position{3, 7}}}}},
+	}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Errorf("got symbols %+v, want %+v", symbols, want)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	c.open("file:///a.elv", "echo  foo\nfn f []{\necho }")
+	c.diagnostics("file:///a.elv")
+
+	var edits []TextEdit
+	c.request("textDocument/formatting",
+		DocumentFormattingParams{TextDocumentIdentifier{"file:///a.elv"}}, &edits)
+	want := []TextEdit{{
+		Range:   Range{Position{0, 0}, Position{2, 6}},
+		NewText: "echo foo\nfn f []{\n  echo\n}\n"}}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("got edits %+v, want %+v", edits, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	defer c.close()
+
+	err := c.requestError("foo/bar", nil)
+	if err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("got error %v for unknown method, want method not found", err)
+	}
+	err = c.requestError("textDocument/hover", positionParams("file:///unknown.elv", Position{}))
+	if err == nil || err.Code != codeInvalidParams {
+		t.Errorf("got error %v for unknown document, want invalid params", err)
+	}
+}
+
+func TestShutdownAndExit(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("Serve returned %v, want nil", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := startServer(t, eval.NewEvaler())
+	c.notify("exit", nil)
+	if err := c.wait(); err != errExitWithoutShutdown {
+		t.Errorf("Serve returned %v, want errExitWithoutShutdown", err)
+	}
+}
+
+// A client talking to a Server running in the same process.
+type testClient struct {
+	t      *testing.T
+	w      *io.PipeWriter
+	conn   *conn
+	nextID int
+	// Messages read from the server.
+	msgs chan *message
+	// Notifications read while waiting for responses.
+	notifications []*message
+	// Delivers the return value of Serve.
+	serveErr chan error
+}
+
+func startServer(t *testing.T, ev *eval.Evaler) *testClient {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &testClient{
+		t: t, w: clientW, conn: newConn(clientR, clientW),
+		msgs: make(chan *message, 100), serveErr: make(chan error, 1)}
+	go func() {
+		c.serveErr <- NewServer(ev).Serve(serverR, serverW)
+		serverW.Close()
+	}()
+	go func() {
+		defer close(c.msgs)
+		for {
+			content, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(content, &msg); err != nil {
+				t.Errorf("invalid message from server: %q", content)
+				return
+			}
+			c.msgs <- &msg
+		}
+	}()
+	return c
+}
+
+func (c *testClient) close() {
+	c.w.Close()
+	c.wait()
+}
+
+// Waits for Serve to return.
+func (c *testClient) wait() error {
+	select {
+	case err := <-c.serveErr:
+		return err
+	case <-time.After(testutil.ScaledMs(1000)):
+		c.t.Fatal("timed out waiting for server to exit")
+		return nil
+	}
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// Sends a request and waits for its response, decoding the result into result.
+func (c *testClient) request(method string, params, result interface{}) {
+	c.t.Helper()
+	msg := c.call(method, params)
+	if msg.Error != nil {
+		c.t.Fatalf("%s: got error %v", method, msg.Error)
+	}
+	if result != nil {
+		b, _ := json.Marshal(msg.Result)
+		if err := json.Unmarshal(b, result); err != nil {
+			c.t.Fatalf("%s: cannot decode result %s: %v", method, b, err)
+		}
+	}
+}
+
+// Sends a request and returns the error in its response.
+func (c *testClient) requestError(method string, params interface{}) *rpcError {
+	c.t.Helper()
+	return c.call(method, params).Error
+}
+
+func (c *testClient) call(method string, params interface{}) *message {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.nextID))))
+	raw := json.RawMessage("null")
+	if params != nil {
+		raw = mustMarshal(params)
+	}
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: raw}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.next()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("got response with ID %s, want %s", *msg.ID, id)
+		}
+		return msg
+	}
+}
+
+func (c *testClient) next() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed connection")
+		}
+		return msg
+	case <-time.After(testutil.ScaledMs(1000)):
+		c.t.Fatal("timed out waiting for message from server")
+		return nil
+	}
+}
+
+func (c *testClient) open(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocumentItem{URI: uri, LanguageID: "elvish", Version: 1, Text: text}})
+}
+
+func (c *testClient) change(uri, text string) {
+	c.t.Helper()
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocumentIdentifier{uri}, []TextDocumentContentChangeEvent{{text}}})
+}
+
+// Waits for the next diagnostics published for uri.
+func (c *testClient) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+func positionParams(uri string, pos Position) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocumentIdentifier{uri}, pos}
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -47,3 +47,21 @@ func isCompound(n parse.Node) bool {
 	_, ok := n.(*parse.Compound)
 	return ok
 }
+
+// CommentBefore returns the comment lines immediately before the line
+// containing the given position, without the leading "#" and one space.
+func CommentBefore(code string, pos int) string {
+	lineStart := strings.LastIndexByte(code[:pos], '\n') + 1
+	var lines []string
+	for lineStart > 0 {
+		prevStart := strings.LastIndexByte(code[:lineStart-1], '\n') + 1
+		line := strings.TrimSpace(code[prevStart : lineStart-1])
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "#"), " ")
+		lines = append([]string{line}, lines...)
+		lineStart = prevStart
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,7 +1,20 @@
 package parseutil
 
-import "testing"
+import (
+	"testing"
+
+	"src.elv.sh/pkg/tt"
+)
 
 func Test(t *testing.T) {
 	// Required to get accurate test coverage report.
 }
+
+func TestCommentBefore(t *testing.T) {
+	tt.Test(t, tt.Fn("CommentBefore", CommentBefore), tt.Table{
+		tt.Args("fn f { }", 0).Rets(""),
+		tt.Args("# Does\n#things.\nfn f { }", 17).Rets("Does\nthings."),
+		tt.Args("# Unrelated.\n\n# Does things.\n  fn f { }", 31).Rets("Does things."),
+		tt.Args("echo # not a comment\nfn f { }", 21).Rets(""),
+	})
+}
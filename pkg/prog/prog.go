@@ -40,11 +40,15 @@ type Flags struct {
 
 	Help, Version, BuildInfo, JSON bool
 
-	CodeInArg, CompileOnly, NoRc bool
+	CodeInArg, CompileOnly, NoRc, Trace bool
 
 	Web  bool
 	Port int
 
+	LSP bool
+
+	Fmt, FmtWrite, FmtCheck bool
+
 	Daemon bool
 	Forked int
 
@@ -70,10 +74,17 @@ func newFlagSet(f *Flags) *flag.FlagSet {
 	fs.BoolVar(&f.CodeInArg, "c", false, "take first argument as code to execute")
 	fs.BoolVar(&f.CompileOnly, "compileonly", false, "Parse/Compile but do not execute")
 	fs.BoolVar(&f.NoRc, "norc", false, "run elvish without invoking rc.elv")
+	fs.BoolVar(&f.Trace, "trace", false, "print each command to stderr before running it; can be changed with $debug:trace")
 
 	fs.BoolVar(&f.Web, "web", false, "run backend of web interface")
 	fs.IntVar(&f.Port, "port", defaultWebPort, "the port of the web backend")
 
+	fs.BoolVar(&f.LSP, "lsp", false, "run language server over stdio")
+
+	fs.BoolVar(&f.Fmt, "fmt", false, "format the given files or standard input")
+	fs.BoolVar(&f.FmtWrite, "w", false, "with -fmt, write the result to the files instead of standard output")
+	fs.BoolVar(&f.FmtCheck, "check", false, "with -fmt, list the files that are not formatted and exit with 1 if there are any")
+
 	fs.BoolVar(&f.Daemon, "daemon", false, "[internal flag] run the storage daemon instead of shell")
 
 	fs.StringVar(&f.DB, "db", "", "[internal flag] path to the database")
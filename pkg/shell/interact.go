@@ -27,6 +27,7 @@ var interactiveRescueShell bool = true
 type InteractConfig struct {
 	ActivateDaemon daemondefs.ActivateFunc
 	Paths          Paths
+	Trace          bool
 }
 
 // Interactive mode panic handler.
@@ -49,20 +50,23 @@ func Interact(fds [3]*os.File, cfg *InteractConfig) {
 	}
 	ev, cleanup := setupShell(fds, cfg.Paths, cfg.ActivateDaemon)
 	defer cleanup()
+	ev.SetTrace(cfg.Trace)
 
-	// Build Editor.
+	// Build Editor. Job control is only enabled when the terminal is used.
 	var ed editor
+	jobControl := false
 	if sys.IsATTY(fds[0]) {
 		newed := edit.NewEditor(cli.NewTTY(fds[0], fds[2]), ev, ev.DaemonClient())
 		ev.AddBuiltin(eval.NsBuilder{}.AddNs("edit", newed.Ns()).Ns())
 		ed = newed
+		jobControl = true
 	} else {
 		ed = newMinEditor(fds[0], fds[2])
 	}
 
 	// Source rc.elv.
 	if cfg.Paths.Rc != "" {
-		err := sourceRC(fds, ev, ed, cfg.Paths.Rc)
+		err := sourceRC(fds, ev, ed, cfg.Paths.Rc, jobControl)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 		}
@@ -105,7 +109,7 @@ func Interact(fds [3]*os.File, cfg *InteractConfig) {
 			continue
 		}
 		src := parse.Source{Name: fmt.Sprintf("[tty %v]", cmdNum), Code: line}
-		duration, err := evalInTTY(ev, fds, src)
+		duration, err := evalInTTY(ev, fds, src, jobControl)
 		ed.RunAfterCommandHooks(src, duration, err)
 		term.Sanitize(fds[0], fds[2])
 		if err != nil {
@@ -114,7 +118,7 @@ func Interact(fds [3]*os.File, cfg *InteractConfig) {
 	}
 }
 
-func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed eval.Editor, rcPath string) error {
+func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed eval.Editor, rcPath string, jobControl bool) error {
 	absPath, err := filepath.Abs(rcPath)
 	if err != nil {
 		return fmt.Errorf("cannot get full path of rc.elv: %v", err)
@@ -127,7 +131,7 @@ func sourceRC(fds [3]*os.File, ev *eval.Evaler, ed eval.Editor, rcPath string) e
 		return err
 	}
 	src := parse.Source{Name: absPath, Code: code, IsFile: true}
-	duration, err := evalInTTY(ev, fds, src)
+	duration, err := evalInTTY(ev, fds, src, jobControl)
 	ed.RunAfterCommandHooks(src, duration, err)
 	return err
 }
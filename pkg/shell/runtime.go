@@ -6,7 +6,9 @@ import (
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/eval"
+	chanmod "src.elv.sh/pkg/eval/mods/chan"
 	daemonmod "src.elv.sh/pkg/eval/mods/daemon"
+	debugmod "src.elv.sh/pkg/eval/mods/debug"
 	"src.elv.sh/pkg/eval/mods/file"
 	mathmod "src.elv.sh/pkg/eval/mods/math"
 	pathmod "src.elv.sh/pkg/eval/mods/path"
@@ -32,6 +34,8 @@ func InitRuntime(stderr io.Writer, p Paths, activate daemondefs.ActivateFunc) *e
 	ev.AddModule("re", re.Ns)
 	ev.AddModule("str", str.Ns)
 	ev.AddModule("file", file.Ns)
+	ev.AddModule("chan", chanmod.Ns)
+	ev.AddModule("debug", debugmod.Ns(ev))
 	if unix.ExposeUnixNs {
 		ev.AddModule("unix", unix.Ns)
 	}
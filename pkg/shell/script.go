@@ -22,12 +22,17 @@ type ScriptConfig struct {
 	Cmd         bool
 	CompileOnly bool
 	JSON        bool
+	Trace       bool
 }
 
 // Script executes a shell script.
 func Script(fds [3]*os.File, args []string, cfg *ScriptConfig) int {
 	ev, cleanup := setupShell(fds, cfg.Paths, cfg.ActivateDaemon)
 	defer cleanup()
+	// Scripts run without job control, so external commands should not be
+	// stopped by job control signals.
+	ignoreJobControlSignals()
+	ev.SetTrace(cfg.Trace)
 
 	arg0 := args[0]
 	ev.SetArgs(args[1:])
@@ -68,7 +73,7 @@ func Script(fds [3]*os.File, args []string, cfg *ScriptConfig) int {
 			return 2
 		}
 	} else {
-		_, err := evalInTTY(ev, fds, src)
+		_, err := evalInTTY(ev, fds, src, false)
 		if err != nil {
 			diag.ShowError(fds[2], err)
 			return 2
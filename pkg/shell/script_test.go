@@ -106,3 +106,13 @@ func TestScript_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestScript_Trace(t *testing.T) {
+	f := Setup()
+	defer f.Cleanup()
+
+	Script(f.Fds(), []string{"echo hello"}, &ScriptConfig{Cmd: true, Trace: true})
+
+	f.TestOut(t, 1, "hello\n")
+	f.TestOut(t, 2, "+ code from -c:1:1: echo hello\n")
+}
@@ -11,6 +11,7 @@ import (
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/mods/unix"
 	"src.elv.sh/pkg/logutil"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/prog"
@@ -35,10 +36,12 @@ func (p Program) Run(fds [3]*os.File, f *prog.Flags, args []string) error {
 		exit := Script(
 			fds, args, &ScriptConfig{
 				Paths: paths,
-				Cmd:   f.CodeInArg, CompileOnly: f.CompileOnly, JSON: f.JSON})
+				Cmd:   f.CodeInArg, CompileOnly: f.CompileOnly, JSON: f.JSON,
+				Trace: f.Trace})
 		return prog.Exit(exit)
 	}
-	Interact(fds, &InteractConfig{ActivateDaemon: p.ActivateDaemon, Paths: paths})
+	Interact(fds, &InteractConfig{
+		ActivateDaemon: p.ActivateDaemon, Paths: paths, Trace: f.Trace})
 	return nil
 }
 
@@ -47,10 +50,15 @@ func setupShell(fds [3]*os.File, p Paths, activate daemondefs.ActivateFunc) (*ev
 	ev := InitRuntime(fds[2], p, activate)
 	restoreSHLVL := incSHLVL()
 	sigCh := sys.NotifySignals()
+	unix.SetSignalStderr(fds[2])
 
 	go func() {
 		for sig := range sigCh {
 			logger.Println("signal", sig)
+			if unix.HasSignalHandler(sig) {
+				// Handled by the handler set with unix:on-signal.
+				continue
+			}
 			handleSignal(sig, fds[2])
 		}
 	}()
@@ -63,12 +71,13 @@ func setupShell(fds [3]*os.File, p Paths, activate daemondefs.ActivateFunc) (*ev
 	}
 }
 
-func evalInTTY(ev *eval.Evaler, fds [3]*os.File, src parse.Source) (float64, error) {
+func evalInTTY(ev *eval.Evaler, fds [3]*os.File, src parse.Source, jobControl bool) (float64, error) {
 	start := time.Now()
 	ports, cleanup := eval.PortsFromFiles(fds, ev.ValuePrefix())
 	defer cleanup()
 	err := ev.Eval(src, eval.EvalCfg{
-		Ports: ports, Interrupt: eval.ListenInterrupts, PutInFg: true})
+		Ports: ports, Interrupt: eval.ListenInterrupts, PutInFg: true,
+		JobControl: jobControl})
 	end := time.Now()
 	return end.Sub(start).Seconds(), err
 }
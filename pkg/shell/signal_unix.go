@@ -5,6 +5,7 @@ package shell
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"syscall"
 
 	"src.elv.sh/pkg/sys"
@@ -19,3 +20,7 @@ func handleSignal(sig os.Signal, stderr *os.File) {
 		fmt.Fprint(stderr, sys.DumpStack())
 	}
 }
+
+func ignoreJobControlSignals() {
+	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGTSTP)
+}
@@ -6,3 +6,5 @@ import (
 
 func handleSignal(os.Signal, *os.File) {
 }
+
+func ignoreJobControlSignals() {}
@@ -2,6 +2,7 @@ package store
 
 const (
 	bucketCmd       = "cmd"
+	bucketCmdInfo   = "cmd_info"
 	bucketDir       = "dir"
 	bucketSharedVar = "shared_var"
 )
@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 
 	bolt "go.etcd.io/bbolt"
 	. "src.elv.sh/pkg/store/storedefs"
@@ -43,11 +44,51 @@ func (s *dbStore) AddCmd(cmd string) (int, error) {
 	return int(seq), err
 }
 
-// DelCmd deletes a command history item with the given sequence number.
+// AddCmds adds new commands to the command history, along with the information
+// about them keyed by their indices in cmds, and returns the sequence number of
+// the first one. The commands get consecutive sequence numbers.
+func (s *dbStore) AddCmds(cmds []string, infos map[int]CmdInfo) (int, error) {
+	var first uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCmd))
+		bInfo := tx.Bucket([]byte(bucketCmdInfo))
+		for i, cmd := range cmds {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				first = seq
+			}
+			err = b.Put(marshalSeq(seq), []byte(cmd))
+			if err != nil {
+				return err
+			}
+			if info, ok := infos[i]; ok {
+				v, err := json.Marshal(info)
+				if err != nil {
+					return err
+				}
+				err = bInfo.Put(marshalSeq(seq), v)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return int(first), err
+}
+
+// DelCmd deletes a command history item with the given sequence number, along
+// with the information about it.
 func (s *dbStore) DelCmd(seq int) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketCmd))
-		return b.Delete(marshalSeq(uint64(seq)))
+		err := tx.Bucket([]byte(bucketCmd)).Delete(marshalSeq(uint64(seq)))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(bucketCmdInfo)).Delete(marshalSeq(uint64(seq)))
 	})
 }
 
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+func init() {
+	// Databases created before the command info table was introduced get the
+	// table when they are opened, with no information about existing commands.
+	initDB["initialize command info table"] = func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketCmdInfo))
+		return err
+	}
+}
+
+// SetCmdInfo sets the information about the command with the given sequence
+// number.
+func (s *dbStore) SetCmdInfo(seq int, info CmdInfo) error {
+	v, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCmdInfo))
+		return b.Put(marshalSeq(uint64(seq)), v)
+	})
+}
+
+// CmdInfo queries the information about the command with the given sequence
+// number.
+func (s *dbStore) CmdInfo(seq int) (CmdInfo, error) {
+	var info CmdInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCmdInfo))
+		v := b.Get(marshalSeq(uint64(seq)))
+		if v == nil {
+			return ErrNoCmdInfo
+		}
+		return json.Unmarshal(v, &info)
+	})
+	return info, err
+}
+
+// CmdInfos returns the information about all commands within the specified
+// range that have it, keyed by their sequence numbers.
+func (s *dbStore) CmdInfos(from, upto int) (map[int]CmdInfo, error) {
+	infos := make(map[int]CmdInfo)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCmdInfo))
+		c := b.Cursor()
+		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, v = c.Next() {
+			var info CmdInfo
+			err := json.Unmarshal(v, &info)
+			if err != nil {
+				return err
+			}
+			infos[int(unmarshalSeq(k))] = info
+		}
+		return nil
+	})
+	return infos, err
+}
@@ -0,0 +1,62 @@
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/store/storetest"
+)
+
+func TestCmdInfo(t *testing.T) {
+	tStore, cleanup := store.MustGetTempStore()
+	defer cleanup()
+	storetest.TestCmdInfo(t, tStore)
+}
+
+func TestCmdInfo_OldDatabase(t *testing.T) {
+	f, err := ioutil.TempFile("", "elvish.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// Create a database that only has the command history table, like those
+	// created before the command info table was introduced.
+	db, err := bolt.Open(f.Name(), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("cmd"))
+		if err != nil {
+			return err
+		}
+		seq, _ := b.NextSequence()
+		return b.Put([]byte{0, 0, 0, 0, 0, 0, 0, byte(seq)}, []byte("echo old"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tStore, err := store.NewStoreFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tStore.Close()
+
+	if cmd, err := tStore.Cmd(1); cmd != "echo old" || err != nil {
+		t.Errorf("Cmd(1) => (%q, %v), want (%q, nil)", cmd, err, "echo old")
+	}
+	if _, err := tStore.CmdInfo(1); err != storedefs.ErrNoCmdInfo {
+		t.Errorf("CmdInfo(1) => error %v, want %v", err, storedefs.ErrNoCmdInfo)
+	}
+	info := storedefs.CmdInfo{Dir: "/tmp", Session: "s"}
+	if err := tStore.SetCmdInfo(1, info); err != nil {
+		t.Errorf("SetCmdInfo(1, ...) => %v, want nil", err)
+	}
+}
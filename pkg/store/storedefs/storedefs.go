@@ -4,7 +4,10 @@
 // does not need to depend on the concrete implementation.
 package storedefs
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // NoBlacklist is an empty blacklist, to be used in GetDirs.
 var NoBlacklist = map[string]struct{}{}
@@ -13,16 +16,25 @@ var NoBlacklist = map[string]struct{}{}
 // completes with no result.
 var ErrNoMatchingCmd = errors.New("no matching command line")
 
+// ErrNoCmdInfo is the error returned when a CmdInfo query finds no information
+// about the command.
+var ErrNoCmdInfo = errors.New("no command info")
+
 // Store is an interface satisfied by the storage service.
 type Store interface {
 	NextCmdSeq() (int, error)
 	AddCmd(text string) (int, error)
+	AddCmds(texts []string, infos map[int]CmdInfo) (int, error)
 	DelCmd(seq int) error
 	Cmd(seq int) (string, error)
 	CmdsWithSeq(from, upto int) ([]Cmd, error)
 	NextCmd(from int, prefix string) (Cmd, error)
 	PrevCmd(upto int, prefix string) (Cmd, error)
 
+	SetCmdInfo(seq int, info CmdInfo) error
+	CmdInfo(seq int) (CmdInfo, error)
+	CmdInfos(from, upto int) (map[int]CmdInfo, error)
+
 	AddDir(dir string, incFactor float64) error
 	DelDir(dir string) error
 	Dirs(blacklist map[string]struct{}) ([]Dir, error)
@@ -43,3 +55,19 @@ type Cmd struct {
 	Text string
 	Seq  int
 }
+
+// CmdInfo contains information about an execution of an entry in the command
+// history.
+type CmdInfo struct {
+	// Working directory when the command was started.
+	Dir string
+	// Time when the command was started.
+	Start time.Time
+	// How long the command took to run.
+	Duration time.Duration
+	// Message of the exception the command terminated with, or "" if the
+	// command terminated normally.
+	Exception string
+	// An opaque identifier of the interactive session the command was run in.
+	Session string
+}
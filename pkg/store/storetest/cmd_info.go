@@ -0,0 +1,85 @@
+package storetest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+var (
+	cmdInfo1 = storedefs.CmdInfo{
+		Dir: "/home/elf", Start: time.Unix(1600000000, 0).UTC(),
+		Duration: 2 * time.Second, Session: "session1"}
+	cmdInfo2 = storedefs.CmdInfo{
+		Dir: "/tmp", Start: time.Unix(1600000010, 0).UTC(),
+		Duration: time.Millisecond, Exception: "exited with 1",
+		Session: "session1"}
+)
+
+// TestCmdInfo tests the command info functionality of a Store.
+func TestCmdInfo(t *testing.T, tStore storedefs.Store) {
+	seq1, _ := tStore.AddCmd("echo foo")
+	seq2, _ := tStore.AddCmd("false")
+	seq3, _ := tStore.AddCmd("echo bar")
+
+	if info, err := tStore.CmdInfo(seq1); !matchErr(err, storedefs.ErrNoCmdInfo) {
+		t.Errorf("CmdInfo(%v) => (%v, %v), want (%v, %v)",
+			seq1, info, err, storedefs.CmdInfo{}, storedefs.ErrNoCmdInfo)
+	}
+
+	for _, set := range []struct {
+		seq  int
+		info storedefs.CmdInfo
+	}{{seq1, cmdInfo1}, {seq2, cmdInfo2}} {
+		if err := tStore.SetCmdInfo(set.seq, set.info); err != nil {
+			t.Errorf("SetCmdInfo(%v, %v) => %v, want nil", set.seq, set.info, err)
+		}
+	}
+	for _, want := range []struct {
+		seq  int
+		info storedefs.CmdInfo
+	}{{seq1, cmdInfo1}, {seq2, cmdInfo2}} {
+		info, err := tStore.CmdInfo(want.seq)
+		if !reflect.DeepEqual(info, want.info) || err != nil {
+			t.Errorf("CmdInfo(%v) => (%v, %v), want (%v, nil)",
+				want.seq, info, err, want.info)
+		}
+	}
+
+	infos, err := tStore.CmdInfos(seq1, seq3+1)
+	wantInfos := map[int]storedefs.CmdInfo{seq1: cmdInfo1, seq2: cmdInfo2}
+	if !reflect.DeepEqual(infos, wantInfos) || err != nil {
+		t.Errorf("CmdInfos(%v, %v) => (%v, %v), want (%v, nil)",
+			seq1, seq3+1, infos, err, wantInfos)
+	}
+	infos, err = tStore.CmdInfos(seq2+1, seq3+1)
+	if len(infos) != 0 || err != nil {
+		t.Errorf("CmdInfos(%v, %v) => (%v, %v), want (empty, nil)",
+			seq2+1, seq3+1, infos, err)
+	}
+
+	first, err := tStore.AddCmds([]string{"echo lorem", "echo ipsum", "echo dolor"},
+		map[int]storedefs.CmdInfo{0: cmdInfo1, 2: cmdInfo2})
+	if first != seq3+1 || err != nil {
+		t.Errorf("AddCmds(...) => (%v, %v), want (%v, nil)", first, err, seq3+1)
+	}
+	if cmd, err := tStore.Cmd(first + 1); cmd != "echo ipsum" || err != nil {
+		t.Errorf("Cmd(%v) => (%v, %v), want (%v, nil)", first+1, cmd, err, "echo ipsum")
+	}
+	wantInfos = map[int]storedefs.CmdInfo{first: cmdInfo1, first + 2: cmdInfo2}
+	infos, err = tStore.CmdInfos(first, first+3)
+	if !reflect.DeepEqual(infos, wantInfos) || err != nil {
+		t.Errorf("CmdInfos(%v, %v) => (%v, %v), want (%v, nil)",
+			first, first+3, infos, err, wantInfos)
+	}
+
+	if err := tStore.DelCmd(seq1); err != nil {
+		t.Error("Failed to remove cmd")
+	}
+	if info, err := tStore.CmdInfo(seq1); !matchErr(err, storedefs.ErrNoCmdInfo) {
+		t.Errorf("CmdInfo(%v) after DelCmd => (%v, %v), want (%v, %v)",
+			seq1, info, err, storedefs.CmdInfo{}, storedefs.ErrNoCmdInfo)
+	}
+}
@@ -0,0 +1,201 @@
+package strutil
+
+import (
+	"sync"
+	"unicode"
+)
+
+// Scores used by FuzzyMatch. They are modelled after those of fzf.
+const (
+	fuzzyScoreMatch    = 16
+	fuzzyScoreGapStart = -3
+	fuzzyScoreGapExt   = -1
+	// Bonus for a match at the beginning of a word, or after a path separator
+	// or punctuation.
+	fuzzyBonusBoundary = 8
+	// Bonus for an uppercase letter following a lowercase letter, or a digit
+	// following a letter.
+	fuzzyBonusCamel = 7
+	// Bonus for a match immediately following another match.
+	fuzzyBonusConsecutive = -(fuzzyScoreGapStart + fuzzyScoreGapExt)
+	// The bonus of the first character of the pattern is multiplied by this.
+	fuzzyBonusFirstCharMultiplier = 2
+	// Bonus for a match that has the same case as in the pattern.
+	fuzzyBonusCase = 1
+)
+
+// FuzzyMatch matches pattern against s, and returns a score of the match, the
+// byte indices in s of the runes that are matched, and whether s matches at
+// all.
+//
+// The string s matches if pattern is a subsequence of it. Among all the ways
+// pattern can match s, the one with the highest score is used. The score
+// rewards matches that are contiguous, at word boundaries or of the same case
+// as in the pattern, and penalizes gaps between matches. When ignoreCase is
+// true, runes are compared case-insensitively.
+func FuzzyMatch(pattern, s string, ignoreCase bool) (score int, matched []int, ok bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+	buf := fuzzyBufPool.Get().(*fuzzyBuf)
+	defer fuzzyBufPool.Put(buf)
+	ps := buf.ps[:0]
+	for _, r := range pattern {
+		ps = append(ps, r)
+	}
+	// Byte indices and runes of s.
+	idx, rs := buf.idx[:0], buf.rs[:0]
+	for i, r := range s {
+		idx = append(idx, i)
+		rs = append(rs, r)
+	}
+	buf.ps, buf.idx, buf.rs = ps, idx, rs
+	n, m := len(rs), len(ps)
+	if n < m {
+		return 0, nil, false
+	}
+	eq := func(r, p rune) bool {
+		return r == p || ignoreCase && unicode.ToLower(r) == unicode.ToLower(p)
+	}
+
+	// Quick check that pattern is a subsequence of s.
+	for i, j := 0, 0; j < m; i++ {
+		if i == n {
+			return 0, nil, false
+		}
+		if eq(rs[i], ps[j]) {
+			j++
+		}
+	}
+
+	// When computing row j, h[i] is the best score of matching ps[:j+1]
+	// against rs[:i+1] with ps[j] matched to rs[i], and noMatch if that is
+	// impossible; chunk[i] is the bonus of the first match in the run of
+	// contiguous matches ending at i in that case. prevH and prevChunk are the
+	// same for row j-1. from[j*n+i] is the index that ps[j-1] is matched to
+	// when ps[j] is matched to rs[i], and is kept for all the rows for
+	// backtracking.
+	const noMatch = -1 << 30
+	h, prevH := growInts(buf.h, n), growInts(buf.prevH, n)
+	chunk, prevChunk := growInts(buf.chunk, n), growInts(buf.prevChunk, n)
+	from := growInts(buf.from, m*n)
+	buf.h, buf.prevH, buf.chunk, buf.prevChunk, buf.from = h, prevH, chunk, prevChunk, from
+	for j := 0; j < m; j++ {
+		if j > 0 {
+			h, prevH = prevH, h
+			chunk, prevChunk = prevChunk, chunk
+		}
+		// Best score of matching ps[:j] ending at or before i-2, with the gap
+		// up to i-1 accounted for, and the index it ends at.
+		gap, gapFrom := noMatch, -1
+		for i := 0; i < n; i++ {
+			if j > 0 && i >= 2 {
+				if prevH[i-2] != noMatch && prevH[i-2]+fuzzyScoreGapStart >= gap+fuzzyScoreGapExt {
+					gap, gapFrom = prevH[i-2]+fuzzyScoreGapStart, i-2
+				} else if gap != noMatch {
+					gap += fuzzyScoreGapExt
+				}
+			}
+			h[i] = noMatch
+			if !eq(rs[i], ps[j]) {
+				continue
+			}
+			bonus := fuzzyBonus(rs, i)
+			caseBonus := 0
+			if rs[i] == ps[j] {
+				caseBonus = fuzzyBonusCase
+			}
+			if j == 0 {
+				h[i] = fuzzyScoreMatch + bonus*fuzzyBonusFirstCharMultiplier + caseBonus
+				chunk[i] = bonus
+				continue
+			}
+			best, bestFrom, bestChunk := noMatch, -1, bonus
+			if gap != noMatch {
+				best, bestFrom = gap+bonus, gapFrom
+			}
+			if i >= 1 && prevH[i-1] != noMatch {
+				// A contiguous match gets at least the bonus of the first
+				// match in the run.
+				consBonus := max(bonus, max(prevChunk[i-1], fuzzyBonusConsecutive))
+				if prevH[i-1]+consBonus >= best {
+					best, bestFrom = prevH[i-1]+consBonus, i-1
+					bestChunk = prevChunk[i-1]
+				}
+			}
+			if best != noMatch {
+				h[i] = best + fuzzyScoreMatch + caseBonus
+				from[j*n+i] = bestFrom
+				chunk[i] = bestChunk
+			}
+		}
+	}
+
+	// Find the best end position and backtrack.
+	end := -1
+	for i := 0; i < n; i++ {
+		if h[i] != noMatch && (end == -1 || h[i] > h[end]) {
+			end = i
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	score = h[end]
+	matched = make([]int, m)
+	for j, i := m-1, end; j >= 0; j-- {
+		matched[j] = idx[i]
+		i = from[j*n+i]
+	}
+	return score, matched, true
+}
+
+// Buffers used by FuzzyMatch, reused across calls to avoid allocating them
+// for every candidate.
+type fuzzyBuf struct {
+	ps, rs                          []rune
+	idx, h, prevH, chunk, prevChunk []int
+	from                            []int
+}
+
+var fuzzyBufPool = sync.Pool{New: func() interface{} { return &fuzzyBuf{} }}
+
+// Returns a slice of length n, reusing the underlying array of s if it is
+// large enough.
+func growInts(s []int, n int) []int {
+	if cap(s) < n {
+		return make([]int, n)
+	}
+	return s[:n]
+}
+
+// Returns the bonus for matching rs[i], based on the rune before it.
+func fuzzyBonus(rs []rune, i int) int {
+	r := rs[i]
+	if !isWordRune(r) {
+		return 0
+	}
+	if i == 0 {
+		return fuzzyBonusBoundary
+	}
+	prev := rs[i-1]
+	switch {
+	case !isWordRune(prev):
+		return fuzzyBonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(r),
+		unicode.IsLetter(prev) && unicode.IsDigit(r):
+		return fuzzyBonusCamel
+	}
+	return 0
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package strutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+var fuzzyMatchTests = []struct {
+	pattern, s  string
+	ignoreCase  bool
+	wantMatched []int
+	wantOK      bool
+}{
+	{"", "foo", false, nil, true},
+	{"foo", "", false, nil, false},
+	{"foo", "foo", false, []int{0, 1, 2}, true},
+	{"fb", "foo bar", false, []int{0, 4}, true},
+	{"bf", "foo bar", false, nil, false},
+	{"Foo", "foo", false, nil, false},
+	{"Foo", "foo", true, []int{0, 1, 2}, true},
+	// Prefers contiguous matches.
+	{"bar", "b a bar", false, []int{4, 5, 6}, true},
+	// Prefers matches at word boundaries.
+	{"gc", "git-checkout", false, []int{0, 4}, true},
+	{"fb", "foobar/foo/bar", false, []int{7, 11}, true},
+	// Byte indices are reported for non-ASCII text.
+	{"好界", "你好世界", false, []int{3, 9}, true},
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, test := range fuzzyMatchTests {
+		_, matched, ok := FuzzyMatch(test.pattern, test.s, test.ignoreCase)
+		if ok != test.wantOK || !reflect.DeepEqual(matched, test.wantMatched) {
+			t.Errorf("FuzzyMatch(%q, %q, %v) -> %v, %v, want %v, %v",
+				test.pattern, test.s, test.ignoreCase,
+				matched, ok, test.wantMatched, test.wantOK)
+		}
+	}
+}
+
+var fuzzyRankTests = []struct {
+	pattern, better, worse string
+}{
+	{"foo", "foo", "f-o-o"},
+	{"foo", "foobar", "xfxoxo"},
+	{"fb", "foo-bar", "fxxxxb"},
+	{"ls", "ls -l", "lorems"},
+	{"cm", "camelMan", "camelman"},
+	{"Foo", "Foo", "foo"},
+}
+
+func TestFuzzyMatch_Ranking(t *testing.T) {
+	for _, test := range fuzzyRankTests {
+		better, _, _ := FuzzyMatch(test.pattern, test.better, true)
+		worse, _, _ := FuzzyMatch(test.pattern, test.worse, true)
+		if better <= worse {
+			t.Errorf("for pattern %q, %q scored %v, not higher than %q (%v)",
+				test.pattern, test.better, better, test.worse, worse)
+		}
+	}
+}
+
+func BenchmarkFuzzyMatch(b *testing.B) {
+	candidates := []string{
+		"git checkout master", "go test ./pkg/...", "ls -l /usr/local/bin",
+		"cd ~/src/elv.sh/pkg/edit", "echo $E:HOME", "vim pkg/strutil/fuzzy.go",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, s := range candidates {
+			FuzzyMatch("gco", s, true)
+		}
+	}
+}